@@ -3,4 +3,11 @@ package constants
 const (
 	DependencyTypePython = "python"
 	DependencyTypeNode   = "node"
+	DependencyTypeGo     = "go"
+	DependencyTypeJava   = "java"
+	DependencyTypeSystem = "system"
+	DependencyTypeConda  = "conda"
+	DependencyTypeRuby   = "ruby"
+	DependencyTypePhp    = "php"
+	DependencyTypeRust   = "rust"
 )
@@ -15,6 +15,65 @@ const (
 )
 
 const (
-	MessageCodeUpdateTask = "update-task"
-	MessageCodeInsertLogs = "insert-logs"
+	MessageCodeGoUpdate    = "update-go"
+	MessageCodeGoSave      = "save-go"
+	MessageCodeGoInstall   = "install-go"
+	MessageCodeGoUninstall = "uninstall-go"
+)
+
+const (
+	MessageCodeJavaUpdate    = "update-java"
+	MessageCodeJavaSave      = "save-java"
+	MessageCodeJavaInstall   = "install-java"
+	MessageCodeJavaUninstall = "uninstall-java"
+)
+
+const (
+	MessageCodeSystemUpdate    = "update-system"
+	MessageCodeSystemSave      = "save-system"
+	MessageCodeSystemInstall   = "install-system"
+	MessageCodeSystemUninstall = "uninstall-system"
+)
+
+const (
+	MessageCodeCondaUpdate    = "update-conda"
+	MessageCodeCondaSave      = "save-conda"
+	MessageCodeCondaInstall   = "install-conda"
+	MessageCodeCondaUninstall = "uninstall-conda"
+)
+
+const (
+	MessageCodeRubyUpdate    = "update-ruby"
+	MessageCodeRubySave      = "save-ruby"
+	MessageCodeRubyInstall   = "install-ruby"
+	MessageCodeRubyUninstall = "uninstall-ruby"
+)
+
+const (
+	MessageCodePhpUpdate    = "update-php"
+	MessageCodePhpSave      = "save-php"
+	MessageCodePhpInstall   = "install-php"
+	MessageCodePhpUninstall = "uninstall-php"
+)
+
+const (
+	MessageCodeRustUpdate    = "update-rust"
+	MessageCodeRustSave      = "save-rust"
+	MessageCodeRustInstall   = "install-rust"
+	MessageCodeRustUninstall = "uninstall-rust"
+)
+
+// message codes of custom providers are suffixed with provider key
+const (
+	MessageCodeCustomUpdatePrefix    = "update-custom-"
+	MessageCodeCustomSavePrefix      = "save-custom-"
+	MessageCodeCustomInstallPrefix   = "install-custom-"
+	MessageCodeCustomUninstallPrefix = "uninstall-custom-"
+)
+
+const (
+	MessageCodeUpdateTask        = "update-task"
+	MessageCodeInsertLogs        = "insert-logs"
+	MessageCodeCancelTask        = "cancel-task"
+	MessageCodeInsertTaskAttempt = "insert-task-attempt"
 )
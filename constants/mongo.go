@@ -4,3 +4,4 @@ const DependencySettingsColName = "dependency_settings"
 const DependenciesColName = "dependencies"
 const DependencyTasksColName = "dependency_tasks"
 const DependencyLogsColName = "dependency_logs"
+const DependencyOperationsColName = "dependency_operations"
@@ -0,0 +1,8 @@
+package constants
+
+const (
+	NodePackageManagerNpm       = "npm"
+	NodePackageManagerYarn      = "yarn"
+	NodePackageManagerYarnBerry = "yarn-berry"
+	NodePackageManagerPnpm      = "pnpm"
+)
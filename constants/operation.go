@@ -0,0 +1,12 @@
+package constants
+
+const (
+	OperationStatusRunning        = "running"
+	OperationStatusSuccess        = "success"
+	OperationStatusPartialFailure = "partial_failure"
+	OperationStatusFailure        = "failure"
+)
+
+// OperationErrorTaskNotSent is the error of node which the task of operation
+// has not been sent to, e.g. if sending to a previous node failed
+const OperationErrorTaskNotSent = "task not sent to node"
@@ -0,0 +1,13 @@
+package constants
+
+const (
+	ProviderParserTypeJson        = "json"
+	ProviderParserTypeRegex       = "regex"
+	ProviderParserTypeNameVersion = "name_version"
+)
+
+const (
+	ProviderParserDefaultNameField    = "name"
+	ProviderParserDefaultVersionField = "version"
+	ProviderParserDefaultSeparator    = "=="
+)
@@ -0,0 +1,27 @@
+package constants
+
+import "time"
+
+const (
+	GoProxyDefaultUrl      = "https://proxy.golang.org"
+	MavenRepoDefaultUrl    = "https://repo.maven.apache.org/maven2"
+	MavenSearchDefaultUrl  = "https://search.maven.org/solrsearch/select"
+	CondaApiDefaultUrl     = "https://api.anaconda.org"
+	CondaChannelDefaultUrl = "https://conda.anaconda.org"
+	CondaDefaultChannel    = "anaconda"
+	RubyGemsDefaultUrl     = "https://rubygems.org"
+	PackagistDefaultUrl    = "https://repo.packagist.org"
+	PackagistSearchUrl     = "https://packagist.org/search.json"
+	CratesIndexDefaultUrl  = "https://index.crates.io"
+	CratesApiUrl           = "https://crates.io/api/v1"
+	NpmRegistryDefaultUrl  = "https://registry.npmjs.org"
+	PypiDefaultUrl         = "https://pypi.org"
+	PypiSimpleDefaultUrl   = "https://pypi.org/simple"
+)
+
+const (
+	CondaSubdirNoarch          = "noarch"
+	CondaCurrentRepodataFile   = "current_repodata.json"
+	CondaRepodataFile          = "repodata.json"
+	CondaRepodataCacheDuration = 1 * time.Hour
+)
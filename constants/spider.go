@@ -2,5 +2,16 @@ package constants
 
 const (
 	DependencyConfigRequirementsTxt = "requirements.txt"
+	DependencyConfigPyprojectToml   = "pyproject.toml"
+	DependencyConfigPoetryLock      = "poetry.lock"
+	DependencyConfigPipfile         = "Pipfile"
+	DependencyConfigPipfileLock     = "Pipfile.lock"
 	DependencyConfigPackageJson     = "package.json"
+	DependencyConfigPackageLockJson = "package-lock.json"
+	DependencyConfigYarnLock        = "yarn.lock"
+	DependencyConfigPnpmLockYaml    = "pnpm-lock.yaml"
+	DependencyConfigPomXml          = "pom.xml"
+	DependencyConfigEnvironmentYml  = "environment.yml"
+	DependencyConfigGemfile         = "Gemfile"
+	DependencyConfigComposerJson    = "composer.json"
 )
@@ -0,0 +1,8 @@
+package constants
+
+const (
+	SystemPackageManagerApt = "apt"
+	SystemPackageManagerApk = "apk"
+	SystemPackageManagerYum = "yum"
+	SystemPackageManagerDnf = "dnf"
+)
@@ -0,0 +1,11 @@
+package constants
+
+import "time"
+
+// TaskStatusQueued is the status of task waiting in the queue of node,
+// while others are the same as tasks in crawlab-core
+const TaskStatusQueued = "queued"
+
+// TaskCancelledRetention is how long a cancelled task id is kept on node,
+// in case the task arrives after it is cancelled
+const TaskCancelledRetention = 1 * time.Hour
@@ -0,0 +1,37 @@
+package entity
+
+type CondaListPackage struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Channel     string `json:"channel"`
+	BuildString string `json:"build_string"`
+	Platform    string `json:"platform"`
+}
+
+type AnacondaPackage struct {
+	Name          string   `json:"name"`
+	Owner         string   `json:"owner"`
+	Summary       string   `json:"summary"`
+	LatestVersion string   `json:"latest_version"`
+	Versions      []string `json:"versions"`
+}
+
+// CondaRepodata is the index of a channel subdir, where packages of
+// ".tar.bz2" and ".conda" formats are listed separately
+type CondaRepodata struct {
+	Packages      map[string]CondaRepodataPackage `json:"packages"`
+	PackagesConda map[string]CondaRepodataPackage `json:"packages.conda"`
+}
+
+type CondaRepodataPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// CondaEnvironment is the environment file of conda, where dependencies
+// are match specs, e.g. "numpy=1.21", or a map of pip requirements
+type CondaEnvironment struct {
+	Name         string        `yaml:"name"`
+	Channels     []string      `yaml:"channels"`
+	Dependencies []interface{} `yaml:"dependencies"`
+}
@@ -7,6 +7,7 @@ type DependencyResult struct {
 	NodeIds       []primitive.ObjectID `json:"node_ids,omitempty" bson:"node_ids,omitempty"`
 	Versions      []string             `json:"versions,omitempty" bson:"versions,omitempty"`
 	LatestVersion string               `json:"latest_version" bson:"latest_version"`
+	WantedVersion string               `json:"wanted_version,omitempty" bson:"wanted_version,omitempty"`
 	Count         int                  `json:"count,omitempty" bson:"count,omitempty"`
 	Upgradable    bool                 `json:"upgradable" bson:"upgradable"`
 	Downgradable  bool                 `json:"downgradable" bson:"downgradable"`
@@ -0,0 +1,13 @@
+package entity
+
+type GoProxyInfo struct {
+	Version string `json:"Version"`
+	Time    string `json:"Time"`
+}
+
+type GoBinaryInfo struct {
+	FilePath   string
+	Path       string
+	ModulePath string
+	Version    string
+}
@@ -3,11 +3,19 @@ package entity
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
 type InstallParams struct {
-	TaskId    primitive.ObjectID `json:"task_id"`
-	Cmd       string             `json:"cmd"`
-	Names     []string           `json:"names"`
-	Upgrade   bool               `json:"upgrade"`
-	Proxy     string             `json:"proxy"`
-	UseConfig bool               `json:"use_config"`
-	SpiderId  primitive.ObjectID `json:"spider_id"`
+	TaskId      primitive.ObjectID `json:"task_id"`
+	Cmd         string             `json:"cmd"`
+	Names       []string           `json:"names"`
+	Packages    []PackageSpec      `json:"packages"`
+	Upgrade     bool               `json:"upgrade"`
+	Proxy       string             `json:"proxy"`
+	UseConfig   bool               `json:"use_config"`
+	SpiderId    primitive.ObjectID `json:"spider_id"`
+	Channels    []string           `json:"channels"`
+	EnvName     string             `json:"env_name"`
+	Isolated    bool               `json:"isolated"`
+	EnvPath     string             `json:"env_path"`
+	Local       bool               `json:"local"`
+	Policy      TaskPolicy         `json:"policy"`
+	Concurrency int                `json:"concurrency"`
 }
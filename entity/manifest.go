@@ -0,0 +1,9 @@
+package entity
+
+// ManifestError is a line of manifest file which failed to parse
+type ManifestError struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Content string `json:"content"`
+	Error   string `json:"error"`
+}
@@ -0,0 +1,64 @@
+package entity
+
+import "encoding/xml"
+
+type MavenMetadata struct {
+	GroupId    string                  `xml:"groupId"`
+	ArtifactId string                  `xml:"artifactId"`
+	Versioning MavenMetadataVersioning `xml:"versioning"`
+}
+
+type MavenMetadataVersioning struct {
+	Latest   string   `xml:"latest"`
+	Release  string   `xml:"release"`
+	Versions []string `xml:"versions>version"`
+}
+
+type MavenSettings struct {
+	LocalRepository string `xml:"localRepository"`
+}
+
+type MavenSearchResponse struct {
+	Response MavenSearchResponseBody `json:"response"`
+}
+
+type MavenSearchResponseBody struct {
+	NumFound int              `json:"numFound"`
+	Docs     []MavenSearchDoc `json:"docs"`
+}
+
+type MavenSearchDoc struct {
+	Id            string `json:"id"`
+	GroupId       string `json:"g"`
+	ArtifactId    string `json:"a"`
+	LatestVersion string `json:"latestVersion"`
+}
+
+type MavenArtifact struct {
+	GroupId    string
+	ArtifactId string
+	Version    string
+	Dir        string
+}
+
+// MavenPom is the project object model of pom.xml
+type MavenPom struct {
+	Version      string               `xml:"version"`
+	Properties   MavenPomProperties   `xml:"properties"`
+	Dependencies []MavenPomDependency `xml:"dependencies>dependency"`
+}
+
+type MavenPomProperties struct {
+	Entries []MavenPomProperty `xml:",any"`
+}
+
+type MavenPomProperty struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+type MavenPomDependency struct {
+	GroupId    string `xml:"groupId"`
+	ArtifactId string `xml:"artifactId"`
+	Version    string `xml:"version"`
+}
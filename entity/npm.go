@@ -1,17 +1,24 @@
 package entity
 
-type NpmResponseList struct {
-	Total   int         `json:"total"`
-	Results []NpmResult `json:"results"`
+// NpmSearchResponse is the response of "/-/v1/search" of npm registry
+type NpmSearchResponse struct {
+	Objects []NpmSearchObject `json:"objects"`
+	Total   int               `json:"total"`
 }
 
-type NpmResult struct {
+type NpmSearchObject struct {
 	Package NpmPackage `json:"package"`
 }
 
 type NpmPackage struct {
-	Name    string `json:"name"`
-	Version string `json:"version"`
+	Name        string   `json:"name"`
+	Version     string   `json:"version"`
+	Description string   `json:"description"`
+	Links       NpmLinks `json:"links"`
+}
+
+type NpmLinks struct {
+	Homepage string `json:"homepage"`
 }
 
 type NpmListResult struct {
@@ -22,10 +29,28 @@ type NpmListPackage struct {
 	Version string `json:"version"`
 }
 
-type NpmResponseDetail struct {
-	Collected NpmCollected `json:"collected"`
+// NpmPackument is the metadata of package in npm registry, where time,
+// description, homepage and license are absent in abbreviated metadata
+type NpmPackument struct {
+	Name        string                       `json:"name"`
+	DistTags    map[string]string            `json:"dist-tags"`
+	Versions    map[string]NpmPackageVersion `json:"versions"`
+	Time        map[string]string            `json:"time"`
+	Description string                       `json:"description"`
+	Homepage    string                       `json:"homepage"`
+	License     interface{}                  `json:"license"`
+}
+
+type NpmPackageVersion struct {
+	Version    string      `json:"version"`
+	Deprecated interface{} `json:"deprecated"`
+	Engines    interface{} `json:"engines"`
 }
 
-type NpmCollected struct {
-	Metadata NpmPackage `json:"metadata"`
+type NpmPackageJson struct {
+	Name            string            `json:"name"`
+	Version         string            `json:"version"`
+	Description     string            `json:"description"`
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies"`
 }
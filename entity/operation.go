@@ -0,0 +1,14 @@
+package entity
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// OperationNode is the status of operation on a node, which is of the
+// latest task of the node
+type OperationNode struct {
+	NodeId   primitive.ObjectID `json:"node_id" bson:"node_id"`
+	TaskId   primitive.ObjectID `json:"task_id" bson:"task_id"`
+	Status   string             `json:"status" bson:"status"`
+	Error    string             `json:"error" bson:"error"`
+	DepNames []string           `json:"dep_names" bson:"dep_names"`
+	Attempts int                `json:"attempts" bson:"attempts"`
+}
@@ -0,0 +1,8 @@
+package entity
+
+// PackageSpec is a package to install with an optional version spec in the
+// syntax of its ecosystem, e.g. "==2.28.1" of Python or "^4.17.0" of Node
+type PackageSpec struct {
+	Name string `json:"name" bson:"name"`
+	Spec string `json:"spec,omitempty" bson:"spec,omitempty"`
+}
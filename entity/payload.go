@@ -4,15 +4,21 @@ import "go.mongodb.org/mongo-driver/bson/primitive"
 
 type InstallPayload struct {
 	Names     []string             `json:"names"`
+	Packages  []PackageSpec        `json:"packages"`
 	Mode      string               `json:"mode"`
 	Upgrade   bool                 `json:"upgrade"`
 	NodeIds   []primitive.ObjectID `json:"node_ids"`
 	UseConfig bool                 `json:"use_config"`
 	SpiderId  primitive.ObjectID   `json:"spider_id"`
+	EnvName   string               `json:"env_name"`
+	Policy    *TaskPolicy          `json:"policy"`
 }
 
 type UninstallPayload struct {
-	Names   []string             `json:"names"`
-	Mode    string               `json:"mode"`
-	NodeIds []primitive.ObjectID `json:"node_ids"`
+	Names    []string             `json:"names"`
+	Mode     string               `json:"mode"`
+	NodeIds  []primitive.ObjectID `json:"node_ids"`
+	EnvName  string               `json:"env_name"`
+	SpiderId primitive.ObjectID   `json:"spider_id"`
+	Policy   *TaskPolicy          `json:"policy"`
 }
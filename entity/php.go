@@ -0,0 +1,25 @@
+package entity
+
+type ComposerShowResult struct {
+	Installed []ComposerPackage `json:"installed"`
+}
+
+type ComposerPackage struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+}
+
+type ComposerJson struct {
+	Require    map[string]string `json:"require"`
+	RequireDev map[string]string `json:"require-dev"`
+}
+
+type PackagistMetadata struct {
+	Packages map[string][]ComposerPackage `json:"packages"`
+}
+
+type PackagistSearchResponse struct {
+	Results []ComposerPackage `json:"results"`
+	Total   int               `json:"total"`
+}
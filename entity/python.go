@@ -0,0 +1,55 @@
+package entity
+
+// PythonRequirement is a requirement declared in requirements.txt, where
+// source is the url, vcs url or local path of the distribution if any
+type PythonRequirement struct {
+	Name      string   `json:"name"`
+	Extras    []string `json:"extras,omitempty"`
+	Specifier string   `json:"specifier,omitempty"`
+	Marker    string   `json:"marker,omitempty"`
+	Source    string   `json:"source,omitempty"`
+	Editable  bool     `json:"editable,omitempty"`
+	File      string   `json:"file"`
+	Line      int      `json:"line"`
+}
+
+// PypiJsonResponse is the response of PyPI JSON API, e.g. "/pypi/requests/json"
+type PypiJsonResponse struct {
+	Info     PypiInfo              `json:"info"`
+	Releases map[string][]PypiFile `json:"releases"`
+}
+
+type PypiInfo struct {
+	Name           string            `json:"name"`
+	Version        string            `json:"version"`
+	Summary        string            `json:"summary"`
+	HomePage       string            `json:"home_page"`
+	ProjectUrls    map[string]string `json:"project_urls"`
+	License        string            `json:"license"`
+	RequiresPython string            `json:"requires_python"`
+}
+
+// PypiFile is a distribution file in PyPI JSON API
+type PypiFile struct {
+	Filename       string `json:"filename"`
+	UploadTime     string `json:"upload_time_iso_8601"`
+	Yanked         bool   `json:"yanked"`
+	YankedReason   string `json:"yanked_reason"`
+	RequiresPython string `json:"requires_python"`
+}
+
+// PypiSimpleResponse is the response of PEP 691 Simple API in json
+type PypiSimpleResponse struct {
+	Name     string           `json:"name"`
+	Files    []PypiSimpleFile `json:"files"`
+	Versions []string         `json:"versions"`
+}
+
+// PypiSimpleFile is a distribution file in Simple API, where yanked is
+// either a boolean or the reason string of yanking
+type PypiSimpleFile struct {
+	Filename       string      `json:"filename"`
+	UploadTime     string      `json:"upload-time"`
+	Yanked         interface{} `json:"yanked"`
+	RequiresPython string      `json:"requires-python"`
+}
@@ -0,0 +1,44 @@
+package entity
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// RegistryPackage is the package metadata in a package registry
+type RegistryPackage struct {
+	Name           string            `json:"name"`
+	Description    string            `json:"description"`
+	LatestVersion  string            `json:"latest_version"`
+	Versions       []string          `json:"versions,omitempty"`
+	DistTags       map[string]string `json:"dist_tags,omitempty"`
+	Homepage       string            `json:"homepage,omitempty"`
+	License        string            `json:"license,omitempty"`
+	RequiresPython string            `json:"requires_python,omitempty"`
+	Engines        map[string]string `json:"engines,omitempty"`
+	Releases       []RegistryRelease `json:"releases,omitempty"`
+}
+
+// RegistryRelease is a published version of package, where yanked releases
+// of PyPI and deprecated versions of npm are flagged
+type RegistryRelease struct {
+	Version        string            `json:"version"`
+	ReleaseTs      time.Time         `json:"release_ts"`
+	Yanked         bool              `json:"yanked"`
+	YankedReason   string            `json:"yanked_reason,omitempty"`
+	Deprecated     string            `json:"deprecated,omitempty"`
+	RequiresPython string            `json:"requires_python,omitempty"`
+	Engines        map[string]string `json:"engines,omitempty"`
+}
+
+// PackageDetail is the package metadata in registry, with installed
+// versions on nodes
+type PackageDetail struct {
+	RegistryPackage
+	Installed []PackageInstalledVersion `json:"installed"`
+}
+
+type PackageInstalledVersion struct {
+	Version string               `json:"version" bson:"version"`
+	NodeIds []primitive.ObjectID `json:"node_ids" bson:"node_ids"`
+}
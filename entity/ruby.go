@@ -0,0 +1,11 @@
+package entity
+
+type RubyGemsVersion struct {
+	Version string `json:"version"`
+}
+
+type RubyGemsSearchResult struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Info    string `json:"info"`
+}
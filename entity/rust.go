@@ -0,0 +1,30 @@
+package entity
+
+type CargoCrates2 struct {
+	Installs map[string]CargoInstall `json:"installs"`
+}
+
+type CargoInstall struct {
+	Bins []string `json:"bins"`
+}
+
+type CargoIndexEntry struct {
+	Name   string `json:"name"`
+	Vers   string `json:"vers"`
+	Yanked bool   `json:"yanked"`
+}
+
+type CratesSearchResponse struct {
+	Crates []CratesSearchCrate `json:"crates"`
+	Meta   CratesSearchMeta    `json:"meta"`
+}
+
+type CratesSearchCrate struct {
+	Name        string `json:"name"`
+	MaxVersion  string `json:"max_stable_version"`
+	Description string `json:"description"`
+}
+
+type CratesSearchMeta struct {
+	Total int `json:"total"`
+}
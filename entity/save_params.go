@@ -0,0 +1,15 @@
+package entity
+
+import (
+	"encoding/json"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SaveParams is sent from worker nodes with installed dependencies, which
+// are scoped to the isolated environment of spider if spider id is set, or
+// to the named environment of provider if env name is set
+type SaveParams struct {
+	SpiderId     primitive.ObjectID `json:"spider_id"`
+	EnvName      string             `json:"env_name"`
+	Dependencies json.RawMessage    `json:"dependencies"`
+}
@@ -0,0 +1,8 @@
+package entity
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+type TaskAttemptMessage struct {
+	TaskId  primitive.ObjectID `json:"task_id"`
+	Attempt TaskAttempt        `json:"attempt"`
+}
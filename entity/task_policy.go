@@ -0,0 +1,26 @@
+package entity
+
+import "time"
+
+// TaskPolicy is the timeout and retry policy of install/uninstall tasks,
+// where a failed attempt is retried if its exit code or any line of its
+// logs is transient, or on any failure if neither is specified
+type TaskPolicy struct {
+	Timeout        int      `json:"timeout" bson:"timeout"`                                       // seconds of each attempt, no timeout if 0
+	MaxAttempts    int      `json:"max_attempts" bson:"max_attempts"`                             // no retries if 0 or 1
+	Backoff        int      `json:"backoff" bson:"backoff"`                                       // seconds before the first retry, doubled for each retry
+	MaxBackoff     int      `json:"max_backoff" bson:"max_backoff"`                               // seconds
+	RetryExitCodes []int    `json:"retry_exit_codes,omitempty" bson:"retry_exit_codes,omitempty"` // transient exit codes
+	RetryPatterns  []string `json:"retry_patterns,omitempty" bson:"retry_patterns,omitempty"`     // regular expressions of transient log lines
+	RetryOnTimeout bool     `json:"retry_on_timeout" bson:"retry_on_timeout"`
+}
+
+// TaskAttempt is an attempt to run an install/uninstall task
+type TaskAttempt struct {
+	Attempt  int       `json:"attempt" bson:"attempt"`
+	StartTs  time.Time `json:"start_ts" bson:"start_ts"`
+	EndTs    time.Time `json:"end_ts" bson:"end_ts"`
+	ExitCode int       `json:"exit_code" bson:"exit_code"`
+	TimedOut bool      `json:"timed_out" bson:"timed_out"`
+	Error    string    `json:"error,omitempty" bson:"error,omitempty"`
+}
@@ -3,7 +3,14 @@ package entity
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
 type UninstallParams struct {
-	TaskId primitive.ObjectID `json:"task_id"`
-	Names  []string           `json:"names"`
-	Cmd    string             `json:"cmd"`
+	TaskId      primitive.ObjectID `json:"task_id"`
+	Names       []string           `json:"names"`
+	Cmd         string             `json:"cmd"`
+	EnvName     string             `json:"env_name"`
+	SpiderId    primitive.ObjectID `json:"spider_id"`
+	Isolated    bool               `json:"isolated"`
+	EnvPath     string             `json:"env_path"`
+	Local       bool               `json:"local"`
+	Policy      TaskPolicy         `json:"policy"`
+	Concurrency int                `json:"concurrency"`
 }
@@ -1,5 +1,12 @@
 package entity
 
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
 type UpdateParams struct {
-	Cmd string `json:"cmd"`
+	Cmd      string             `json:"cmd"`
+	SpiderId primitive.ObjectID `json:"spider_id"`
+	Isolated bool               `json:"isolated"`
+	EnvPath  string             `json:"env_path"`
+	Local    bool               `json:"local"`
+	EnvName  string             `json:"env_name"`
 }
@@ -13,6 +13,8 @@ require (
 	github.com/crawlab-team/go-trace v0.1.1
 	github.com/gin-gonic/gin v1.7.4
 	github.com/imroc/req v0.3.0
+	github.com/pelletier/go-toml v1.7.0
 	go.mongodb.org/mongo-driver v1.8.0
 	go.uber.org/dig v1.10.0
+	gopkg.in/yaml.v2 v2.3.0
 )
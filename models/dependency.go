@@ -8,6 +8,9 @@ import (
 type Dependency struct {
 	Id            primitive.ObjectID      `json:"_id" bson:"_id"`
 	NodeId        primitive.ObjectID      `json:"node_id" bson:"node_id"`
+	SpiderId      primitive.ObjectID      `json:"spider_id,omitempty" bson:"spider_id,omitempty"`
+	EnvName       string                  `json:"env_name,omitempty" bson:"env_name,omitempty"`
+	Platform      string                  `json:"platform,omitempty" bson:"platform,omitempty"`
 	Type          string                  `json:"type" bson:"type"`
 	Name          string                  `json:"name" bson:"name"`
 	Version       string                  `json:"version" bson:"version"`
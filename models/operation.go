@@ -0,0 +1,33 @@
+package models
+
+import (
+	"github.com/crawlab-team/plugin-dependency/entity"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Operation is an install/uninstall operation on nodes, which owns the task
+// of each node and aggregates their statuses
+type Operation struct {
+	Id        primitive.ObjectID   `json:"_id" bson:"_id"`
+	Status    string               `json:"status" bson:"status"`
+	SettingId primitive.ObjectID   `json:"setting_id" bson:"setting_id"`
+	Type      string               `json:"type" bson:"type"`
+	Action    string               `json:"action" bson:"action"`
+	NodeIds   []primitive.ObjectID `json:"node_ids" bson:"node_ids"`
+	DepNames  []string             `json:"dep_names" bson:"dep_names"`
+	Packages  []entity.PackageSpec `json:"packages,omitempty" bson:"packages,omitempty"`
+	Upgrade   bool                 `json:"upgrade" bson:"upgrade"`
+	UseConfig bool                 `json:"use_config" bson:"use_config"`
+	SpiderId  primitive.ObjectID   `json:"spider_id,omitempty" bson:"spider_id,omitempty"`
+	EnvName   string               `json:"env_name,omitempty" bson:"env_name,omitempty"`
+	Policy    entity.TaskPolicy    `json:"policy" bson:"policy"`
+	CreateTs  time.Time            `json:"create_ts" bson:"create_ts"`
+	UpdateTs  time.Time            `json:"update_ts" bson:"update_ts"`
+}
+
+// OperationDetail is the operation with its status on each node
+type OperationDetail struct {
+	Operation `bson:",inline"`
+	Nodes     []entity.OperationNode `json:"nodes" bson:"nodes"`
+}
@@ -0,0 +1,30 @@
+package models
+
+// ProviderDefinition defines a custom dependency provider declaratively.
+//
+// Command templates are split into arguments by whitespace and may contain
+// placeholders {{cmd}}, {{name}}, {{names}}, {{proxy}} and {{manifest}}.
+// Arguments wrapped in square brackets, e.g. "[--index-url {{proxy}}]",
+// are omitted if any placeholder in them is empty.
+type ProviderDefinition struct {
+	ListCmd          string          `json:"list_cmd" bson:"list_cmd"`
+	InstallCmd       string          `json:"install_cmd" bson:"install_cmd"`
+	UpgradeCmd       string          `json:"upgrade_cmd,omitempty" bson:"upgrade_cmd,omitempty"`
+	ConfigInstallCmd string          `json:"config_install_cmd,omitempty" bson:"config_install_cmd,omitempty"`
+	UninstallCmd     string          `json:"uninstall_cmd" bson:"uninstall_cmd"`
+	LatestVersionCmd string          `json:"latest_version_cmd,omitempty" bson:"latest_version_cmd,omitempty"`
+	Parser           ProviderParser  `json:"parser" bson:"parser"`
+	Manifest         string          `json:"manifest,omitempty" bson:"manifest,omitempty"`
+	ManifestParser   *ProviderParser `json:"manifest_parser,omitempty" bson:"manifest_parser,omitempty"`
+}
+
+// ProviderParser defines how the output of list command, or the manifest
+// of spider dependencies is parsed
+type ProviderParser struct {
+	Type         string `json:"type" bson:"type"`
+	Path         string `json:"path,omitempty" bson:"path,omitempty"`
+	NameField    string `json:"name_field,omitempty" bson:"name_field,omitempty"`
+	VersionField string `json:"version_field,omitempty" bson:"version_field,omitempty"`
+	Pattern      string `json:"pattern,omitempty" bson:"pattern,omitempty"`
+	Separator    string `json:"separator,omitempty" bson:"separator,omitempty"`
+}
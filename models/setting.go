@@ -1,17 +1,24 @@
 package models
 
 import (
+	"github.com/crawlab-team/plugin-dependency/entity"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"time"
 )
 
 type Setting struct {
-	Id           primitive.ObjectID `json:"_id" bson:"_id"`
-	Key          string             `json:"key" bson:"key"`
-	Name         string             `json:"name" bson:"name"`
-	Description  string             `json:"description" bson:"description"`
-	Enabled      bool               `json:"enabled" bson:"enabled"`
-	Cmd          string             `json:"cmd" bson:"cmd"`
-	Proxy        string             `json:"proxy" bson:"proxy"`
-	LastUpdateTs time.Time          `json:"last_update_ts" bson:"last_update_ts"`
+	Id           primitive.ObjectID  `json:"_id" bson:"_id"`
+	Key          string              `json:"key" bson:"key"`
+	Name         string              `json:"name" bson:"name"`
+	Description  string              `json:"description" bson:"description"`
+	Enabled      bool                `json:"enabled" bson:"enabled"`
+	Cmd          string              `json:"cmd" bson:"cmd"`
+	Proxy        string              `json:"proxy" bson:"proxy"`
+	Channels     []string            `json:"channels,omitempty" bson:"channels,omitempty"`
+	Provider     *ProviderDefinition `json:"provider,omitempty" bson:"provider,omitempty"`
+	Isolated     bool                `json:"isolated" bson:"isolated"`
+	EnvPath      string              `json:"env_path,omitempty" bson:"env_path,omitempty"`
+	Policy       *entity.TaskPolicy  `json:"policy,omitempty" bson:"policy,omitempty"`
+	Concurrency  int                 `json:"concurrency" bson:"concurrency"`
+	LastUpdateTs time.Time           `json:"last_update_ts" bson:"last_update_ts"`
 }
@@ -1,19 +1,25 @@
 package models
 
 import (
+	"github.com/crawlab-team/plugin-dependency/entity"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"time"
 )
 
 type Task struct {
-	Id        primitive.ObjectID `json:"_id" bson:"_id"`
-	Status    string             `json:"status" bson:"status"`
-	Error     string             `json:"error" bson:"error"`
-	SettingId primitive.ObjectID `json:"setting_id" bson:"setting_id"`
-	Type      string             `json:"type" bson:"type"`
-	NodeId    primitive.ObjectID `json:"node_id" bson:"node_id"`
-	Action    string             `json:"action" bson:"action"`
-	DepNames  []string           `json:"dep_names" bson:"dep_names"`
-	Upgrade   bool               `json:"upgrade" bson:"upgrade"`
-	UpdateTs  time.Time          `json:"update_ts" bson:"update_ts"`
+	Id          primitive.ObjectID   `json:"_id" bson:"_id"`
+	OperationId primitive.ObjectID   `json:"operation_id,omitempty" bson:"operation_id,omitempty"`
+	Status      string               `json:"status" bson:"status"`
+	Error       string               `json:"error" bson:"error"`
+	SettingId   primitive.ObjectID   `json:"setting_id" bson:"setting_id"`
+	Type        string               `json:"type" bson:"type"`
+	NodeId      primitive.ObjectID   `json:"node_id" bson:"node_id"`
+	Action      string               `json:"action" bson:"action"`
+	DepNames    []string             `json:"dep_names" bson:"dep_names"`
+	Packages    []entity.PackageSpec `json:"packages,omitempty" bson:"packages,omitempty"`
+	Upgrade     bool                 `json:"upgrade" bson:"upgrade"`
+	EnvName     string               `json:"env_name,omitempty" bson:"env_name,omitempty"`
+	Policy      entity.TaskPolicy    `json:"policy" bson:"policy"`
+	Attempts    []entity.TaskAttempt `json:"attempts,omitempty" bson:"attempts,omitempty"`
+	UpdateTs    time.Time            `json:"update_ts" bson:"update_ts"`
 }
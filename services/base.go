@@ -1,8 +1,10 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/blang/semver/v4"
 	constants2 "github.com/crawlab-team/crawlab-core/constants"
 	"github.com/crawlab-team/crawlab-core/controllers"
@@ -19,21 +21,48 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	mongo2 "go.mongodb.org/mongo-driver/mongo"
+	"os/exec"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
 type baseService struct {
-	svc        DependencyService
-	parent     *Service
-	api        *gin.Engine
-	chMap      sync.Map
-	s          models.Setting
-	key        string
-	codes      entity.MessageCodes
-	vCache     sync.Map
-	defaultCmd string
+	svc            DependencyService
+	parent         *Service
+	api            *gin.Engine
+	chMap          sync.Map
+	s              models.Setting
+	key            string
+	codes          entity.MessageCodes
+	vCache         sync.Map
+	defaultCmd     string
+	defaultSetting models.Setting
+
+	// package registry, where the injected client takes precedence
+	// over the one created from setting
+	registryClient    RegistryClient
+	newRegistryClient func(s models.Setting) (client RegistryClient)
+
+	// queue of install/uninstall tasks on current node
+	queue *taskQueue
+
+	// manifest files of spider dependencies in order of precedence
+	manifests []string
+}
+
+func (svc *baseService) Init() {
+	svc.api.GET("/"+svc.key, svc.getList)
+	svc.api.POST("/"+svc.key+"/update", svc.update)
+	svc.api.POST("/"+svc.key+"/install", svc.install)
+	svc.api.POST("/"+svc.key+"/uninstall", svc.uninstall)
+
+	// package detail in registry, whose name may contain "/", e.g. "@types/node"
+	if svc.registryClient != nil || svc.newRegistryClient != nil {
+		svc.api.GET("/"+svc.key+"/packages/*name", svc.getPackage)
+	}
 }
 
 func (svc *baseService) Start() {
@@ -51,6 +80,18 @@ func (svc *baseService) Start() {
 	}
 }
 
+func (svc *baseService) GetKey() (key string) {
+	return svc.key
+}
+
+func (svc *baseService) GetMessageCodes() (codes entity.MessageCodes) {
+	return svc.codes
+}
+
+func (svc *baseService) GetDefaultSetting() (s models.Setting) {
+	return svc.defaultSetting
+}
+
 func (svc *baseService) getList(c *gin.Context) {
 	installed, _ := strconv.ParseBool(c.Query("installed"))
 	if installed {
@@ -60,6 +101,74 @@ func (svc *baseService) getList(c *gin.Context) {
 	}
 }
 
+func (svc *baseService) getPackage(c *gin.Context) {
+	// package name
+	name := strings.TrimPrefix(c.Param("name"), "/")
+	if name == "" {
+		controllers.HandleErrorBadRequest(c, errors.New("name is empty"))
+		return
+	}
+
+	// setting
+	if err := svc._getSetting(); err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// package in registry
+	pkg, err := svc._getRegistryClient().GetPackage(name)
+	if err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+	if pkg == nil {
+		controllers.HandleErrorNotFound(c, errors.New(fmt.Sprintf("package %s not found", name)))
+		return
+	}
+
+	// installed versions on nodes, excluding spider dependencies
+	var installed []entity.PackageInstalledVersion
+	pipelines := mongo2.Pipeline{
+		{{
+			"$match",
+			bson.M{
+				"type":      svc.key,
+				"name":      svc._getNameQuery([]string{pkg.Name}),
+				"spider_id": bson.M{"$exists": false},
+				"env_name":  bson.M{"$exists": false},
+			},
+		}},
+		{{
+			"$group",
+			bson.M{
+				"_id":      "$version",
+				"node_ids": bson.M{"$push": "$node_id"},
+			},
+		}},
+		{{
+			"$project",
+			bson.M{
+				"version":  "$_id",
+				"node_ids": "$node_ids",
+			},
+		}},
+	}
+	if err := svc.parent.colD.Aggregate(pipelines, nil).All(&installed); err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// sort in the version scheme of the provider, as releases are sorted
+	sort.SliceStable(installed, func(i, j int) bool {
+		return svc._isLowerVersion(installed[i].Version, installed[j].Version)
+	})
+
+	controllers.HandleSuccessWithData(c, entity.PackageDetail{
+		RegistryPackage: *pkg,
+		Installed:       installed,
+	})
+}
+
 func (svc *baseService) update(c *gin.Context) {
 	if err := svc._update(); err != nil {
 		controllers.HandleErrorInternalServerError(c, err)
@@ -76,12 +185,30 @@ func (svc *baseService) install(c *gin.Context) {
 		return
 	}
 
+	svc._install(c, payload)
+}
+
+func (svc *baseService) _install(c *gin.Context, payload entity.InstallPayload) {
+	// packages with version specs
+	pkgs, err := svc._getPackageSpecs(payload)
+	if err != nil {
+		controllers.HandleErrorBadRequest(c, err)
+		return
+	}
+	names := make([]string, len(pkgs))
+	for i, pkg := range pkgs {
+		names[i] = pkg.Name
+	}
+
 	// setting
 	if err := svc._getSetting(); err != nil {
 		controllers.HandleErrorInternalServerError(c, err)
 		return
 	}
 
+	// timeout and retry policy
+	policy := svc._getTaskPolicy(payload.Policy)
+
 	// nodes
 	query := bson.M{}
 	if payload.Mode == constants.InstallModeAll {
@@ -95,65 +222,111 @@ func (svc *baseService) install(c *gin.Context) {
 		return
 	}
 
-	// wait group
-	wg := sync.WaitGroup{}
-	wg.Add(len(nodes))
+	// operation
+	op := &models.Operation{
+		Id:        primitive.NewObjectID(),
+		Status:    constants.OperationStatusRunning,
+		SettingId: svc.s.Id,
+		Type:      svc.key,
+		Action:    constants.ActionInstall,
+		DepNames:  names,
+		Packages:  pkgs,
+		Upgrade:   payload.Upgrade,
+		UseConfig: payload.UseConfig,
+		SpiderId:  payload.SpiderId,
+		EnvName:   payload.EnvName,
+		Policy:    policy,
+		CreateTs:  time.Now(),
+		UpdateTs:  time.Now(),
+	}
+	for _, n := range nodes {
+		op.NodeIds = append(op.NodeIds, n.Id)
+	}
+	if len(op.NodeIds) == 0 {
+		// no nodes to install on
+		op.Status = constants.OperationStatusFailure
+	}
+	if _, err := svc.parent.colO.Insert(op); err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
 
 	// iterate nodes
 	for _, n := range nodes {
-		// task
-		t := &models.Task{
-			Id:        primitive.NewObjectID(),
-			Status:    constants2.TaskStatusRunning,
-			SettingId: svc.s.Id,
-			Type:      svc.key,
-			NodeId:    n.Id,
-			DepNames:  payload.Names,
-			Action:    constants.ActionInstall,
-			UpdateTs:  time.Now(),
-		}
-		if _, err := svc.parent.colT.Insert(t); err != nil {
+		if err := svc._sendInstall(op, n.Id, n.GetKey()); err != nil {
 			controllers.HandleErrorInternalServerError(c, err)
 			return
 		}
+	}
 
-		// params
-		params := &entity.InstallParams{
-			TaskId:    t.Id,
-			Upgrade:   payload.Upgrade,
-			Names:     payload.Names,
-			Proxy:     svc.s.Proxy,
-			Cmd:       svc._getCmd(),
-			UseConfig: payload.UseConfig,
-			SpiderId:  payload.SpiderId,
-		}
+	controllers.HandleSuccessWithData(c, op)
+}
 
-		// message data
-		data, _ := json.Marshal(params)
-		msgDataObj := &entity.MessageData{
-			Code: svc.codes.Install,
-			Data: data,
-		}
-		msgData, _ := json.Marshal(msgDataObj)
+// _sendInstall creates install task of the operation on the node, and
+// sends it to the node
+func (svc *baseService) _sendInstall(op *models.Operation, nodeId primitive.ObjectID, nodeKey string) (err error) {
+	// task
+	t := &models.Task{
+		Id:          primitive.NewObjectID(),
+		OperationId: op.Id,
+		Status:      constants.TaskStatusQueued,
+		SettingId:   svc.s.Id,
+		Type:        svc.key,
+		NodeId:      nodeId,
+		DepNames:    op.DepNames,
+		Packages:    op.Packages,
+		Upgrade:     op.Upgrade,
+		Action:      constants.ActionInstall,
+		EnvName:     op.EnvName,
+		Policy:      op.Policy,
+		UpdateTs:    time.Now(),
+	}
+	if _, err := svc.parent.colT.Insert(t); err != nil {
+		return trace.TraceError(err)
+	}
 
-		// stream message
-		msg := &grpc.StreamMessage{
-			Code:    grpc.StreamMessageCode_SEND,
-			NodeKey: svc.parent.currentNode.GetKey(),
-			From:    "plugin:" + svc.parent.currentNode.GetKey(),
-			To:      "plugin:" + n.GetKey(),
-			Data:    msgData,
-		}
+	// params
+	params := &entity.InstallParams{
+		TaskId:      t.Id,
+		Upgrade:     op.Upgrade,
+		Names:       op.DepNames,
+		Packages:    op.Packages,
+		Proxy:       svc.s.Proxy,
+		Cmd:         svc._getCmd(),
+		UseConfig:   op.UseConfig,
+		SpiderId:    op.SpiderId,
+		Channels:    svc.s.Channels,
+		EnvName:     op.EnvName,
+		Isolated:    svc._isIsolated(op.SpiderId),
+		EnvPath:     svc.s.EnvPath,
+		Local:       svc._isLocal(op.UseConfig, op.SpiderId),
+		Policy:      op.Policy,
+		Concurrency: svc.s.Concurrency,
+	}
 
-		// send message
-		if err := svc.parent.msgStream.Send(msg); err != nil {
-			trace.PrintError(err)
-			wg.Done()
-			return
-		}
+	// message data
+	data, _ := json.Marshal(params)
+	msgDataObj := &entity.MessageData{
+		Code: svc.codes.Install,
+		Data: data,
 	}
+	msgData, _ := json.Marshal(msgDataObj)
 
-	controllers.HandleSuccess(c)
+	// stream message
+	msg := &grpc.StreamMessage{
+		Code:    grpc.StreamMessageCode_SEND,
+		NodeKey: svc.parent.currentNode.GetKey(),
+		From:    "plugin:" + svc.parent.currentNode.GetKey(),
+		To:      "plugin:" + nodeKey,
+		Data:    msgData,
+	}
+
+	// send message
+	if err := svc.parent.msgStream.Send(msg); err != nil {
+		return trace.TraceError(err)
+	}
+
+	return nil
 }
 
 func (svc *baseService) uninstall(c *gin.Context) {
@@ -164,12 +337,19 @@ func (svc *baseService) uninstall(c *gin.Context) {
 		return
 	}
 
+	svc._uninstall(c, payload)
+}
+
+func (svc *baseService) _uninstall(c *gin.Context, payload entity.UninstallPayload) {
 	// setting
 	if err := svc._getSetting(); err != nil {
 		controllers.HandleErrorInternalServerError(c, err)
 		return
 	}
 
+	// timeout and retry policy
+	policy := svc._getTaskPolicy(payload.Policy)
+
 	// node model service
 	nodeModelSvc, err := svc.parent.GetModelService().NewBaseServiceDelegate(interfaces.ModelIdNode)
 	if err != nil {
@@ -177,11 +357,15 @@ func (svc *baseService) uninstall(c *gin.Context) {
 		return
 	}
 
-	// dependencies
+	// dependencies, which are in workspace of spider if local
 	var deps []models.Dependency
+	isolated := svc._isIsolated(payload.SpiderId)
+	local := svc._isLocal(true, payload.SpiderId)
 	query := bson.M{
-		"type": svc.key,
-		"name": bson.M{"$in": payload.Names},
+		"type":      svc.key,
+		"name":      bson.M{"$in": payload.Names},
+		"spider_id": svc._getSpiderIdQuery(isolated || local, payload.SpiderId),
+		"env_name":  svc._getEnvNameQuery(svc._getEnvName(payload.EnvName)),
 	}
 	if err := svc.parent.colD.Find(query, nil).All(&deps); err != nil {
 		controllers.HandleErrorInternalServerError(c, err)
@@ -218,64 +402,207 @@ func (svc *baseService) uninstall(c *gin.Context) {
 		depNamesNodeMap[n] = append(depNamesNodeMap[n], d.Name)
 	}
 
+	// operation
+	op := &models.Operation{
+		Id:        primitive.NewObjectID(),
+		Status:    constants.OperationStatusRunning,
+		SettingId: svc.s.Id,
+		Type:      svc.key,
+		Action:    constants.ActionUninstall,
+		DepNames:  payload.Names,
+		SpiderId:  payload.SpiderId,
+		EnvName:   payload.EnvName,
+		Policy:    policy,
+		CreateTs:  time.Now(),
+		UpdateTs:  time.Now(),
+	}
+	for n := range depNamesNodeMap {
+		op.NodeIds = append(op.NodeIds, n.GetId())
+	}
+	if len(op.NodeIds) == 0 {
+		// no active nodes with the dependencies to uninstall
+		op.Status = constants.OperationStatusFailure
+	}
+	if _, err := svc.parent.colO.Insert(op); err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
 	// iterate map
 	for n, depNames := range depNamesNodeMap {
-		// task
-		t := &models.Task{
-			Id:        primitive.NewObjectID(),
-			Status:    constants2.TaskStatusRunning,
-			SettingId: svc.s.Id,
-			Type:      svc.key,
-			NodeId:    n.GetId(),
-			DepNames:  depNames,
-			Action:    constants.ActionUninstall,
-			UpdateTs:  time.Now(),
-		}
-		if _, err := svc.parent.colT.Insert(t); err != nil {
+		if err := svc._sendUninstall(op, n.GetId(), n.GetKey(), depNames); err != nil {
 			controllers.HandleErrorInternalServerError(c, err)
 			return
 		}
+	}
 
-		// params
-		params := &entity.UninstallParams{
-			TaskId: t.Id,
-			Cmd:    svc._getCmd(),
-			Names:  depNames,
-		}
+	controllers.HandleSuccessWithData(c, op)
+}
+
+// _sendUninstall creates uninstall task of the operation on the node, and
+// sends it to the node
+func (svc *baseService) _sendUninstall(op *models.Operation, nodeId primitive.ObjectID, nodeKey string, depNames []string) (err error) {
+	// task
+	t := &models.Task{
+		Id:          primitive.NewObjectID(),
+		OperationId: op.Id,
+		Status:      constants.TaskStatusQueued,
+		SettingId:   svc.s.Id,
+		Type:        svc.key,
+		NodeId:      nodeId,
+		DepNames:    depNames,
+		Action:      constants.ActionUninstall,
+		EnvName:     op.EnvName,
+		Policy:      op.Policy,
+		UpdateTs:    time.Now(),
+	}
+	if _, err := svc.parent.colT.Insert(t); err != nil {
+		return trace.TraceError(err)
+	}
+
+	// params
+	params := &entity.UninstallParams{
+		TaskId:      t.Id,
+		Cmd:         svc._getCmd(),
+		Names:       depNames,
+		EnvName:     op.EnvName,
+		SpiderId:    op.SpiderId,
+		Isolated:    svc._isIsolated(op.SpiderId),
+		EnvPath:     svc.s.EnvPath,
+		Local:       svc._isLocal(true, op.SpiderId),
+		Policy:      op.Policy,
+		Concurrency: svc.s.Concurrency,
+	}
 
-		// data
-		data, err := json.Marshal(params)
+	// data
+	data, err := json.Marshal(params)
+	if err != nil {
+		return trace.TraceError(err)
+	}
+
+	// message data
+	msgDataObj := &entity.MessageData{
+		Code: svc.codes.Uninstall,
+		Data: data,
+	}
+	msgData, err := json.Marshal(msgDataObj)
+	if err != nil {
+		return trace.TraceError(err)
+	}
+
+	// stream message
+	msg := &grpc.StreamMessage{
+		Code:    grpc.StreamMessageCode_SEND,
+		NodeKey: svc.parent.currentNode.GetKey(),
+		From:    "plugin:" + svc.parent.currentNode.GetKey(),
+		To:      "plugin:" + nodeKey,
+		Data:    msgData,
+	}
+
+	// send message
+	if err := svc.parent.msgStream.Send(msg); err != nil {
+		return trace.TraceError(err)
+	}
+
+	return nil
+}
+
+// _retryOperation sends the tasks of operation again to the nodes, where
+// dependencies to uninstall are looked up again if the task has not been
+// sent to the node
+func (svc *baseService) _retryOperation(op *models.Operation, nodes []entity.OperationNode) (err error) {
+	// setting
+	if err := svc._getSetting(); err != nil {
+		return err
+	}
+
+	// node model service
+	nodeModelSvc, err := svc.parent.GetModelService().NewBaseServiceDelegate(interfaces.ModelIdNode)
+	if err != nil {
+		return trace.TraceError(err)
+	}
+
+	// iterate nodes
+	for _, on := range nodes {
+		// node
+		doc, err := nodeModelSvc.GetById(on.NodeId)
 		if err != nil {
-			controllers.HandleErrorInternalServerError(c, err)
-			return
+			return trace.TraceError(err)
+		}
+		n, ok := doc.(interfaces.Node)
+		if !ok {
+			return trace.TraceError(errors.New("invalid type"))
 		}
 
-		// message data
-		msgDataObj := &entity.MessageData{
-			Code: svc.codes.Uninstall,
-			Data: data,
+		// send task
+		switch op.Action {
+		case constants.ActionInstall:
+			err = svc._sendInstall(op, n.GetId(), n.GetKey())
+		case constants.ActionUninstall:
+			depNames := on.DepNames
+			if on.TaskId.IsZero() {
+				depNames, err = svc._getNodeDepNames(op, n.GetId())
+				if err != nil {
+					return err
+				}
+			}
+			if len(depNames) == 0 {
+				// nothing to uninstall on the node, which is recorded
+				// as finished so that it is no longer retried
+				err = svc._insertFinishedTask(op, n.GetId())
+			} else {
+				err = svc._sendUninstall(op, n.GetId(), n.GetKey(), depNames)
+			}
+		default:
+			err = trace.TraceError(errors.New(fmt.Sprintf("invalid action: %s", op.Action)))
 		}
-		msgData, err := json.Marshal(msgDataObj)
 		if err != nil {
-			controllers.HandleErrorInternalServerError(c, err)
-			return
+			return err
 		}
+	}
 
-		// stream message
-		msg := &grpc.StreamMessage{
-			Code:    grpc.StreamMessageCode_SEND,
-			NodeKey: svc.parent.currentNode.GetKey(),
-			From:    "plugin:" + svc.parent.currentNode.GetKey(),
-			To:      "plugin:" + n.GetKey(),
-			Data:    msgData,
-		}
+	return nil
+}
 
-		// send message
-		if err := svc.parent.msgStream.Send(msg); err != nil {
-			controllers.HandleErrorInternalServerError(c, err)
-			return
-		}
+// _insertFinishedTask records a finished task of the operation on the node
+// without sending it to the node
+func (svc *baseService) _insertFinishedTask(op *models.Operation, nodeId primitive.ObjectID) (err error) {
+	t := &models.Task{
+		Id:          primitive.NewObjectID(),
+		OperationId: op.Id,
+		Status:      constants2.TaskStatusFinished,
+		SettingId:   svc.s.Id,
+		Type:        svc.key,
+		NodeId:      nodeId,
+		Action:      op.Action,
+		EnvName:     op.EnvName,
+		Policy:      op.Policy,
+		UpdateTs:    time.Now(),
+	}
+	if _, err := svc.parent.colT.Insert(t); err != nil {
+		return trace.TraceError(err)
+	}
+	return nil
+}
+
+// _getNodeDepNames returns names of dependencies of the operation which are
+// installed on the node, in the same scope as they are uninstalled
+func (svc *baseService) _getNodeDepNames(op *models.Operation, nodeId primitive.ObjectID) (depNames []string, err error) {
+	var deps []models.Dependency
+	query := bson.M{
+		"type":      svc.key,
+		"node_id":   nodeId,
+		"name":      bson.M{"$in": op.DepNames},
+		"spider_id": svc._getSpiderIdQuery(svc._isIsolated(op.SpiderId) || svc._isLocal(true, op.SpiderId), op.SpiderId),
+		"env_name":  svc._getEnvNameQuery(svc._getEnvName(op.EnvName)),
+	}
+	if err := svc.parent.colD.Find(query, nil).All(&deps); err != nil {
+		return nil, trace.TraceError(err)
+	}
+	for _, d := range deps {
+		depNames = append(depNames, d.Name)
 	}
+	return depNames, nil
 }
 
 func (svc *baseService) _getInstalledList(c *gin.Context) {
@@ -286,6 +613,8 @@ func (svc *baseService) _getInstalledList(c *gin.Context) {
 	// query
 	query := bson.M{}
 	query["type"] = svc.key
+	query["spider_id"] = svc._getSpiderIdQuery(false, primitive.NilObjectID)
+	query["env_name"] = svc._getEnvNameQuery(svc._getEnvName(c.Query("env_name")))
 	if searchQuery != "" {
 		query["name"] = primitive.Regex{
 			Pattern: searchQuery,
@@ -343,21 +672,13 @@ func (svc *baseService) _getInstalledList(c *gin.Context) {
 			continue
 		}
 
-		// latest version
-		lv, err := semver.Make(dr.LatestVersion)
-		if err != nil {
-			continue
-		}
-
 		for _, v := range dr.Versions {
-			// current version
-			cv, err := semver.Make(v)
+			// compare with the latest version
+			res, err := svc._compareVersions(dr.LatestVersion, v)
 			if err != nil {
 				continue
 			}
-
-			// compare with the latest version
-			if lv.Compare(cv) > 0 {
+			if res > 0 {
 				depsResults[i].Upgradable = true
 				break
 			}
@@ -459,15 +780,33 @@ func (svc *baseService) updateDependencyList(msg *grpc.StreamMessage, msgData en
 		return
 	}
 
-	// installed dependencies
-	deps, err := svc.svc.GetDependencies(params)
+	// installed dependencies, which are in workspace of spider if local
+	var deps []models.Dependency
+	var err error
+	if localSvc, ok := svc.svc.(LocalDependencyService); ok && params.Local {
+		deps, err = localSvc.GetLocalDependencies(params)
+	} else {
+		deps, err = svc.svc.GetDependencies(params)
+	}
 	if err != nil {
 		trace.PrintError(err)
 		return
 	}
 
 	// data
-	data, err := json.Marshal(deps)
+	depsData, err := json.Marshal(deps)
+	if err != nil {
+		trace.PrintError(err)
+		return
+	}
+	saveParams := entity.SaveParams{
+		Dependencies: depsData,
+	}
+	if params.Isolated || params.Local {
+		saveParams.SpiderId = params.SpiderId
+	}
+	saveParams.EnvName = svc._getEnvName(params.EnvName)
+	data, err := json.Marshal(saveParams)
 	if err != nil {
 		trace.PrintError(err)
 		return
@@ -505,13 +844,25 @@ func (svc *baseService) _saveDependencyList(msg *grpc.StreamMessage, msgData ent
 	ch := svc._getDefaultCh()
 
 	// dependencies
+	var saveParams entity.SaveParams
+	if err := json.Unmarshal(msgData.Data, &saveParams); err != nil {
+		trace.PrintError(err)
+		ch <- true
+		return
+	}
 	var deps []models.Dependency
-	if err := json.Unmarshal(msgData.Data, &deps); err != nil {
+	if err := json.Unmarshal(saveParams.Dependencies, &deps); err != nil {
 		trace.PrintError(err)
 		ch <- true
 		return
 	}
 
+	// isolated environment of spider
+	spiderIdQuery := svc._getSpiderIdQuery(!saveParams.SpiderId.IsZero(), saveParams.SpiderId)
+
+	// named environment of provider
+	envNameQuery := svc._getEnvNameQuery(saveParams.EnvName)
+
 	// installed dependency names
 	var depNames []string
 	for _, d := range deps {
@@ -544,17 +895,21 @@ func (svc *baseService) _saveDependencyList(msg *grpc.StreamMessage, msgData ent
 	err = mongo.RunTransaction(func(ctx mongo2.SessionContext) (err error) {
 		// remove non-existing dependencies
 		if err := svc.parent.colD.Delete(bson.M{
-			"type":    svc.key,
-			"node_id": n.GetId(),
-			"name":    bson.M{"$nin": depNames},
+			"type":      svc.key,
+			"node_id":   n.GetId(),
+			"spider_id": spiderIdQuery,
+			"env_name":  envNameQuery,
+			"name":      bson.M{"$nin": depNames},
 		}); err != nil {
 			return err
 		}
 
 		// existing dependencies
 		query := bson.M{
-			"type":    svc.key,
-			"node_id": n.GetId(),
+			"type":      svc.key,
+			"node_id":   n.GetId(),
+			"spider_id": spiderIdQuery,
+			"env_name":  envNameQuery,
 		}
 		var depsDb []models.Dependency
 		if err := svc.parent.colD.Find(query, nil).All(&depsDb); err != nil {
@@ -572,10 +927,37 @@ func (svc *baseService) _saveDependencyList(msg *grpc.StreamMessage, msgData ent
 				d.Id = primitive.NewObjectID()
 				d.Type = svc.key
 				d.NodeId = n.GetId()
+				d.SpiderId = saveParams.SpiderId
+				d.EnvName = saveParams.EnvName
 				depsNew = append(depsNew, d)
 			}
 		}
 
+		// update versions of existing dependencies, where the latest
+		// version is kept unless reported by the node
+		for _, d := range deps {
+			dDb, ok := depsDbMap[d.Name]
+			if !ok {
+				continue
+			}
+			set := bson.M{}
+			if d.Version != dDb.Version {
+				set["version"] = d.Version
+			}
+			if d.LatestVersion != "" && d.LatestVersion != dDb.LatestVersion {
+				set["latest_version"] = d.LatestVersion
+			}
+			if d.Platform != "" && d.Platform != dDb.Platform {
+				set["platform"] = d.Platform
+			}
+			if len(set) == 0 {
+				continue
+			}
+			if err := svc.parent.colD.UpdateId(dDb.Id, bson.M{"$set": set}); err != nil {
+				return err
+			}
+		}
+
 		// skip if no new dependencies
 		if len(depsNew) == 0 {
 			return
@@ -606,8 +988,17 @@ func (svc *baseService) installDependency(msg *grpc.StreamMessage, msgData entit
 		return
 	}
 
-	// install
-	if err := svc.svc.InstallDependencies(params); err != nil {
+	// install, which can be cancelled or retried
+	cancelled, err := svc.parent._runTask(params.TaskId, svc.queue, params.Concurrency, params.Policy, func() (err error) {
+		return svc.svc.InstallDependencies(params)
+	})
+	if cancelled {
+		// dependencies may have been changed partially
+		svc.parent._sendTaskStatus(params.TaskId, constants2.TaskStatusCancelled, nil)
+		svc.updateDependencyList(msg, msgData)
+		return
+	}
+	if err != nil {
 		trace.PrintError(err)
 		svc.parent._sendTaskStatus(params.TaskId, constants2.TaskStatusError, err)
 		return
@@ -629,8 +1020,17 @@ func (svc *baseService) uninstallDependency(msg *grpc.StreamMessage, msgData ent
 		return
 	}
 
-	// uninstall
-	if err := svc.svc.UninstallDependencies(params); err != nil {
+	// uninstall, which can be cancelled or retried
+	cancelled, err := svc.parent._runTask(params.TaskId, svc.queue, params.Concurrency, params.Policy, func() (err error) {
+		return svc.svc.UninstallDependencies(params)
+	})
+	if cancelled {
+		// dependencies may have been changed partially
+		svc.parent._sendTaskStatus(params.TaskId, constants2.TaskStatusCancelled, nil)
+		svc.updateDependencyList(msg, msgData)
+		return
+	}
+	if err != nil {
 		trace.PrintError(err)
 		svc.parent._sendTaskStatus(params.TaskId, constants2.TaskStatusError, err)
 		return
@@ -647,6 +1047,7 @@ func (svc *baseService) _updateDependenciesLatestVersion() {
 	// dependencies
 	var deps []models.Dependency
 	query := bson.M{
+		"type": svc.key,
 		"latest_version": bson.M{
 			"$exists": false,
 		},
@@ -666,8 +1067,14 @@ func (svc *baseService) _updateDependencyLatestVersion(dep models.Dependency) {
 	// version
 	var v string
 
+	// cache key, where latest versions may differ by platform
+	key := dep.Name
+	if dep.Platform != "" {
+		key = dep.Platform + "/" + dep.Name
+	}
+
 	// attempt to load from cache
-	r, ok := svc.vCache.Load(dep.Name)
+	r, ok := svc.vCache.Load(key)
 	if ok {
 		// exists in cache
 		v, _ = r.(string)
@@ -681,7 +1088,7 @@ func (svc *baseService) _updateDependencyLatestVersion(dep models.Dependency) {
 		}
 
 		// store in cache
-		svc.vCache.Store(dep.Name, v)
+		svc.vCache.Store(key, v)
 	}
 
 	// update
@@ -696,6 +1103,163 @@ func (svc *baseService) _updateDependencyLatestVersion(dep models.Dependency) {
 	}
 }
 
+func (svc *baseService) _getDependencyResultsMap(depNames []string) (depsResultsMap map[string]entity.DependencyResult, err error) {
+	return svc._getSpiderDependencyResultsMap(primitive.NilObjectID, depNames)
+}
+
+// _getSpiderDependencyResultsMap returns results of dependencies installed in
+// the isolated environment of spider, or globally if spider id is empty
+func (svc *baseService) _getSpiderDependencyResultsMap(spiderId primitive.ObjectID, depNames []string) (depsResultsMap map[string]entity.DependencyResult, err error) {
+	// dependencies in db
+	var depsResults []entity.DependencyResult
+	pipelines := mongo2.Pipeline{
+		{{
+			"$match",
+			bson.M{
+				"type":      svc.key,
+				"spider_id": svc._getSpiderIdQuery(!spiderId.IsZero(), spiderId),
+				"env_name":  bson.M{"$exists": false},
+				"name":      svc._getNameQuery(depNames),
+			},
+		}},
+		{{
+			"$group",
+			bson.M{
+				"_id": "$name",
+				"node_ids": bson.M{
+					"$push": "$node_id",
+				},
+				"versions": bson.M{
+					"$addToSet": "$version",
+				},
+			},
+		}},
+		{{
+			"$project",
+			bson.M{
+				"name":     "$_id",
+				"node_ids": "$node_ids",
+				"versions": "$versions",
+			},
+		}},
+	}
+	if err := svc.parent.colD.Aggregate(pipelines, nil).All(&depsResults); err != nil {
+		return nil, err
+	}
+
+	// results of normalized names, where results of names which are the
+	// same if normalized are merged, e.g. "Django" and "django"
+	normalizedResultsMap := map[string]entity.DependencyResult{}
+	for _, dr := range depsResults {
+		name := svc._normalizeName(dr.Name)
+		if r, ok := normalizedResultsMap[name]; ok {
+			r.NodeIds = append(r.NodeIds, dr.NodeIds...)
+			versions := map[string]bool{}
+			for _, v := range r.Versions {
+				versions[v] = true
+			}
+			for _, v := range dr.Versions {
+				if !versions[v] {
+					r.Versions = append(r.Versions, v)
+				}
+			}
+			dr = r
+		}
+		normalizedResultsMap[name] = dr
+	}
+
+	// dependencies map of the given names
+	depsResultsMap = map[string]entity.DependencyResult{}
+	for _, name := range depNames {
+		if dr, ok := normalizedResultsMap[svc._normalizeName(name)]; ok {
+			depsResultsMap[name] = dr
+		}
+	}
+
+	return depsResultsMap, nil
+}
+
+// _normalizeName returns the name normalized by the dependency provider,
+// or the name itself if names are not normalized
+func (svc *baseService) _normalizeName(name string) (res string) {
+	normalizedNameSvc, ok := svc.svc.(NormalizedNameDependencyService)
+	if !ok {
+		return name
+	}
+	return normalizedNameSvc.NormalizeName(name)
+}
+
+// _getNameQuery returns the query of dependencies of the names, which also
+// matches names that are the same if normalized by the dependency provider
+func (svc *baseService) _getNameQuery(names []string) (query bson.M) {
+	normalizedNameSvc, ok := svc.svc.(NormalizedNameDependencyService)
+	if !ok {
+		return bson.M{"$in": names}
+	}
+	var patterns []interface{}
+	for _, name := range names {
+		patterns = append(patterns, primitive.Regex{Pattern: normalizedNameSvc.GetNamePattern(name), Options: "i"})
+	}
+	return bson.M{"$in": patterns}
+}
+
+// _runCmd runs the install/uninstall command in its own process group and
+// streams its output to the task logs, where the command is killed with its
+// process group if the task is cancelled or timed out
+func (svc *baseService) _runCmd(taskId primitive.ObjectID, cmd *exec.Cmd) (err error) {
+	// context of task attempt
+	ctx := context.Background()
+	proc, ok := svc.parent._getTaskProcess(taskId)
+	if ok {
+		ctx = proc.getContext()
+		if ctx.Err() != nil {
+			return trace.TraceError(proc.getError(ctx, ctx.Err()))
+		}
+	}
+	cmd = withCmdContext(ctx, cmd)
+
+	// logging
+	logWg := svc.parent._configureLogging(taskId, cmd)
+
+	// process group
+	setCmdProcessGroup(cmd)
+
+	// start
+	if err := cmd.Start(); err != nil {
+		if ok {
+			proc.setExitCode(err)
+		}
+		return trace.TraceError(err)
+	}
+
+	// kill process group when done, as only the command itself is killed
+	// by context
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = killCmdProcessGroup(cmd)
+		case <-done:
+		}
+	}()
+
+	// flush logs before waiting, as output pipes are closed by wait
+	logWg.Wait()
+
+	// wait
+	err = cmd.Wait()
+	if ok {
+		proc.setExitCode(err)
+		err = proc.getError(ctx, err)
+	}
+	if err != nil {
+		return trace.TraceError(err)
+	}
+
+	return nil
+}
+
 func (svc *baseService) _getDefaultCh() (ch chan bool) {
 	return svc._getCh(svc.parent.currentNode.GetKey())
 }
@@ -727,6 +1291,178 @@ func (svc *baseService) _getCmd() (cmd string) {
 	return svc.s.Cmd
 }
 
+// _isIsolated returns true if dependencies of the spider are installed in its
+// isolated environment, which requires the provider to support it
+func (svc *baseService) _isIsolated(spiderId primitive.ObjectID) (res bool) {
+	if !svc.s.Isolated || spiderId.IsZero() {
+		return false
+	}
+	_, ok := svc.svc.(IsolatedDependencyService)
+	return ok
+}
+
+// _getPackageSpecs returns packages of names and version specs in payload,
+// where specs are validated by the provider
+func (svc *baseService) _getPackageSpecs(payload entity.InstallPayload) (pkgs []entity.PackageSpec, err error) {
+	// packages, where the version spec overrides the bare name
+	indexes := map[string]int{}
+	for _, name := range payload.Names {
+		pkgs = append(pkgs, entity.PackageSpec{Name: name})
+		indexes[name] = len(pkgs) - 1
+	}
+	for _, pkg := range payload.Packages {
+		pkg.Name = strings.TrimSpace(pkg.Name)
+		pkg.Spec = strings.TrimSpace(pkg.Spec)
+		if pkg.Name == "" {
+			return nil, trace.TraceError(errors.New("package name is empty"))
+		}
+		if i, ok := indexes[pkg.Name]; ok {
+			pkgs[i] = pkg
+			continue
+		}
+		pkgs = append(pkgs, pkg)
+		indexes[pkg.Name] = len(pkgs) - 1
+	}
+
+	// validate version specs
+	for _, pkg := range pkgs {
+		if pkg.Spec == "" {
+			continue
+		}
+		specSvc, ok := svc.svc.(VersionSpecDependencyService)
+		if !ok {
+			return nil, trace.TraceError(errors.New(fmt.Sprintf("version specs are not supported by %s", svc.key)))
+		}
+		if err := specSvc.ValidateVersionSpec(pkg.Spec); err != nil {
+			return nil, trace.TraceError(errors.New(fmt.Sprintf("invalid version spec of %s: %s", pkg.Name, err.Error())))
+		}
+	}
+
+	return pkgs, nil
+}
+
+// _getTaskPolicy returns the timeout and retry policy of task in setting,
+// where non-zero fields of the override in request take precedence
+func (svc *baseService) _getTaskPolicy(override *entity.TaskPolicy) (policy entity.TaskPolicy) {
+	if svc.s.Policy != nil {
+		policy = *svc.s.Policy
+	}
+	if override == nil {
+		return policy
+	}
+	if override.Timeout > 0 {
+		policy.Timeout = override.Timeout
+	}
+	if override.MaxAttempts > 0 {
+		policy.MaxAttempts = override.MaxAttempts
+	}
+	if override.Backoff > 0 {
+		policy.Backoff = override.Backoff
+	}
+	if override.MaxBackoff > 0 {
+		policy.MaxBackoff = override.MaxBackoff
+	}
+	if len(override.RetryExitCodes) > 0 {
+		policy.RetryExitCodes = override.RetryExitCodes
+	}
+	if len(override.RetryPatterns) > 0 {
+		policy.RetryPatterns = override.RetryPatterns
+	}
+	if override.RetryOnTimeout {
+		policy.RetryOnTimeout = true
+	}
+	return policy
+}
+
+// _getManifests returns manifest files of spider dependencies in order of
+// precedence, where the manifest of custom provider is defined in setting
+func (svc *baseService) _getManifests() (manifests []string) {
+	if svc.s.Provider != nil && svc.s.Provider.Manifest != "" {
+		return []string{svc.s.Provider.Manifest}
+	}
+	return svc.manifests
+}
+
+// _getRegistryClient returns the client of package registry, which is
+// nil if the provider does not look up packages in registry
+func (svc *baseService) _getRegistryClient() (client RegistryClient) {
+	if svc.registryClient != nil {
+		return svc.registryClient
+	}
+	if svc.newRegistryClient != nil {
+		return svc.newRegistryClient(svc.s)
+	}
+	return nil
+}
+
+// _compareVersions compares versions in the scheme of the provider, which
+// are semantic versions by default
+func (svc *baseService) _compareVersions(v1, v2 string) (res int, err error) {
+	if cmpSvc, ok := svc.svc.(ComparableDependencyService); ok {
+		return cmpSvc.CompareVersions(v1, v2)
+	}
+	sv1, err := semver.Make(v1)
+	if err != nil {
+		return 0, err
+	}
+	sv2, err := semver.Make(v2)
+	if err != nil {
+		return 0, err
+	}
+	return sv1.Compare(sv2), nil
+}
+
+// _isLowerVersion returns true if v1 is lower than v2 in the scheme of the
+// provider, where invalid versions are lower than valid ones
+func (svc *baseService) _isLowerVersion(v1, v2 string) (res bool) {
+	if res, err := svc._compareVersions(v1, v2); err == nil {
+		return res < 0
+	}
+	_, err1 := svc._compareVersions(v1, v1)
+	_, err2 := svc._compareVersions(v2, v2)
+	if err1 != nil && err2 != nil {
+		return v1 < v2
+	}
+	return err1 != nil
+}
+
+// _isLocal returns true if dependencies of the spider are installed by config
+// into its workspace, which requires the provider to support it
+func (svc *baseService) _isLocal(useConfig bool, spiderId primitive.ObjectID) (res bool) {
+	if !useConfig || spiderId.IsZero() {
+		return false
+	}
+	_, ok := svc.svc.(LocalDependencyService)
+	return ok
+}
+
+// _getSpiderIdQuery returns query of spider id of dependencies, which
+// matches global dependencies if not isolated
+func (svc *baseService) _getSpiderIdQuery(isolated bool, spiderId primitive.ObjectID) (query interface{}) {
+	if !isolated {
+		return bson.M{"$exists": false}
+	}
+	return spiderId
+}
+
+// _getEnvName returns the named environment, which is empty if the
+// provider does not support named environments
+func (svc *baseService) _getEnvName(envName string) (res string) {
+	if _, ok := svc.svc.(NamedEnvDependencyService); !ok {
+		return ""
+	}
+	return envName
+}
+
+// _getEnvNameQuery returns query of env name of dependencies, which
+// matches dependencies of the default environment if empty
+func (svc *baseService) _getEnvNameQuery(envName string) (query interface{}) {
+	if envName == "" {
+		return bson.M{"$exists": false}
+	}
+	return envName
+}
+
 func (svc *baseService) _getInstallWorkspacePath(params entity.InstallParams) (workspacePath string, err error) {
 	// spider fs service
 	fsSvc, err := fs.NewSpiderFsService(params.SpiderId)
@@ -742,13 +1478,19 @@ func (svc *baseService) _getInstallWorkspacePath(params entity.InstallParams) (w
 	return fsSvc.GetWorkspacePath(), nil
 }
 
-func newBaseService(svc DependencyService, parent *Service, key string, codes entity.MessageCodes) (res *baseService) {
+func newBaseService(svc DependencyService, parent *Service, key string, codes entity.MessageCodes, defaultSetting models.Setting) (res *baseService) {
+	// default setting
+	defaultSetting.Key = key
+
 	return &baseService{
-		svc:    svc,
-		parent: parent,
-		api:    parent.GetApi(),
-		chMap:  sync.Map{},
-		key:    key,
-		codes:  codes,
+		svc:            svc,
+		parent:         parent,
+		api:            parent.GetApi(),
+		chMap:          sync.Map{},
+		key:            key,
+		codes:          codes,
+		defaultCmd:     defaultSetting.Cmd,
+		defaultSetting: defaultSetting,
+		queue:          newTaskQueue(),
 	}
 }
@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package services
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setCmdProcessGroup starts the command in a new process group, so that
+// child processes such as compilers can be killed with it
+func setCmdProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killCmdProcessGroup kills the process group of the command
+func killCmdProcessGroup(cmd *exec.Cmd) (err error) {
+	if cmd.Process == nil {
+		return nil
+	}
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
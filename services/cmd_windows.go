@@ -0,0 +1,20 @@
+//go:build windows
+// +build windows
+
+package services
+
+import (
+	"os/exec"
+)
+
+// setCmdProcessGroup is a no-op, as process groups are not supported
+func setCmdProcessGroup(cmd *exec.Cmd) {
+}
+
+// killCmdProcessGroup kills the process of the command only
+func killCmdProcessGroup(cmd *exec.Cmd) (err error) {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
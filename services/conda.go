@@ -0,0 +1,500 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/crawlab-team/crawlab-core/controllers"
+	"github.com/crawlab-team/go-trace"
+	"github.com/crawlab-team/plugin-dependency/constants"
+	"github.com/crawlab-team/plugin-dependency/entity"
+	"github.com/crawlab-team/plugin-dependency/models"
+	"github.com/gin-gonic/gin"
+	"github.com/imroc/req"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+var condaMatchSpecPattern = regexp.MustCompile(`^(?:\S+::)?([A-Za-z0-9_][A-Za-z0-9_.-]*)\s*(.*)$`)
+
+type CondaService struct {
+	*baseService
+	repodataCache sync.Map
+}
+
+func (svc *CondaService) GetRepoList(c *gin.Context) {
+	// query
+	query := c.Query("query")
+	pagination := controllers.MustGetPagination(c)
+
+	// validate
+	if query == "" {
+		controllers.HandleErrorBadRequest(c, errors.New("empty query"))
+		return
+	}
+
+	// setting
+	if err := svc._getSetting(); err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// request session
+	reqSession := req.New()
+
+	// set timeout
+	reqSession.SetTimeout(15 * time.Second)
+
+	// request url
+	requestUrl := fmt.Sprintf("%s/search?name=%s", svc._getApiUrl(), url.QueryEscape(query))
+
+	// perform request
+	res, err := reqSession.Get(requestUrl)
+	if err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// response
+	var packages []entity.AnacondaPackage
+	if err := res.ToJSON(&packages); err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// channels
+	channelsMap := map[string]bool{}
+	for _, ch := range svc._getChannels(svc.s.Channels) {
+		channelsMap[ch] = true
+	}
+
+	// results of configured channels, de-duplicated by name
+	var results []models.Dependency
+	resultsMap := map[string]bool{}
+	for _, p := range packages {
+		if !channelsMap[p.Owner] || resultsMap[p.Name] {
+			continue
+		}
+		resultsMap[p.Name] = true
+		results = append(results, models.Dependency{
+			Name:          p.Name,
+			LatestVersion: svc._getPackageLatestVersion(p),
+			Description:   p.Summary,
+		})
+	}
+
+	// empty results
+	total := len(results)
+	if total == 0 {
+		controllers.HandleSuccess(c)
+		return
+	}
+
+	// paginate
+	start := (pagination.Page - 1) * pagination.Size
+	if start > total {
+		start = total
+	}
+	end := start + pagination.Size
+	if end > total {
+		end = total
+	}
+	deps := results[start:end]
+
+	// dependency names
+	var depNames []string
+	for _, d := range deps {
+		depNames = append(depNames, d.Name)
+	}
+
+	// dependencies in db
+	depsResultsMap, err := svc._getDependencyResultsMap(depNames)
+	if err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// iterate dependencies
+	for i, d := range deps {
+		dr, ok := depsResultsMap[d.Name]
+		if ok {
+			deps[i].Result = dr
+		}
+	}
+
+	controllers.HandleSuccessWithListData(c, deps, total)
+}
+
+func (svc *CondaService) GetDependencies(params entity.UpdateParams) (deps []models.Dependency, err error) {
+	// arguments, where packages of named environment are listed if set
+	args := []string{"list", "--json"}
+	args = append(args, svc.GetEnvArgs(params.EnvName)...)
+
+	cmd := exec.Command(params.Cmd, args...)
+	data, err := cmd.Output()
+	if err != nil {
+		return nil, trace.TraceError(err)
+	}
+	var packages []entity.CondaListPackage
+	if err := json.Unmarshal(data, &packages); err != nil {
+		return nil, trace.TraceError(err)
+	}
+	for _, p := range packages {
+		d := models.Dependency{
+			Name:        p.Name,
+			Version:     p.Version,
+			Description: p.Channel,
+			Platform:    p.Platform,
+		}
+		d.Type = constants.DependencyTypeConda
+		deps = append(deps, d)
+	}
+	return deps, nil
+}
+
+func (svc *CondaService) InstallDependencies(params entity.InstallParams) (err error) {
+	// arguments
+	var args []string
+
+	if params.UseConfig {
+		// workspace path
+		workspacePath, err := svc._getInstallWorkspacePath(params)
+		if err != nil {
+			return err
+		}
+
+		// config path
+		configPath := filepath.Join(workspacePath, constants.DependencyConfigEnvironmentYml)
+
+		// use config
+		args = append(args, "env", "update", "-f", configPath)
+
+		// environment
+		args = append(args, svc.GetEnvArgs(params.EnvName)...)
+	} else {
+		// install
+		args = append(args, "install", "-y")
+
+		// environment
+		args = append(args, svc.GetEnvArgs(params.EnvName)...)
+
+		// channels
+		for _, ch := range params.Channels {
+			args = append(args, "-c", ch)
+		}
+
+		// upgrade
+		if params.Upgrade {
+			args = append(args, "--update-deps")
+		}
+
+		// dependency names
+		for _, depName := range params.Names {
+			args = append(args, depName)
+		}
+	}
+
+	// run
+	return svc._runCmd(params.TaskId, exec.Command(params.Cmd, args...))
+}
+
+func (svc *CondaService) UninstallDependencies(params entity.UninstallParams) (err error) {
+	// arguments
+	var args []string
+
+	// uninstall
+	args = append(args, "remove", "-y")
+
+	// environment
+	args = append(args, svc.GetEnvArgs(params.EnvName)...)
+
+	// dependency names
+	for _, depName := range params.Names {
+		args = append(args, depName)
+	}
+
+	// run
+	return svc._runCmd(params.TaskId, exec.Command(params.Cmd, args...))
+}
+
+// GetLatestVersion returns the latest version of dependency in channel
+// index of the platform reported by node, where channels are looked up in
+// order of priority
+func (svc *CondaService) GetLatestVersion(dep models.Dependency) (v string, err error) {
+	for _, ch := range svc._getChannels(svc.s.Channels) {
+		for _, subdir := range svc._getSubdirs(dep.Platform) {
+			// latest versions in channel index, which is the index of
+			// current versions only, or the full index if not available
+			subdirUrl := svc._getChannelUrl(ch) + "/" + subdir
+			versions, err := svc._getRepodataVersions(subdirUrl + "/" + constants.CondaCurrentRepodataFile)
+			if err != nil {
+				return "", err
+			}
+			if versions == nil {
+				versions, err = svc._getRepodataVersions(subdirUrl + "/" + constants.CondaRepodataFile)
+				if err != nil {
+					return "", err
+				}
+			}
+			if _, ok := versions[dep.Name]; !ok {
+				continue
+			}
+			if v == "" {
+				v = versions[dep.Name]
+			} else if res, err := compareCondaVersions(versions[dep.Name], v); err == nil && res > 0 {
+				v = versions[dep.Name]
+			}
+		}
+
+		// skip channels of lower priority
+		if v != "" {
+			return v, nil
+		}
+	}
+
+	return "", nil
+}
+
+// CompareVersions compares conda versions, e.g. "1.21.5" and "1.21.5rc1"
+func (svc *CondaService) CompareVersions(v1, v2 string) (res int, err error) {
+	return compareCondaVersions(v1, v2)
+}
+
+// GetManifestDependencies returns conda packages declared in environment.yml
+// with their match specs, e.g. "numpy=1.21" or "conda-forge::scipy>=1.7",
+// where build strings and pip requirements are skipped
+func (svc *CondaService) GetManifestDependencies(workspacePath, manifest string) (deps []models.Dependency, parseErrors []entity.ManifestError, err error) {
+	// environment
+	data, err := ioutil.ReadFile(filepath.Join(workspacePath, manifest))
+	if err != nil {
+		return nil, nil, trace.TraceError(err)
+	}
+	var env entity.CondaEnvironment
+	if err := yaml.Unmarshal(data, &env); err != nil {
+		return nil, nil, trace.TraceError(err)
+	}
+
+	// iterate match specs
+	for _, item := range env.Dependencies {
+		spec, ok := item.(string)
+		if !ok {
+			continue
+		}
+		matches := condaMatchSpecPattern.FindStringSubmatch(strings.TrimSpace(spec))
+		if len(matches) < 3 {
+			parseErrors = append(parseErrors, entity.ManifestError{
+				File:    manifest,
+				Content: spec,
+				Error:   "invalid match spec",
+			})
+			continue
+		}
+
+		// version, e.g. "1.21" of "numpy 1.21 py39_0" or
+		// "=1.21" of "numpy=1.21=py39_0"
+		version := strings.TrimSpace(matches[2])
+		if fields := strings.Fields(version); len(fields) > 0 {
+			version = fields[0]
+		}
+		if strings.HasPrefix(version, "=") && !strings.HasPrefix(version, "==") {
+			version = "=" + strings.SplitN(version[1:], "=", 2)[0]
+		}
+
+		deps = append(deps, models.Dependency{
+			Name:    matches[1],
+			Version: version,
+		})
+	}
+
+	return deps, parseErrors, nil
+}
+
+// GetEnvArgs returns arguments of the named environment, which is a
+// prefix if it is a path
+func (svc *CondaService) GetEnvArgs(envName string) (args []string) {
+	if envName == "" {
+		return nil
+	}
+	if strings.ContainsAny(envName, "/\\") {
+		return []string{"-p", envName}
+	}
+	return []string{"-n", envName}
+}
+
+// _getApiUrl returns the search api url of anaconda.org
+func (svc *CondaService) _getApiUrl() (apiUrl string) {
+	return constants.CondaApiDefaultUrl
+}
+
+// _getChannelUrl returns url of channel, which is under the proxy of
+// the setting, or conda.anaconda.org by default, unless it is a url
+func (svc *CondaService) _getChannelUrl(ch string) (channelUrl string) {
+	if strings.Contains(ch, "://") {
+		return strings.TrimSuffix(ch, "/")
+	}
+	baseUrl := constants.CondaChannelDefaultUrl
+	if svc.s.Proxy != "" {
+		baseUrl = strings.TrimSuffix(svc.s.Proxy, "/")
+	}
+	return baseUrl + "/" + ch
+}
+
+// _getRepodataVersions returns the latest version of each package in the
+// channel index, which is cached for a while, and nil if the index does
+// not exist
+func (svc *CondaService) _getRepodataVersions(repodataUrl string) (versions map[string]string, err error) {
+	// cache
+	if res, ok := svc.repodataCache.Load(repodataUrl); ok {
+		cache, _ := res.(condaRepodataCache)
+		if time.Since(cache.ts) < constants.CondaRepodataCacheDuration {
+			return cache.versions, nil
+		}
+	}
+
+	// request session
+	reqSession := req.New()
+
+	// set timeout
+	reqSession.SetTimeout(5 * time.Minute)
+
+	// perform request
+	res, err := reqSession.Get(repodataUrl)
+	if err != nil {
+		return nil, trace.TraceError(err)
+	}
+	switch res.Response().StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		svc.repodataCache.Store(repodataUrl, condaRepodataCache{ts: time.Now()})
+		return nil, nil
+	default:
+		return nil, trace.TraceError(errors.New(fmt.Sprintf("request %s failed: %s", repodataUrl, res.Response().Status)))
+	}
+
+	// response
+	var repodata entity.CondaRepodata
+	if err := res.ToJSON(&repodata); err != nil {
+		return nil, trace.TraceError(err)
+	}
+
+	// latest version of each package
+	versions = map[string]string{}
+	for _, packages := range []map[string]entity.CondaRepodataPackage{repodata.Packages, repodata.PackagesConda} {
+		for _, p := range packages {
+			v, ok := versions[p.Name]
+			if !ok {
+				versions[p.Name] = p.Version
+				continue
+			}
+			if res, err := compareCondaVersions(p.Version, v); err == nil && res > 0 {
+				versions[p.Name] = p.Version
+			}
+		}
+	}
+
+	// cache
+	svc.repodataCache.Store(repodataUrl, condaRepodataCache{versions: versions, ts: time.Now()})
+
+	return versions, nil
+}
+
+// _getSubdirs returns subdirs of channel index of the platform, e.g.
+// "linux-64", which is the platform of current node if not reported,
+// followed by "noarch"
+func (svc *CondaService) _getSubdirs(platform string) (subdirs []string) {
+	if platform == "" {
+		platform = getCondaSubdir()
+	}
+	if platform != constants.CondaSubdirNoarch {
+		subdirs = append(subdirs, platform)
+	}
+	return append(subdirs, constants.CondaSubdirNoarch)
+}
+
+func (svc *CondaService) _getChannels(channels []string) (res []string) {
+	if len(channels) == 0 {
+		return []string{constants.CondaDefaultChannel}
+	}
+	return channels
+}
+
+// _getPackageLatestVersion returns the latest version of package in search
+// results of anaconda.org
+func (svc *CondaService) _getPackageLatestVersion(p entity.AnacondaPackage) (v string) {
+	if p.LatestVersion != "" {
+		return p.LatestVersion
+	}
+	for _, version := range p.Versions {
+		if v == "" {
+			v = version
+		} else if res, err := compareCondaVersions(version, v); err == nil && res > 0 {
+			v = version
+		}
+	}
+	return v
+}
+
+// condaRepodataCache is the latest version of each package in channel index
+type condaRepodataCache struct {
+	versions map[string]string
+	ts       time.Time
+}
+
+// getCondaSubdir returns the platform subdir of channel index of current
+// node, e.g. "linux-64"
+func getCondaSubdir() (subdir string) {
+	platforms := map[string]string{
+		"linux":   "linux",
+		"darwin":  "osx",
+		"windows": "win",
+	}
+	archs := map[string]string{
+		"amd64":   "64",
+		"386":     "32",
+		"arm64":   "aarch64",
+		"ppc64le": "ppc64le",
+		"s390x":   "s390x",
+	}
+	platform, arch := platforms[runtime.GOOS], archs[runtime.GOARCH]
+	if platform == "" || arch == "" {
+		return constants.CondaSubdirNoarch
+	}
+	if platform != "linux" && arch == "aarch64" {
+		arch = "arm64"
+	}
+	return platform + "-" + arch
+}
+
+func NewCondaService(parent *Service) (svc *CondaService) {
+	svc = &CondaService{}
+	baseSvc := newBaseService(
+		svc,
+		parent,
+		constants.DependencyTypeConda,
+		entity.MessageCodes{
+			Update:    constants.MessageCodeCondaUpdate,
+			Save:      constants.MessageCodeCondaSave,
+			Install:   constants.MessageCodeCondaInstall,
+			Uninstall: constants.MessageCodeCondaUninstall,
+		},
+		models.Setting{
+			Name:        "Conda",
+			Description: "settings.description.conda",
+			Cmd:         "conda",
+			Enabled:     true,
+			Channels:    []string{constants.CondaDefaultChannel},
+		},
+	)
+	baseSvc.manifests = []string{constants.DependencyConfigEnvironmentYml}
+	svc.baseService = baseSvc
+	return svc
+}
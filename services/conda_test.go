@@ -0,0 +1,183 @@
+package services
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/crawlab-team/plugin-dependency/constants"
+	"github.com/crawlab-team/plugin-dependency/models"
+)
+
+func TestCompareCondaVersions(t *testing.T) {
+	cases := []struct {
+		a, b string
+		res  int
+	}{
+		{"1.21.5", "1.21.10", -1},
+		{"1.0", "1.0.0", 0},
+		{"1.0rc1", "1.0", -1},
+		{"1.0dev1", "1.0a1", -1},
+		{"1.0.post1", "1.0", 1},
+		{"1.1.1k", "1.1.1j", 1},
+		{"1!1.0", "2.0", 1},
+		{"1.0+local", "1.0", 0},
+		{"2022_01_01", "2021_12_31", 1},
+	}
+	for _, c := range cases {
+		res, err := compareCondaVersions(c.a, c.b)
+		if err != nil {
+			t.Fatalf("compare %s with %s: %v", c.a, c.b, err)
+		}
+		if res != c.res {
+			t.Errorf("compare %s with %s: expected %d, got %d", c.a, c.b, c.res, res)
+		}
+	}
+}
+
+func TestCondaService_GetLatestVersion(t *testing.T) {
+	repodata := map[string]string{
+		"/main/linux-64/current_repodata.json": `{
+			"packages": {
+				"numpy-1.21.10-0.tar.bz2": {"name": "numpy", "version": "1.21.10"},
+				"numpy-1.21.9-0.tar.bz2": {"name": "numpy", "version": "1.21.9"}
+			},
+			"packages.conda": {
+				"numpy-1.21.10rc1-0.conda": {"name": "numpy", "version": "1.21.10rc1"}
+			}
+		}`,
+		"/main/osx-arm64/current_repodata.json": `{
+			"packages": {
+				"numpy-1.22.3-0.tar.bz2": {"name": "numpy", "version": "1.22.3"}
+			}
+		}`,
+		"/main/noarch/current_repodata.json": `{
+			"packages": {
+				"tqdm-4.64.0-0.tar.bz2": {"name": "tqdm", "version": "4.64.0"}
+			}
+		}`,
+		"/conda-forge/noarch/repodata.json": `{
+			"packages": {
+				"numpy-1.23.0-0.tar.bz2": {"name": "numpy", "version": "1.23.0"},
+				"black-22.3.0-0.tar.bz2": {"name": "black", "version": "22.3.0"}
+			}
+		}`,
+	}
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		data, ok := repodata[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(data))
+	}))
+	defer server.Close()
+
+	svc := &CondaService{baseService: &baseService{
+		s: models.Setting{
+			Proxy:    server.URL,
+			Channels: []string{"main", "conda-forge"},
+		},
+	}}
+	cases := []struct {
+		dep      models.Dependency
+		expected string
+	}{
+		{models.Dependency{Name: "numpy", Platform: "linux-64"}, "1.21.10"},
+		{models.Dependency{Name: "numpy", Platform: "osx-arm64"}, "1.22.3"},
+		{models.Dependency{Name: "numpy", Platform: "noarch"}, "1.23.0"},
+		{models.Dependency{Name: "tqdm", Platform: "linux-64"}, "4.64.0"},
+		{models.Dependency{Name: "black", Platform: "linux-64"}, "22.3.0"},
+		{models.Dependency{Name: "pandas", Platform: "linux-64"}, ""},
+	}
+	for _, c := range cases {
+		v, err := svc.GetLatestVersion(c.dep)
+		if err != nil {
+			t.Fatalf("latest version of %s on %s: %v", c.dep.Name, c.dep.Platform, err)
+		}
+		if v != c.expected {
+			t.Errorf("latest version of %s on %s: expected %q, got %q", c.dep.Name, c.dep.Platform, c.expected, v)
+		}
+	}
+
+	// full index is requested only if current index does not exist
+	for _, p := range paths {
+		if p == "/main/linux-64/repodata.json" || p == "/main/noarch/repodata.json" {
+			t.Errorf("unexpected request of full index: %s", p)
+		}
+	}
+}
+
+func TestCondaService_GetSubdirs(t *testing.T) {
+	svc := &CondaService{}
+	cases := []struct {
+		platform string
+		subdirs  []string
+	}{
+		{"linux-64", []string{"linux-64", "noarch"}},
+		{"noarch", []string{"noarch"}},
+		{"", []string{getCondaSubdir(), "noarch"}},
+	}
+	for _, c := range cases {
+		if subdirs := svc._getSubdirs(c.platform); !reflect.DeepEqual(subdirs, c.subdirs) {
+			t.Errorf("subdirs of %q: expected %v, got %v", c.platform, c.subdirs, subdirs)
+		}
+	}
+}
+
+func TestCondaService_GetEnvArgs(t *testing.T) {
+	svc := &CondaService{}
+	if args := svc.GetEnvArgs(""); len(args) != 0 {
+		t.Errorf("expected no args, got %v", args)
+	}
+	if args := svc.GetEnvArgs("py39"); len(args) != 2 || args[0] != "-n" {
+		t.Errorf("expected -n, got %v", args)
+	}
+	if args := svc.GetEnvArgs("/opt/envs/py39"); len(args) != 2 || args[0] != "-p" {
+		t.Errorf("expected -p, got %v", args)
+	}
+}
+
+func TestCondaService_GetManifestDependencies(t *testing.T) {
+	dir := t.TempDir()
+	env := `name: spider
+channels:
+  - conda-forge
+dependencies:
+  - python=3.9
+  - numpy=1.21.5=py39h7a5d4dd_0
+  - conda-forge::scipy>=1.7
+  - pandas 1.4.2 py39_0
+  - requests
+  - "!invalid"
+  - pip:
+      - scrapy==2.6.1
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, constants.DependencyConfigEnvironmentYml), []byte(env), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := &CondaService{}
+	deps, parseErrors, err := svc.GetManifestDependencies(dir, constants.DependencyConfigEnvironmentYml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []models.Dependency{
+		{Name: "python", Version: "=3.9"},
+		{Name: "numpy", Version: "=1.21.5"},
+		{Name: "scipy", Version: ">=1.7"},
+		{Name: "pandas", Version: "1.4.2"},
+		{Name: "requests"},
+	}
+	if !reflect.DeepEqual(deps, expected) {
+		t.Errorf("expected %v, got %v", expected, deps)
+	}
+	if len(parseErrors) != 1 || parseErrors[0].Content != "!invalid" {
+		t.Errorf("expected error of invalid match spec, got %v", parseErrors)
+	}
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var condaVersionTokenPattern = regexp.MustCompile(`[0-9]+|[a-z]+`)
+
+// condaVersion is a conda version split into components of numeric and
+// string tokens, e.g. "1.1.1k" -> [[1] [1] [1 "k"]]
+type condaVersion struct {
+	epoch      int
+	components [][]interface{}
+}
+
+// parseCondaVersion parses conda version, e.g. "1.21.5", "2.0.0rc1",
+// "1.1.1k" or "1!2.0", where the local version label is ignored
+func parseCondaVersion(s string) (v condaVersion, err error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return v, errors.New("empty conda version")
+	}
+
+	// local version label
+	s = strings.SplitN(s, "+", 2)[0]
+
+	// epoch
+	if parts := strings.SplitN(s, "!", 2); len(parts) == 2 {
+		v.epoch, err = strconv.Atoi(parts[0])
+		if err != nil {
+			return v, errors.New(fmt.Sprintf("invalid conda version: %s", s))
+		}
+		s = parts[1]
+	}
+
+	// components
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == '.' || r == '-' || r == '_'
+	}) {
+		tokens := condaVersionTokenPattern.FindAllString(part, -1)
+		if len(tokens) == 0 {
+			return v, errors.New(fmt.Sprintf("invalid conda version: %s", s))
+		}
+		var component []interface{}
+		for _, t := range tokens {
+			if n, err := strconv.Atoi(t); err == nil {
+				component = append(component, n)
+			} else {
+				component = append(component, t)
+			}
+		}
+		v.components = append(v.components, component)
+	}
+	if len(v.components) == 0 {
+		return v, errors.New(fmt.Sprintf("invalid conda version: %s", s))
+	}
+
+	return v, nil
+}
+
+// compare returns -1, 0 or 1 if the version is lower than, equal to or
+// higher than the other, where missing tokens are 0
+func (v condaVersion) compare(o condaVersion) (res int) {
+	if res = compareInt(v.epoch, o.epoch); res != 0 {
+		return res
+	}
+	for i := 0; i < len(v.components) || i < len(o.components); i++ {
+		var a, b []interface{}
+		if i < len(v.components) {
+			a = v.components[i]
+		}
+		if i < len(o.components) {
+			b = o.components[i]
+		}
+		for j := 0; j < len(a) || j < len(b); j++ {
+			var x, y interface{} = 0, 0
+			if j < len(a) {
+				x = a[j]
+			}
+			if j < len(b) {
+				y = b[j]
+			}
+			if res = compareCondaToken(x, y); res != 0 {
+				return res
+			}
+		}
+	}
+	return 0
+}
+
+// compareCondaToken compares tokens of conda versions, where strings sort
+// before numbers as pre-releases, except "post" sorting after everything
+// and "dev" before everything
+func compareCondaToken(x, y interface{}) (res int) {
+	nx, okX := x.(int)
+	ny, okY := y.(int)
+	switch {
+	case okX && okY:
+		return compareInt(nx, ny)
+	case okX:
+		return -compareCondaToken(y, x)
+	case okY:
+		if x == "post" {
+			return 1
+		}
+		return -1
+	}
+	sx, sy := x.(string), y.(string)
+	if res = compareInt(condaStringKey(sx), condaStringKey(sy)); res != 0 {
+		return res
+	}
+	return strings.Compare(sx, sy)
+}
+
+// condaStringKey returns sort key of string token, where "dev" sorts
+// before, and "post" after other strings
+func condaStringKey(s string) (key int) {
+	switch s {
+	case "dev":
+		return 0
+	case "post":
+		return 2
+	}
+	return 1
+}
+
+// compareCondaVersions compares conda versions, e.g. "1.21.5" and "1.21.5rc1"
+func compareCondaVersions(v1, v2 string) (res int, err error) {
+	a, err := parseCondaVersion(v1)
+	if err != nil {
+		return 0, err
+	}
+	b, err := parseCondaVersion(v2)
+	if err != nil {
+		return 0, err
+	}
+	return a.compare(b), nil
+}
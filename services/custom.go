@@ -0,0 +1,599 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/crawlab-team/crawlab-core/controllers"
+	"github.com/crawlab-team/go-trace"
+	"github.com/crawlab-team/plugin-dependency/constants"
+	"github.com/crawlab-team/plugin-dependency/entity"
+	"github.com/crawlab-team/plugin-dependency/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var customProviderKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+var customPlaceholderPattern = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// CustomService is a dependency provider instantiated from the
+// provider definition of a setting
+type CustomService struct {
+	*baseService
+}
+
+func (svc *CustomService) Init() {
+	// routes of custom providers are served by CustomProviderService
+}
+
+func (svc *CustomService) GetRepoList(c *gin.Context) {
+	// query
+	query := strings.TrimSpace(c.Query("query"))
+
+	// validate
+	if query == "" {
+		controllers.HandleErrorBadRequest(c, errors.New("empty query"))
+		return
+	}
+
+	// setting
+	if err := svc._getSetting(); err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// custom providers have no search, so the query is
+	// looked up as a dependency name
+	d := models.Dependency{
+		Name: query,
+	}
+	if svc.s.Provider != nil && svc.s.Provider.LatestVersionCmd != "" {
+		v, err := svc.GetLatestVersion(d)
+		if err != nil {
+			controllers.HandleErrorInternalServerError(c, err)
+			return
+		}
+		d.LatestVersion = v
+	}
+	deps := []models.Dependency{d}
+
+	// dependencies in db
+	depsResultsMap, err := svc._getDependencyResultsMap([]string{d.Name})
+	if err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+	if dr, ok := depsResultsMap[d.Name]; ok {
+		deps[0].Result = dr
+	}
+
+	controllers.HandleSuccessWithListData(c, deps, len(deps))
+}
+
+func (svc *CustomService) GetDependencies(params entity.UpdateParams) (deps []models.Dependency, err error) {
+	// definition
+	def, err := svc._getDefinition()
+	if err != nil {
+		return nil, err
+	}
+
+	// command
+	args, err := svc._renderCmd(def.ListCmd, map[string][]string{
+		"cmd": {params.Cmd},
+	})
+	if err != nil {
+		return nil, err
+	}
+	data, err := exec.Command(args[0], args[1:]...).Output()
+	if err != nil {
+		return nil, trace.TraceError(err)
+	}
+
+	// parse output
+	deps, err = svc._parseOutput(def.Parser, data)
+	if err != nil {
+		return nil, err
+	}
+	for i := range deps {
+		deps[i].Type = svc.key
+	}
+
+	return deps, nil
+}
+
+func (svc *CustomService) InstallDependencies(params entity.InstallParams) (err error) {
+	// definition
+	def, err := svc._getDefinition()
+	if err != nil {
+		return err
+	}
+
+	if params.UseConfig {
+		// validate
+		if def.ConfigInstallCmd == "" || def.Manifest == "" {
+			return trace.TraceError(errors.New(fmt.Sprintf("installing by config is not supported by %s", svc.key)))
+		}
+
+		// workspace path
+		workspacePath, err := svc._getInstallWorkspacePath(params)
+		if err != nil {
+			return err
+		}
+
+		// command
+		args, err := svc._renderCmd(def.ConfigInstallCmd, map[string][]string{
+			"cmd":      {params.Cmd},
+			"proxy":    {params.Proxy},
+			"manifest": {filepath.Join(workspacePath, def.Manifest)},
+		})
+		if err != nil {
+			return err
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Dir = workspacePath
+
+		return svc._runCmd(params.TaskId, cmd)
+	}
+
+	// command template
+	tpl := def.InstallCmd
+	if params.Upgrade && def.UpgradeCmd != "" {
+		tpl = def.UpgradeCmd
+	}
+
+	return svc._runCmdTemplate(params.TaskId, tpl, params.Cmd, params.Proxy, params.Names)
+}
+
+func (svc *CustomService) UninstallDependencies(params entity.UninstallParams) (err error) {
+	// definition
+	def, err := svc._getDefinition()
+	if err != nil {
+		return err
+	}
+
+	return svc._runCmdTemplate(params.TaskId, def.UninstallCmd, params.Cmd, "", params.Names)
+}
+
+func (svc *CustomService) GetLatestVersion(dep models.Dependency) (v string, err error) {
+	// definition
+	def := svc.s.Provider
+	if def == nil || def.LatestVersionCmd == "" {
+		return "", nil
+	}
+
+	// command
+	args, err := svc._renderCmd(def.LatestVersionCmd, map[string][]string{
+		"cmd":   {svc._getCmd()},
+		"name":  {dep.Name},
+		"names": {dep.Name},
+		"proxy": {svc.s.Proxy},
+	})
+	if err != nil {
+		return "", err
+	}
+	data, err := exec.Command(args[0], args[1:]...).Output()
+	if err != nil {
+		return "", trace.TraceError(err)
+	}
+
+	// last non-empty line of output
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1]), nil
+}
+
+// GetManifestDependencies returns dependencies declared in the manifest,
+// which is parsed by the manifest parser of provider definition, or as
+// lines like "name==version" if not defined
+func (svc *CustomService) GetManifestDependencies(workspacePath, manifest string) (deps []models.Dependency, parseErrors []entity.ManifestError, err error) {
+	// definition
+	def, err := svc._getDefinition()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// manifest
+	data, err := ioutil.ReadFile(filepath.Join(workspacePath, manifest))
+	if err != nil {
+		return nil, nil, trace.TraceError(err)
+	}
+
+	// parse
+	var parser models.ProviderParser
+	if def.ManifestParser != nil {
+		parser = *def.ManifestParser
+	}
+	deps, err = svc._parseOutput(parser, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return deps, nil, nil
+}
+
+// _getDefinition returns the provider definition of the latest setting,
+// as the definition may have been updated since registration
+func (svc *CustomService) _getDefinition() (def *models.ProviderDefinition, err error) {
+	if err := svc._getSetting(); err != nil {
+		return nil, err
+	}
+	if svc.s.Provider == nil {
+		return nil, trace.TraceError(errors.New(fmt.Sprintf("provider definition not found: %s", svc.key)))
+	}
+	return svc.s.Provider, nil
+}
+
+// _runCmdTemplate runs the command template once for all names, or
+// once for each name if the template contains {{name}}
+func (svc *CustomService) _runCmdTemplate(taskId primitive.ObjectID, tpl, cmd, proxy string, names []string) (err error) {
+	// all names in one command
+	if !strings.Contains(strings.ReplaceAll(tpl, " ", ""), "{{name}}") {
+		args, err := svc._renderCmd(tpl, map[string][]string{
+			"cmd":   {cmd},
+			"names": names,
+			"proxy": {proxy},
+		})
+		if err != nil {
+			return err
+		}
+		return svc._runCmd(taskId, exec.Command(args[0], args[1:]...))
+	}
+
+	// one command for each name
+	for _, name := range names {
+		args, err := svc._renderCmd(tpl, map[string][]string{
+			"cmd":   {cmd},
+			"name":  {name},
+			"names": {name},
+			"proxy": {proxy},
+		})
+		if err != nil {
+			return err
+		}
+		if err := svc._runCmd(taskId, exec.Command(args[0], args[1:]...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// _renderCmd renders the command template into command name and arguments
+func (svc *CustomService) _renderCmd(tpl string, vars map[string][]string) (args []string, err error) {
+	// validate
+	if strings.TrimSpace(tpl) == "" {
+		return nil, trace.TraceError(errors.New(fmt.Sprintf("command template not defined for %s", svc.key)))
+	}
+
+	// iterate tokens
+	var group []string
+	inGroup := false
+	for _, token := range strings.Fields(tpl) {
+		// optional group
+		if !inGroup && strings.HasPrefix(token, "[") {
+			inGroup = true
+			token = strings.TrimPrefix(token, "[")
+		}
+		if inGroup {
+			end := strings.HasSuffix(token, "]")
+			group = append(group, strings.TrimSuffix(token, "]"))
+			if !end {
+				continue
+			}
+			inGroup = false
+			groupArgs, empty, err := svc._renderTokens(group, vars)
+			if err != nil {
+				return nil, err
+			}
+			if !empty {
+				args = append(args, groupArgs...)
+			}
+			group = nil
+			continue
+		}
+
+		// required token
+		tokenArgs, empty, err := svc._renderTokens([]string{token}, vars)
+		if err != nil {
+			return nil, err
+		}
+		if empty {
+			return nil, trace.TraceError(errors.New(fmt.Sprintf("empty placeholder in required argument of command template: %s", token)))
+		}
+		args = append(args, tokenArgs...)
+	}
+	if inGroup {
+		return nil, trace.TraceError(errors.New(fmt.Sprintf("unclosed bracket in command template: %s", tpl)))
+	}
+	if len(args) == 0 {
+		return nil, trace.TraceError(errors.New(fmt.Sprintf("empty command rendered from template: %s", tpl)))
+	}
+
+	return args, nil
+}
+
+// _renderTokens renders tokens into arguments, where a token consisting
+// only of a multi-valued placeholder such as {{names}} is expanded into
+// multiple arguments. empty is true if any placeholder has no value.
+func (svc *CustomService) _renderTokens(tokens []string, vars map[string][]string) (args []string, empty bool, err error) {
+	for _, token := range tokens {
+		// placeholders
+		matches := customPlaceholderPattern.FindAllStringSubmatch(token, -1)
+		if len(matches) == 0 {
+			if token != "" {
+				args = append(args, token)
+			}
+			continue
+		}
+
+		// validate placeholders
+		for _, m := range matches {
+			values, ok := vars[m[1]]
+			if !ok {
+				switch m[1] {
+				case "cmd", "name", "names", "proxy", "manifest":
+				default:
+					return nil, false, trace.TraceError(errors.New(fmt.Sprintf("invalid placeholder in command template: %s", m[0])))
+				}
+			}
+			if len(values) == 0 || (len(values) == 1 && values[0] == "") {
+				empty = true
+			}
+		}
+
+		// expand multi-valued placeholder, skipping empty values
+		if len(matches) == 1 && matches[0][0] == token {
+			for _, v := range vars[matches[0][1]] {
+				if v != "" {
+					args = append(args, v)
+				}
+			}
+			continue
+		}
+
+		// substitute placeholders
+		res := customPlaceholderPattern.ReplaceAllStringFunc(token, func(s string) string {
+			name := customPlaceholderPattern.FindStringSubmatch(s)[1]
+			return strings.Join(vars[name], " ")
+		})
+		if res != "" {
+			args = append(args, res)
+		}
+	}
+	return args, empty, nil
+}
+
+func (svc *CustomService) _parseOutput(parser models.ProviderParser, data []byte) (deps []models.Dependency, err error) {
+	switch parser.Type {
+	case constants.ProviderParserTypeJson:
+		return svc._parseOutputJson(parser, data)
+	case constants.ProviderParserTypeRegex:
+		return svc._parseOutputRegex(parser, data)
+	case constants.ProviderParserTypeNameVersion, "":
+		return svc._parseOutputNameVersion(parser, data)
+	default:
+		return nil, trace.TraceError(errors.New(fmt.Sprintf("invalid parser type: %s", parser.Type)))
+	}
+}
+
+// _parseOutputJson parses json output, where dependencies are located by
+// a dot-separated path, e.g. "installed" or "data.0.packages", and are
+// either an array of objects or an object keyed by dependency name
+func (svc *CustomService) _parseOutputJson(parser models.ProviderParser, data []byte) (deps []models.Dependency, err error) {
+	// fields
+	nameField := parser.NameField
+	if nameField == "" {
+		nameField = constants.ProviderParserDefaultNameField
+	}
+	versionField := parser.VersionField
+	if versionField == "" {
+		versionField = constants.ProviderParserDefaultVersionField
+	}
+
+	// unmarshal
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, trace.TraceError(err)
+	}
+
+	// locate by path
+	if parser.Path != "" {
+		for _, seg := range strings.Split(parser.Path, ".") {
+			switch v := value.(type) {
+			case map[string]interface{}:
+				value = v[seg]
+			case []interface{}:
+				i, err := strconv.Atoi(seg)
+				if err != nil || i < 0 || i >= len(v) {
+					return nil, trace.TraceError(errors.New(fmt.Sprintf("invalid json path: %s", parser.Path)))
+				}
+				value = v[i]
+			default:
+				return nil, trace.TraceError(errors.New(fmt.Sprintf("invalid json path: %s", parser.Path)))
+			}
+		}
+	}
+
+	switch v := value.(type) {
+	case []interface{}:
+		// e.g. [{"name": "x", "version": "1.0"}]
+		for _, item := range v {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name := svc._getJsonString(obj[nameField])
+			if name == "" {
+				continue
+			}
+			deps = append(deps, models.Dependency{
+				Name:    name,
+				Version: svc._getJsonString(obj[versionField]),
+			})
+		}
+	case map[string]interface{}:
+		// e.g. {"x": "1.0"} or {"x": {"version": "1.0"}}
+		var names []string
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			d := models.Dependency{Name: name}
+			switch item := v[name].(type) {
+			case map[string]interface{}:
+				d.Version = svc._getJsonString(item[versionField])
+			default:
+				d.Version = svc._getJsonString(item)
+			}
+			deps = append(deps, d)
+		}
+	case nil:
+		return nil, nil
+	default:
+		return nil, trace.TraceError(errors.New(fmt.Sprintf("invalid json path: %s", parser.Path)))
+	}
+
+	return deps, nil
+}
+
+func (svc *CustomService) _getJsonString(value interface{}) (res string) {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// _parseOutputRegex parses each line of output by regular expression with
+// named groups "name" and "version", or the first two groups otherwise
+func (svc *CustomService) _parseOutputRegex(parser models.ProviderParser, data []byte) (deps []models.Dependency, err error) {
+	pattern, err := regexp.Compile(parser.Pattern)
+	if err != nil {
+		return nil, trace.TraceError(err)
+	}
+	nameIndex := pattern.SubexpIndex("name")
+	if nameIndex < 0 {
+		nameIndex = 1
+	}
+	versionIndex := pattern.SubexpIndex("version")
+	if versionIndex < 0 {
+		versionIndex = 2
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		matches := pattern.FindStringSubmatch(strings.TrimSpace(line))
+		if len(matches) <= nameIndex || matches[nameIndex] == "" {
+			continue
+		}
+		d := models.Dependency{
+			Name: matches[nameIndex],
+		}
+		if len(matches) > versionIndex {
+			d.Version = matches[versionIndex]
+		}
+		deps = append(deps, d)
+	}
+	return deps, nil
+}
+
+// _parseOutputNameVersion parses output of lines like "name==version"
+func (svc *CustomService) _parseOutputNameVersion(parser models.ProviderParser, data []byte) (deps []models.Dependency, err error) {
+	sep := parser.Separator
+	if sep == "" {
+		sep = constants.ProviderParserDefaultSeparator
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, sep, 2)
+		d := models.Dependency{
+			Name: strings.TrimSpace(parts[0]),
+		}
+		if len(parts) == 2 {
+			d.Version = strings.TrimSpace(parts[1])
+		}
+		deps = append(deps, d)
+	}
+	return deps, nil
+}
+
+// validateProviderDefinition validates the provider definition of a setting
+func validateProviderDefinition(s models.Setting) (err error) {
+	// key
+	if !customProviderKeyPattern.MatchString(s.Key) {
+		return errors.New(fmt.Sprintf("invalid provider key: %s", s.Key))
+	}
+
+	// definition
+	def := s.Provider
+	if def == nil {
+		return errors.New(fmt.Sprintf("provider definition not found: %s", s.Key))
+	}
+	if def.ListCmd == "" || def.InstallCmd == "" || def.UninstallCmd == "" {
+		return errors.New("list, install and uninstall commands are required")
+	}
+	if def.ConfigInstallCmd != "" && def.Manifest == "" {
+		return errors.New("manifest is required by config install command")
+	}
+	if strings.ContainsAny(def.Manifest, `/\`) {
+		return errors.New(fmt.Sprintf("invalid manifest: %s", def.Manifest))
+	}
+
+	// parsers
+	if err := validateProviderParser(def.Parser); err != nil {
+		return err
+	}
+	if def.ManifestParser != nil {
+		if def.Manifest == "" {
+			return errors.New("manifest is required by manifest parser")
+		}
+		if err := validateProviderParser(*def.ManifestParser); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateProviderParser(parser models.ProviderParser) (err error) {
+	switch parser.Type {
+	case constants.ProviderParserTypeJson, constants.ProviderParserTypeNameVersion, "":
+	case constants.ProviderParserTypeRegex:
+		if _, err := regexp.Compile(parser.Pattern); err != nil {
+			return err
+		}
+	default:
+		return errors.New(fmt.Sprintf("invalid parser type: %s", parser.Type))
+	}
+	return nil
+}
+
+func NewCustomService(parent *Service, s models.Setting) (svc *CustomService) {
+	svc = &CustomService{}
+	baseSvc := newBaseService(
+		svc,
+		parent,
+		s.Key,
+		entity.MessageCodes{
+			Update:    constants.MessageCodeCustomUpdatePrefix + s.Key,
+			Save:      constants.MessageCodeCustomSavePrefix + s.Key,
+			Install:   constants.MessageCodeCustomInstallPrefix + s.Key,
+			Uninstall: constants.MessageCodeCustomUninstallPrefix + s.Key,
+		},
+		s,
+	)
+	baseSvc.s = s
+	svc.baseService = baseSvc
+	return svc
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/crawlab-team/crawlab-core/controllers"
+	"github.com/crawlab-team/go-trace"
+	"github.com/crawlab-team/plugin-dependency/constants"
+	"github.com/crawlab-team/plugin-dependency/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"strings"
+)
+
+// CustomProviderService manages dependency providers defined in settings,
+// which are registered at runtime and served under "/custom/:key"
+type CustomProviderService struct {
+	parent *Service
+	api    *gin.Engine
+}
+
+func (svc *CustomProviderService) Init() {
+	svc.api.GET("/custom/:key", svc.getList)
+	svc.api.POST("/custom/:key/update", svc.update)
+	svc.api.POST("/custom/:key/install", svc.install)
+	svc.api.POST("/custom/:key/uninstall", svc.uninstall)
+}
+
+func (svc *CustomProviderService) getList(c *gin.Context) {
+	p, err := svc._getProvider(c.Param("key"))
+	if err != nil {
+		controllers.HandleErrorNotFound(c, err)
+		return
+	}
+	p.getList(c)
+}
+
+func (svc *CustomProviderService) update(c *gin.Context) {
+	p, err := svc._getProvider(c.Param("key"))
+	if err != nil {
+		controllers.HandleErrorNotFound(c, err)
+		return
+	}
+	p.update(c)
+}
+
+func (svc *CustomProviderService) install(c *gin.Context) {
+	p, err := svc._getProvider(c.Param("key"))
+	if err != nil {
+		controllers.HandleErrorNotFound(c, err)
+		return
+	}
+	p.install(c)
+}
+
+func (svc *CustomProviderService) uninstall(c *gin.Context) {
+	p, err := svc._getProvider(c.Param("key"))
+	if err != nil {
+		controllers.HandleErrorNotFound(c, err)
+		return
+	}
+	p.uninstall(c)
+}
+
+// load registers custom providers of all settings with provider definitions
+func (svc *CustomProviderService) load() (err error) {
+	var settings []models.Setting
+	query := bson.M{
+		"provider": bson.M{
+			"$ne": nil,
+		},
+	}
+	if err := svc.parent.colS.Find(query, nil).All(&settings); err != nil {
+		if err.Error() == mongo.ErrNoDocuments.Error() {
+			return nil
+		}
+		return trace.TraceError(err)
+	}
+	for _, s := range settings {
+		if _, ok := svc.parent.registry.get(s.Key); ok {
+			continue
+		}
+		if _, err := svc.register(s); err != nil {
+			trace.PrintError(err)
+		}
+	}
+	return nil
+}
+
+// register instantiates and registers custom provider of the setting
+func (svc *CustomProviderService) register(s models.Setting) (p *baseService, err error) {
+	// validate
+	if err := validateProviderDefinition(s); err != nil {
+		return nil, trace.TraceError(err)
+	}
+
+	// register
+	p = NewCustomService(svc.parent, s).baseService
+	if err := svc.parent.registerProvider(p); err != nil {
+		return nil, trace.TraceError(err)
+	}
+
+	return p, nil
+}
+
+// unregister removes custom provider of the given key
+func (svc *CustomProviderService) unregister(key string) {
+	if _, err := svc._getProvider(key); err != nil {
+		return
+	}
+	svc.parent.registry.unregister(key)
+}
+
+// isCustomMessageCode returns true if the message code belongs to
+// a custom provider, which may not have been registered on this node
+func (svc *CustomProviderService) isCustomMessageCode(code string) (res bool) {
+	for _, prefix := range []string{
+		constants.MessageCodeCustomUpdatePrefix,
+		constants.MessageCodeCustomSavePrefix,
+		constants.MessageCodeCustomInstallPrefix,
+		constants.MessageCodeCustomUninstallPrefix,
+	} {
+		if strings.HasPrefix(code, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (svc *CustomProviderService) _getProvider(key string) (p *baseService, err error) {
+	p, ok := svc.parent.registry.get(key)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("custom provider not found: %s", key))
+	}
+	if _, ok := p.svc.(*CustomService); !ok {
+		return nil, errors.New(fmt.Sprintf("custom provider not found: %s", key))
+	}
+	return p, nil
+}
+
+func NewCustomProviderService(parent *Service) (svc *CustomProviderService) {
+	svc = &CustomProviderService{
+		parent: parent,
+		api:    parent.GetApi(),
+	}
+
+	return svc
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/crawlab-team/plugin-dependency/constants"
+	"github.com/crawlab-team/plugin-dependency/models"
+)
+
+func newCustomTestService() (svc *CustomService) {
+	return &CustomService{baseService: &baseService{key: "test"}}
+}
+
+func TestCustomService_RenderCmd(t *testing.T) {
+	svc := newCustomTestService()
+	cases := []struct {
+		tpl  string
+		vars map[string][]string
+		args []string
+		err  bool
+	}{
+		// placeholders
+		{"{{cmd}} install {{names}}", map[string][]string{"cmd": {"pip"}, "names": {"requests", "scrapy"}}, []string{"pip", "install", "requests", "scrapy"}, false},
+		{"{{cmd}} install {{name}}==latest", map[string][]string{"cmd": {"pip"}, "name": {"requests"}}, []string{"pip", "install", "requests==latest"}, false},
+
+		// optional groups
+		{"{{cmd}} install [--index-url {{proxy}}] {{names}}", map[string][]string{"cmd": {"pip"}, "proxy": {"https://mirror"}, "names": {"requests"}}, []string{"pip", "install", "--index-url", "https://mirror", "requests"}, false},
+		{"{{cmd}} install [--index-url {{proxy}}] {{names}}", map[string][]string{"cmd": {"pip"}, "proxy": {""}, "names": {"requests"}}, []string{"pip", "install", "requests"}, false},
+		{"{{cmd}} install [--index-url {{proxy}}] {{names}}", map[string][]string{"cmd": {"pip"}, "names": {"requests"}}, []string{"pip", "install", "requests"}, false},
+		{"{{cmd}} install [--registry={{proxy}}]", map[string][]string{"cmd": {"npm"}}, []string{"npm", "install"}, false},
+		{"{{cmd}} install [--index-url {{proxy}}", map[string][]string{"cmd": {"pip"}}, nil, true},
+
+		// quoting, where values with spaces or quotes are single
+		// arguments and not interpreted by shell
+		{"{{cmd}} install {{name}}", map[string][]string{"cmd": {"pip"}, "name": {"requests; rm -rf /"}}, []string{"pip", "install", "requests; rm -rf /"}, false},
+		{"{{cmd}} install {{names}}", map[string][]string{"cmd": {"pip"}, "names": {`"requests"`, "a b"}}, []string{"pip", "install", `"requests"`, "a b"}, false},
+		{"{{cmd}} -r {{manifest}}", map[string][]string{"cmd": {"pip"}, "manifest": {"/spiders/my spider/requirements.txt"}}, []string{"pip", "-r", "/spiders/my spider/requirements.txt"}, false},
+
+		// missing placeholders
+		{"{{cmd}} install -r {{manifest}}", map[string][]string{"cmd": {"pip"}}, nil, true},
+		{"{{cmd}} install {{unknown}}", map[string][]string{"cmd": {"pip"}}, nil, true},
+		{"[{{cmd}}]", map[string][]string{}, nil, true},
+		{"  ", map[string][]string{}, nil, true},
+	}
+	for _, c := range cases {
+		args, err := svc._renderCmd(c.tpl, c.vars)
+		if c.err {
+			if err == nil {
+				t.Errorf("render %q: expected error, got %v", c.tpl, args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("render %q: %v", c.tpl, err)
+			continue
+		}
+		if !reflect.DeepEqual(args, c.args) {
+			t.Errorf("render %q: expected %q, got %q", c.tpl, c.args, args)
+		}
+	}
+}
+
+func TestCustomService_RenderTokens(t *testing.T) {
+	svc := newCustomTestService()
+	vars := map[string][]string{
+		"cmd":   {"pip"},
+		"names": {"requests", "scrapy"},
+		"proxy": {""},
+	}
+	cases := []struct {
+		tokens []string
+		args   []string
+		empty  bool
+	}{
+		{[]string{"{{names}}"}, []string{"requests", "scrapy"}, false},
+		{[]string{"--pkgs={{names}}"}, []string{"--pkgs=requests scrapy"}, false},
+		{[]string{"--index-url", "{{proxy}}"}, []string{"--index-url"}, true},
+		{[]string{"{{manifest}}"}, nil, true},
+	}
+	for _, c := range cases {
+		args, empty, err := svc._renderTokens(c.tokens, vars)
+		if err != nil {
+			t.Fatalf("render %v: %v", c.tokens, err)
+		}
+		if !reflect.DeepEqual(args, c.args) || empty != c.empty {
+			t.Errorf("render %v: expected %q %t, got %q %t", c.tokens, c.args, c.empty, args, empty)
+		}
+	}
+}
+
+func TestCustomService_ParseOutput(t *testing.T) {
+	svc := newCustomTestService()
+	cases := []struct {
+		name   string
+		parser models.ProviderParser
+		data   string
+		deps   []models.Dependency
+		err    bool
+	}{
+		{
+			name:   "json array",
+			parser: models.ProviderParser{Type: constants.ProviderParserTypeJson},
+			data:   `[{"name": "requests", "version": "2.28.1"}, {"version": "1.0"}, "invalid"]`,
+			deps:   []models.Dependency{{Name: "requests", Version: "2.28.1"}},
+		},
+		{
+			name:   "json nested keys",
+			parser: models.ProviderParser{Type: constants.ProviderParserTypeJson, Path: "data.1.packages", NameField: "pkg", VersionField: "ver"},
+			data:   `{"data": [{}, {"packages": [{"pkg": "lodash", "ver": 4}]}]}`,
+			deps:   []models.Dependency{{Name: "lodash", Version: "4"}},
+		},
+		{
+			name:   "json object keyed by name",
+			parser: models.ProviderParser{Type: constants.ProviderParserTypeJson, Path: "dependencies"},
+			data:   `{"dependencies": {"b": {"version": "2.0"}, "a": "1.0"}}`,
+			deps:   []models.Dependency{{Name: "a", Version: "1.0"}, {Name: "b", Version: "2.0"}},
+		},
+		{
+			name:   "json missing key",
+			parser: models.ProviderParser{Type: constants.ProviderParserTypeJson, Path: "installed"},
+			data:   `{"packages": []}`,
+		},
+		{
+			name:   "json invalid path",
+			parser: models.ProviderParser{Type: constants.ProviderParserTypeJson, Path: "data.x"},
+			data:   `{"data": [{"name": "a"}]}`,
+			err:    true,
+		},
+		{
+			name:   "json out of range",
+			parser: models.ProviderParser{Type: constants.ProviderParserTypeJson, Path: "data.2"},
+			data:   `{"data": [{"name": "a"}]}`,
+			err:    true,
+		},
+		{
+			name:   "json malformed",
+			parser: models.ProviderParser{Type: constants.ProviderParserTypeJson},
+			data:   `{"data":`,
+			err:    true,
+		},
+		{
+			name:   "regex named groups",
+			parser: models.ProviderParser{Type: constants.ProviderParserTypeRegex, Pattern: `^(?P<version>\S+)\s+(?P<name>\S+)$`},
+			data:   "2.28.1 requests\ninvalid\n",
+			deps:   []models.Dependency{{Name: "requests", Version: "2.28.1"}},
+		},
+		{
+			name:   "regex groups",
+			parser: models.ProviderParser{Type: constants.ProviderParserTypeRegex, Pattern: `^(\S+) \((\S+)\)$`},
+			data:   "nokogiri (1.13.8)\nrake (13.0.6)",
+			deps:   []models.Dependency{{Name: "nokogiri", Version: "1.13.8"}, {Name: "rake", Version: "13.0.6"}},
+		},
+		{
+			name:   "regex malformed",
+			parser: models.ProviderParser{Type: constants.ProviderParserTypeRegex, Pattern: `^(\S+`},
+			data:   "requests",
+			err:    true,
+		},
+		{
+			name:   "name version",
+			parser: models.ProviderParser{},
+			data:   "# comment\nrequests==2.28.1\n\nscrapy\n",
+			deps:   []models.Dependency{{Name: "requests", Version: "2.28.1"}, {Name: "scrapy"}},
+		},
+		{
+			name:   "name version separator",
+			parser: models.ProviderParser{Type: constants.ProviderParserTypeNameVersion, Separator: "@"},
+			data:   "lodash@4.17.21",
+			deps:   []models.Dependency{{Name: "lodash", Version: "4.17.21"}},
+		},
+		{
+			name:   "invalid type",
+			parser: models.ProviderParser{Type: "xml"},
+			err:    true,
+		},
+	}
+	for _, c := range cases {
+		deps, err := svc._parseOutput(c.parser, []byte(c.data))
+		if c.err {
+			if err == nil {
+				t.Errorf("%s: expected error, got %v", c.name, deps)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(deps, c.deps) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.deps, deps)
+		}
+	}
+}
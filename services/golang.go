@@ -0,0 +1,374 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/blang/semver/v4"
+	"github.com/crawlab-team/crawlab-core/controllers"
+	"github.com/crawlab-team/crawlab-core/utils"
+	"github.com/crawlab-team/go-trace"
+	"github.com/crawlab-team/plugin-dependency/constants"
+	"github.com/crawlab-team/plugin-dependency/entity"
+	"github.com/crawlab-team/plugin-dependency/models"
+	"github.com/gin-gonic/gin"
+	"github.com/imroc/req"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type GoService struct {
+	*baseService
+}
+
+func (svc *GoService) GetRepoList(c *gin.Context) {
+	// query
+	query := strings.TrimSpace(c.Query("query"))
+
+	// validate
+	if query == "" {
+		controllers.HandleErrorBadRequest(c, errors.New("empty query"))
+		return
+	}
+
+	// setting
+	if err := svc._getSetting(); err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// go proxy has no search endpoint, so the query is
+	// looked up as a package path
+	v, err := svc._getProxyLatestVersion(query)
+	if err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// empty results
+	if v == "" {
+		controllers.HandleSuccess(c)
+		return
+	}
+
+	// dependencies
+	deps := []models.Dependency{
+		{
+			Name:          query,
+			LatestVersion: v,
+		},
+	}
+
+	// dependencies in db
+	depsResultsMap, err := svc._getDependencyResultsMap([]string{query})
+	if err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// iterate dependencies
+	for i, d := range deps {
+		dr, ok := depsResultsMap[d.Name]
+		if ok {
+			deps[i].Result = dr
+		}
+	}
+
+	controllers.HandleSuccessWithListData(c, deps, len(deps))
+}
+
+func (svc *GoService) GetDependencies(params entity.UpdateParams) (deps []models.Dependency, err error) {
+	bins, err := svc._getBinaries(params.Cmd)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range bins {
+		d := models.Dependency{
+			Name:        b.Path,
+			Version:     b.Version,
+			Description: filepath.Base(b.FilePath),
+		}
+		d.Type = constants.DependencyTypeGo
+		deps = append(deps, d)
+	}
+	return deps, nil
+}
+
+func (svc *GoService) InstallDependencies(params entity.InstallParams) (err error) {
+	// validate
+	if params.UseConfig {
+		return trace.TraceError(errors.New("installing by config is not supported by go"))
+	}
+
+	// environment
+	env := os.Environ()
+
+	// proxy
+	if params.Proxy != "" {
+		env = append(env, "GOPROXY="+params.Proxy)
+	}
+
+	// iterate dependency names, as go install only accepts
+	// multiple packages from the same module
+	for _, depName := range params.Names {
+		// version
+		if params.Upgrade || !strings.Contains(depName, "@") {
+			depName = strings.SplitN(depName, "@", 2)[0] + "@latest"
+		}
+
+		// command
+		cmd := exec.Command(params.Cmd, "install", depName)
+		cmd.Env = env
+
+		// run
+		if err := svc._runCmd(params.TaskId, cmd); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (svc *GoService) UninstallDependencies(params entity.UninstallParams) (err error) {
+	// installed binaries
+	bins, err := svc._getBinaries(params.Cmd)
+	if err != nil {
+		return err
+	}
+
+	// dependency names
+	depNamesMap := map[string]bool{}
+	for _, depName := range params.Names {
+		depNamesMap[depName] = true
+	}
+
+	// remove binaries
+	var logLines []string
+	for _, b := range bins {
+		if !depNamesMap[b.Path] {
+			continue
+		}
+		if err := os.Remove(b.FilePath); err != nil {
+			return trace.TraceError(err)
+		}
+		logLines = append(logLines, fmt.Sprintf("removed %s (%s)", b.FilePath, b.Path))
+	}
+
+	// logging
+	if len(logLines) > 0 {
+		svc.parent._sendLogs(params.TaskId, logLines)
+	}
+
+	return nil
+}
+
+func (svc *GoService) GetLatestVersion(dep models.Dependency) (v string, err error) {
+	return svc._getProxyLatestVersion(dep.Name)
+}
+
+// CompareVersions compares module versions, which are prefixed with "v",
+// e.g. "v0.7.4" or pseudo-version "v0.0.0-20211216021012-1d35b9e2eb4e"
+func (svc *GoService) CompareVersions(v1, v2 string) (res int, err error) {
+	sv1, err := semver.ParseTolerant(v1)
+	if err != nil {
+		return 0, trace.TraceError(err)
+	}
+	sv2, err := semver.ParseTolerant(v2)
+	if err != nil {
+		return 0, trace.TraceError(err)
+	}
+	return sv1.Compare(sv2), nil
+}
+
+func (svc *GoService) _getBinDir(cmd string) (binDir string, err error) {
+	data, err := exec.Command(cmd, "env", "GOBIN", "GOPATH").Output()
+	if err != nil {
+		return "", trace.TraceError(err)
+	}
+	lines := strings.Split(string(data), "\n")
+	if len(lines) < 2 {
+		return "", trace.TraceError(errors.New("invalid go env output"))
+	}
+
+	// GOBIN
+	if gobin := strings.TrimSpace(lines[0]); gobin != "" {
+		return gobin, nil
+	}
+
+	// first GOPATH entry
+	gopaths := filepath.SplitList(strings.TrimSpace(lines[1]))
+	if len(gopaths) == 0 {
+		return "", trace.TraceError(errors.New("empty GOPATH"))
+	}
+	return filepath.Join(gopaths[0], "bin"), nil
+}
+
+func (svc *GoService) _getBinaries(cmd string) (bins []entity.GoBinaryInfo, err error) {
+	// bin directory
+	binDir, err := svc._getBinDir(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if !utils.Exists(binDir) {
+		return nil, nil
+	}
+
+	// build info of all binaries in bin directory
+	data, err := exec.Command(cmd, "version", "-m", binDir).Output()
+	if err != nil {
+		return nil, trace.TraceError(err)
+	}
+
+	return svc._parseVersionOutput(string(data)), nil
+}
+
+// _parseVersionOutput parses the output of "go version -m", e.g.
+//
+//	/root/go/bin/gopls: go1.17.5
+//		path	golang.org/x/tools/gopls
+//		mod	golang.org/x/tools/gopls	v0.7.4	h1:...
+func (svc *GoService) _parseVersionOutput(content string) (bins []entity.GoBinaryInfo) {
+	var b *entity.GoBinaryInfo
+	for _, line := range strings.Split(content, "\n") {
+		// skip empty lines
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		// binary header
+		if !strings.HasPrefix(line, "\t") {
+			if b != nil && b.Path != "" {
+				bins = append(bins, *b)
+			}
+			b = nil
+			idx := strings.LastIndex(line, ": ")
+			if idx < 0 {
+				continue
+			}
+			b = &entity.GoBinaryInfo{FilePath: line[:idx]}
+			continue
+		}
+
+		// skip lines not belonging to a binary
+		if b == nil {
+			continue
+		}
+
+		// build info
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "path":
+			if len(fields) > 1 {
+				b.Path = fields[1]
+			}
+		case "mod":
+			if len(fields) > 2 {
+				b.ModulePath = fields[1]
+				b.Version = fields[2]
+			}
+		}
+	}
+	if b != nil && b.Path != "" {
+		bins = append(bins, *b)
+	}
+	return bins
+}
+
+func (svc *GoService) _getProxyLatestVersion(pkgPath string) (v string, err error) {
+	// request session
+	reqSession := req.New()
+
+	// set timeout
+	reqSession.SetTimeout(60 * time.Second)
+
+	// iterate the package path and its parents, as the module
+	// path can be any prefix of the package path
+	for p := pkgPath; p != "." && p != "/" && p != ""; p = path.Dir(p) {
+		// request url
+		requestUrl := fmt.Sprintf("%s/%s/@latest", svc._getProxyUrl(), svc._escapeModulePath(p))
+
+		// perform request
+		res, err := reqSession.Get(requestUrl)
+		if err != nil {
+			return "", trace.TraceError(err)
+		}
+
+		// try parent path if module not found
+		statusCode := res.Response().StatusCode
+		if statusCode == http.StatusNotFound || statusCode == http.StatusGone {
+			continue
+		}
+		if statusCode != http.StatusOK {
+			return "", trace.TraceError(errors.New(fmt.Sprintf("request %s failed: %s", requestUrl, res.Response().Status)))
+		}
+
+		// response
+		var info entity.GoProxyInfo
+		if err := res.ToJSON(&info); err != nil {
+			return "", trace.TraceError(err)
+		}
+
+		return info.Version, nil
+	}
+
+	return "", nil
+}
+
+// _getProxyUrl returns the first proxy url in the GOPROXY-style
+// proxy list of the setting
+func (svc *GoService) _getProxyUrl() (proxyUrl string) {
+	proxies := strings.FieldsFunc(svc.s.Proxy, func(r rune) bool {
+		return r == ',' || r == '|'
+	})
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if p == "" || p == "direct" || p == "off" {
+			continue
+		}
+		return strings.TrimSuffix(p, "/")
+	}
+	return constants.GoProxyDefaultUrl
+}
+
+// _escapeModulePath escapes upper-case letters in module path
+// as required by the GOPROXY protocol, e.g. "github.com/Azure" to
+// "github.com/!azure"
+func (svc *GoService) _escapeModulePath(p string) (res string) {
+	var sb strings.Builder
+	for _, r := range p {
+		if 'A' <= r && r <= 'Z' {
+			sb.WriteRune('!')
+			sb.WriteRune(r + ('a' - 'A'))
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+func NewGoService(parent *Service) (svc *GoService) {
+	svc = &GoService{}
+	baseSvc := newBaseService(
+		svc,
+		parent,
+		constants.DependencyTypeGo,
+		entity.MessageCodes{
+			Update:    constants.MessageCodeGoUpdate,
+			Save:      constants.MessageCodeGoSave,
+			Install:   constants.MessageCodeGoInstall,
+			Uninstall: constants.MessageCodeGoUninstall,
+		},
+		models.Setting{
+			Name:        "Go",
+			Description: "settings.description.go",
+			Cmd:         "go",
+			Enabled:     true,
+		},
+	)
+	svc.baseService = baseSvc
+	return svc
+}
@@ -0,0 +1,31 @@
+package services
+
+import "testing"
+
+func TestGoService_CompareVersions(t *testing.T) {
+	svc := &GoService{}
+	cases := []struct {
+		a, b string
+		res  int
+	}{
+		{"v0.7.4", "v0.7.10", -1},
+		{"v1.2.0", "v1.2.0", 0},
+		{"v2.0.0+incompatible", "v1.9.9", 1},
+		{"v0.0.0-20211216021012-1d35b9e2eb4e", "v0.0.1", -1},
+		{"v1.0.0-rc.1", "v1.0.0", -1},
+	}
+	for _, c := range cases {
+		res, err := svc.CompareVersions(c.a, c.b)
+		if err != nil {
+			t.Fatalf("compare %s with %s: %v", c.a, c.b, err)
+		}
+		if res != c.res {
+			t.Errorf("compare %s with %s: expected %d, got %d", c.a, c.b, c.res, res)
+		}
+	}
+
+	// development builds are not comparable
+	if _, err := svc.CompareVersions("(devel)", "v1.0.0"); err == nil {
+		t.Error("expected error of (devel)")
+	}
+}
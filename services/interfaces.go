@@ -4,13 +4,74 @@ import (
 	"github.com/crawlab-team/plugin-dependency/entity"
 	"github.com/crawlab-team/plugin-dependency/models"
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type DependencyService interface {
 	Init()
+	Start()
+	GetKey() (key string)
+	GetMessageCodes() (codes entity.MessageCodes)
+	GetDefaultSetting() (s models.Setting)
 	GetRepoList(c *gin.Context)
 	GetDependencies(params entity.UpdateParams) (deps []models.Dependency, err error)
 	InstallDependencies(params entity.InstallParams) (err error)
 	UninstallDependencies(params entity.UninstallParams) (err error)
 	GetLatestVersion(dep models.Dependency) (v string, err error)
 }
+
+// IsolatedDependencyService is implemented by dependency providers which
+// support isolated environments for each spider
+type IsolatedDependencyService interface {
+	GetEnvPath(root string, spiderId primitive.ObjectID) (envPath string, err error)
+}
+
+// ComparableDependencyService is implemented by dependency providers whose
+// versions are not compared as semantic versions
+type ComparableDependencyService interface {
+	CompareVersions(v1, v2 string) (res int, err error)
+}
+
+// VersionSpecDependencyService is implemented by dependency providers which
+// install dependencies at versions or version constraints
+type VersionSpecDependencyService interface {
+	ValidateVersionSpec(spec string) (err error)
+}
+
+// LocalDependencyService is implemented by dependency providers which
+// install dependencies of spider into its workspace, and can report them
+// separately from global dependencies
+type LocalDependencyService interface {
+	GetLocalDependencies(params entity.UpdateParams) (deps []models.Dependency, err error)
+}
+
+// ManifestDependencyService is implemented by dependency providers which
+// parse dependencies declared in manifest files of spider workspace, where
+// lines failed to parse are skipped and returned as parse errors
+type ManifestDependencyService interface {
+	GetManifestDependencies(workspacePath, manifest string) (deps []models.Dependency, parseErrors []entity.ManifestError, err error)
+}
+
+// NormalizedNameDependencyService is implemented by dependency providers
+// whose dependency names are the same if normalized, e.g. "Scrapy_Redis"
+// and "scrapy-redis" of python, where GetNamePattern returns the regular
+// expression of names which are the same as the name, ignoring case
+type NormalizedNameDependencyService interface {
+	NormalizeName(name string) (res string)
+	GetNamePattern(name string) (pattern string)
+}
+
+// NamedEnvDependencyService is implemented by dependency providers which
+// install dependencies into named environments, e.g. conda environments
+type NamedEnvDependencyService interface {
+	GetEnvArgs(envName string) (args []string)
+}
+
+// RegistryClient looks up packages in a package registry, where
+// GetPackage returns nil if the package does not exist
+type RegistryClient interface {
+	Search(query string, page, size int) (pkgs []entity.RegistryPackage, total int, err error)
+	GetVersions(name string) (versions []string, err error)
+	GetLatestVersion(name string) (v string, err error)
+	GetPackage(name string) (pkg *entity.RegistryPackage, err error)
+}
@@ -0,0 +1,544 @@
+package services
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/crawlab-team/crawlab-core/controllers"
+	"github.com/crawlab-team/crawlab-core/utils"
+	"github.com/crawlab-team/go-trace"
+	"github.com/crawlab-team/plugin-dependency/constants"
+	"github.com/crawlab-team/plugin-dependency/entity"
+	"github.com/crawlab-team/plugin-dependency/models"
+	"github.com/gin-gonic/gin"
+	"github.com/imroc/req"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var javaPomPropertyPattern = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+type JavaService struct {
+	*baseService
+}
+
+func (svc *JavaService) GetRepoList(c *gin.Context) {
+	// query
+	query := strings.TrimSpace(c.Query("query"))
+	pagination := controllers.MustGetPagination(c)
+
+	// validate
+	if query == "" {
+		controllers.HandleErrorBadRequest(c, errors.New("empty query"))
+		return
+	}
+
+	// setting
+	if err := svc._getSetting(); err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// dependencies
+	var deps []models.Dependency
+	var total int
+	if strings.Contains(query, ":") {
+		// look up coordinates in repository
+		groupId, artifactId, _, err := svc._parseCoordinates(query)
+		if err != nil {
+			controllers.HandleErrorBadRequest(c, err)
+			return
+		}
+		v, err := svc._getRepoLatestVersion(svc._getRepoUrl(svc.s.Proxy), groupId, artifactId)
+		if err != nil {
+			controllers.HandleErrorInternalServerError(c, err)
+			return
+		}
+		if v != "" {
+			deps = append(deps, models.Dependency{
+				Name:          groupId + ":" + artifactId,
+				LatestVersion: v,
+			})
+			total = 1
+		}
+	} else if svc.s.Proxy == "" {
+		// search in maven central
+		searchRes, err := svc._search(query, pagination.Page, pagination.Size)
+		if err != nil {
+			controllers.HandleErrorInternalServerError(c, err)
+			return
+		}
+		for _, doc := range searchRes.Response.Docs {
+			deps = append(deps, models.Dependency{
+				Name:          doc.GroupId + ":" + doc.ArtifactId,
+				LatestVersion: doc.LatestVersion,
+			})
+		}
+		total = searchRes.Response.NumFound
+	}
+
+	// empty results
+	if total == 0 {
+		controllers.HandleSuccess(c)
+		return
+	}
+
+	// dependency names
+	var depNames []string
+	for _, d := range deps {
+		depNames = append(depNames, d.Name)
+	}
+
+	// dependencies in db
+	depsResultsMap, err := svc._getDependencyResultsMap(depNames)
+	if err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// iterate dependencies
+	for i, d := range deps {
+		dr, ok := depsResultsMap[d.Name]
+		if ok {
+			deps[i].Result = dr
+		}
+	}
+
+	controllers.HandleSuccessWithListData(c, deps, total)
+}
+
+func (svc *JavaService) GetDependencies(params entity.UpdateParams) (deps []models.Dependency, err error) {
+	// local repository
+	repoPath, err := svc._getLocalRepoPath()
+	if err != nil {
+		return nil, err
+	}
+
+	// artifacts in local repository
+	artifacts, err := svc._getLocalArtifacts(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// keep the highest version of each artifact
+	depsMap := map[string]models.Dependency{}
+	var depNames []string
+	for _, a := range artifacts {
+		name := a.GroupId + ":" + a.ArtifactId
+		d, ok := depsMap[name]
+		if !ok {
+			depNames = append(depNames, name)
+		} else if res, _ := svc.CompareVersions(a.Version, d.Version); res <= 0 {
+			continue
+		}
+		depsMap[name] = models.Dependency{
+			Name:    name,
+			Version: a.Version,
+			Type:    constants.DependencyTypeJava,
+		}
+	}
+	for _, name := range depNames {
+		deps = append(deps, depsMap[name])
+	}
+
+	return deps, nil
+}
+
+func (svc *JavaService) InstallDependencies(params entity.InstallParams) (err error) {
+	// install by pom.xml
+	if params.UseConfig {
+		// workspace path
+		workspacePath, err := svc._getInstallWorkspacePath(params)
+		if err != nil {
+			return err
+		}
+
+		// config path
+		configPath := filepath.Join(workspacePath, constants.DependencyConfigPomXml)
+
+		// arguments
+		args := []string{"-B", "-f", configPath, "dependency:go-offline"}
+
+		return svc._runCmd(params.TaskId, exec.Command(params.Cmd, args...))
+	}
+
+	// repository url
+	repoUrl := svc._getRepoUrl(params.Proxy)
+
+	// iterate dependency names
+	for _, depName := range params.Names {
+		// coordinates
+		groupId, artifactId, version, err := svc._parseCoordinates(depName)
+		if err != nil {
+			return trace.TraceError(err)
+		}
+
+		// resolve the latest version if not specified, as
+		// dependency:get requires an explicit version
+		if params.Upgrade || version == "" {
+			version, err = svc._getRepoLatestVersion(repoUrl, groupId, artifactId)
+			if err != nil {
+				return err
+			}
+			if version == "" {
+				return trace.TraceError(errors.New(fmt.Sprintf("artifact not found: %s:%s", groupId, artifactId)))
+			}
+		}
+
+		// arguments
+		args := []string{"-B", "dependency:get"}
+		args = append(args, fmt.Sprintf("-Dartifact=%s:%s:%s", groupId, artifactId, version))
+
+		// proxy
+		if params.Proxy != "" {
+			args = append(args, "-DremoteRepositories="+params.Proxy)
+		}
+
+		// run
+		if err := svc._runCmd(params.TaskId, exec.Command(params.Cmd, args...)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (svc *JavaService) UninstallDependencies(params entity.UninstallParams) (err error) {
+	// local repository
+	repoPath, err := svc._getLocalRepoPath()
+	if err != nil {
+		return err
+	}
+
+	// iterate dependency names
+	var logLines []string
+	for _, depName := range params.Names {
+		// coordinates
+		groupId, artifactId, version, err := svc._parseCoordinates(depName)
+		if err != nil {
+			return trace.TraceError(err)
+		}
+
+		// artifact directory, or artifact version directory if
+		// version is specified
+		dirPath := filepath.Join(repoPath, strings.ReplaceAll(groupId, ".", string(filepath.Separator)), artifactId)
+		if version != "" {
+			dirPath = filepath.Join(dirPath, version)
+		}
+		if !utils.Exists(dirPath) {
+			continue
+		}
+
+		// remove
+		if err := os.RemoveAll(dirPath); err != nil {
+			return trace.TraceError(err)
+		}
+		logLines = append(logLines, fmt.Sprintf("removed %s", dirPath))
+	}
+
+	// logging
+	if len(logLines) > 0 {
+		svc.parent._sendLogs(params.TaskId, logLines)
+	}
+
+	return nil
+}
+
+func (svc *JavaService) GetLatestVersion(dep models.Dependency) (v string, err error) {
+	groupId, artifactId, _, err := svc._parseCoordinates(dep.Name)
+	if err != nil {
+		return "", trace.TraceError(err)
+	}
+	return svc._getRepoLatestVersion(svc._getRepoUrl(svc.s.Proxy), groupId, artifactId)
+}
+
+// _parseCoordinates parses maven coordinates in the form of
+// "groupId:artifactId[:version]"
+// GetManifestDependencies returns dependencies declared in pom.xml, whose
+// versions are resolved from properties, e.g. "${jackson.version}", or
+// empty if managed by parent or imported boms
+func (svc *JavaService) GetManifestDependencies(workspacePath, manifest string) (deps []models.Dependency, parseErrors []entity.ManifestError, err error) {
+	// pom.xml
+	data, err := ioutil.ReadFile(filepath.Join(workspacePath, manifest))
+	if err != nil {
+		return nil, nil, trace.TraceError(err)
+	}
+	var pom entity.MavenPom
+	if err := xml.Unmarshal(data, &pom); err != nil {
+		return nil, nil, trace.TraceError(err)
+	}
+
+	// properties
+	props := map[string]string{}
+	if pom.Version != "" {
+		props["project.version"] = strings.TrimSpace(pom.Version)
+	}
+	for _, p := range pom.Properties.Entries {
+		props[p.XMLName.Local] = strings.TrimSpace(p.Value)
+	}
+
+	// iterate dependencies
+	for _, d := range pom.Dependencies {
+		groupId := strings.TrimSpace(d.GroupId)
+		artifactId := strings.TrimSpace(d.ArtifactId)
+		if groupId == "" || artifactId == "" {
+			continue
+		}
+		version := javaPomPropertyPattern.ReplaceAllStringFunc(strings.TrimSpace(d.Version), func(s string) string {
+			if v, ok := props[s[2:len(s)-1]]; ok {
+				return v
+			}
+			return s
+		})
+		deps = append(deps, models.Dependency{
+			Name:    groupId + ":" + artifactId,
+			Version: version,
+		})
+	}
+
+	return deps, nil, nil
+}
+
+func (svc *JavaService) _parseCoordinates(name string) (groupId, artifactId, version string, err error) {
+	parts := strings.Split(strings.TrimSpace(name), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return "", "", "", errors.New(fmt.Sprintf("invalid maven coordinates: %s", name))
+	}
+	for _, p := range parts {
+		if p == "" || p == "." || p == ".." || strings.ContainsAny(p, "/\\") {
+			return "", "", "", errors.New(fmt.Sprintf("invalid maven coordinates: %s", name))
+		}
+	}
+	groupId, artifactId = parts[0], parts[1]
+	if len(parts) == 3 {
+		version = parts[2]
+	}
+	return groupId, artifactId, version, nil
+}
+
+// _getLocalRepoPath returns the local repository path configured in
+// ~/.m2/settings.xml, or the default ~/.m2/repository
+func (svc *JavaService) _getLocalRepoPath() (repoPath string, err error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", trace.TraceError(err)
+	}
+	m2Path := filepath.Join(homeDir, ".m2")
+
+	// settings.xml
+	settingsPath := filepath.Join(m2Path, "settings.xml")
+	if utils.Exists(settingsPath) {
+		data, err := ioutil.ReadFile(settingsPath)
+		if err != nil {
+			return "", trace.TraceError(err)
+		}
+		var settings entity.MavenSettings
+		if err := xml.Unmarshal(data, &settings); err != nil {
+			return "", trace.TraceError(err)
+		}
+		if p := strings.TrimSpace(settings.LocalRepository); p != "" {
+			return strings.ReplaceAll(p, "${user.home}", homeDir), nil
+		}
+	}
+
+	return filepath.Join(m2Path, "repository"), nil
+}
+
+// _getLocalArtifacts walks the local repository and returns the artifact
+// versions, which are directories containing "<artifactId>-<version>.pom"
+func (svc *JavaService) _getLocalArtifacts(repoPath string) (artifacts []entity.MavenArtifact, err error) {
+	if !utils.Exists(repoPath) {
+		return nil, nil
+	}
+	err = filepath.Walk(repoPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+
+		// artifact version directory
+		version := filepath.Base(p)
+		artifactDir := filepath.Dir(p)
+		artifactId := filepath.Base(artifactDir)
+		pomPath := filepath.Join(p, fmt.Sprintf("%s-%s.pom", artifactId, version))
+		if !utils.Exists(pomPath) {
+			return nil
+		}
+
+		// group id
+		groupPath, err := filepath.Rel(repoPath, filepath.Dir(artifactDir))
+		if err != nil || groupPath == "." {
+			return nil
+		}
+		groupId := strings.ReplaceAll(groupPath, string(filepath.Separator), ".")
+
+		artifacts = append(artifacts, entity.MavenArtifact{
+			GroupId:    groupId,
+			ArtifactId: artifactId,
+			Version:    version,
+			Dir:        p,
+		})
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, trace.TraceError(err)
+	}
+	return artifacts, nil
+}
+
+func (svc *JavaService) _getRepoUrl(proxy string) (repoUrl string) {
+	if proxy == "" {
+		return constants.MavenRepoDefaultUrl
+	}
+	return strings.TrimSuffix(proxy, "/")
+}
+
+func (svc *JavaService) _getRepoLatestVersion(repoUrl, groupId, artifactId string) (v string, err error) {
+	// request session
+	reqSession := req.New()
+
+	// set timeout
+	reqSession.SetTimeout(60 * time.Second)
+
+	// request url
+	requestUrl := fmt.Sprintf("%s/%s/%s/maven-metadata.xml", repoUrl, strings.ReplaceAll(groupId, ".", "/"), artifactId)
+
+	// perform request
+	res, err := reqSession.Get(requestUrl)
+	if err != nil {
+		return "", trace.TraceError(err)
+	}
+	if res.Response().StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if res.Response().StatusCode != http.StatusOK {
+		return "", trace.TraceError(errors.New(fmt.Sprintf("request %s failed: %s", requestUrl, res.Response().Status)))
+	}
+
+	// metadata
+	var metadata entity.MavenMetadata
+	if err := res.ToXML(&metadata); err != nil {
+		return "", trace.TraceError(err)
+	}
+
+	// latest version
+	if metadata.Versioning.Release != "" {
+		return metadata.Versioning.Release, nil
+	}
+	if metadata.Versioning.Latest != "" {
+		return metadata.Versioning.Latest, nil
+	}
+	if n := len(metadata.Versioning.Versions); n > 0 {
+		return metadata.Versioning.Versions[n-1], nil
+	}
+	return "", nil
+}
+
+func (svc *JavaService) _search(query string, page, size int) (searchRes entity.MavenSearchResponse, err error) {
+	// request session
+	reqSession := req.New()
+
+	// set timeout
+	reqSession.SetTimeout(15 * time.Second)
+
+	// request url
+	requestUrl := fmt.Sprintf("%s?q=%s&start=%d&rows=%d&wt=json", constants.MavenSearchDefaultUrl, url.QueryEscape(query), (page-1)*size, size)
+
+	// perform request
+	res, err := reqSession.Get(requestUrl)
+	if err != nil {
+		return searchRes, trace.TraceError(err)
+	}
+
+	// response
+	if err := res.ToJSON(&searchRes); err != nil {
+		return searchRes, trace.TraceError(err)
+	}
+
+	return searchRes, nil
+}
+
+// CompareVersions compares maven versions segment by segment,
+// numerically where possible, e.g. "2.9" and "1.0-SNAPSHOT"
+func (svc *JavaService) CompareVersions(a, b string) (res int, err error) {
+	split := func(v string) []string {
+		return strings.FieldsFunc(v, func(r rune) bool {
+			return r == '.' || r == '-'
+		})
+	}
+	partsA, partsB := split(a), split(b)
+	for i := 0; i < len(partsA) || i < len(partsB); i++ {
+		var pa, pb string
+		if i < len(partsA) {
+			pa = partsA[i]
+		}
+		if i < len(partsB) {
+			pb = partsB[i]
+		}
+
+		// a missing segment is 0 against a numeric segment
+		if _, err := strconv.Atoi(pb); pa == "" && err == nil {
+			pa = "0"
+		}
+		if _, err := strconv.Atoi(pa); pb == "" && err == nil {
+			pb = "0"
+		}
+		na, errA := strconv.Atoi(pa)
+		nb, errB := strconv.Atoi(pb)
+		switch {
+		case errA == nil && errB == nil:
+			if na != nb {
+				if na > nb {
+					return 1, nil
+				}
+				return -1, nil
+			}
+		case pa != pb:
+			// qualifiers (e.g. "SNAPSHOT", "beta") sort before releases
+			qualifierA := pa != "" && errA != nil
+			qualifierB := pb != "" && errB != nil
+			switch {
+			case qualifierA && !qualifierB:
+				return -1, nil
+			case qualifierB && !qualifierA:
+				return 1, nil
+			}
+			return strings.Compare(pa, pb), nil
+		}
+	}
+	return 0, nil
+}
+
+func NewJavaService(parent *Service) (svc *JavaService) {
+	svc = &JavaService{}
+	baseSvc := newBaseService(
+		svc,
+		parent,
+		constants.DependencyTypeJava,
+		entity.MessageCodes{
+			Update:    constants.MessageCodeJavaUpdate,
+			Save:      constants.MessageCodeJavaSave,
+			Install:   constants.MessageCodeJavaInstall,
+			Uninstall: constants.MessageCodeJavaUninstall,
+		},
+		models.Setting{
+			Name:        "Java",
+			Description: "settings.description.java",
+			Cmd:         "mvn",
+			Enabled:     true,
+		},
+	)
+	baseSvc.manifests = []string{constants.DependencyConfigPomXml}
+	svc.baseService = baseSvc
+	return svc
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/crawlab-team/plugin-dependency/constants"
+	"github.com/crawlab-team/plugin-dependency/models"
+)
+
+func TestJavaService_CompareVersions(t *testing.T) {
+	svc := &JavaService{}
+	cases := []struct {
+		a, b string
+		res  int
+	}{
+		{"1.0", "1.0.1", -1},
+		{"1.0.1", "1.0", 1},
+		{"1.0", "1.0.0", 0},
+		{"1.10", "1.9", 1},
+		{"1.0-SNAPSHOT", "1.0", -1},
+		{"1.0", "1.0-SNAPSHOT", 1},
+		{"1.0-SNAPSHOT", "1.0.1", -1},
+		{"1.0-alpha", "1.0-beta", -1},
+		{"2.0-beta", "1.9", 1},
+	}
+	for _, c := range cases {
+		res, err := svc.CompareVersions(c.a, c.b)
+		if err != nil {
+			t.Fatalf("compare %s with %s: %v", c.a, c.b, err)
+		}
+		if res != c.res {
+			t.Errorf("compare %s with %s: expected %d, got %d", c.a, c.b, c.res, res)
+		}
+	}
+}
+
+func TestJavaService_GetManifestDependencies(t *testing.T) {
+	dir := t.TempDir()
+	pom := `<?xml version="1.0" encoding="UTF-8"?>
+<project>
+  <groupId>com.example</groupId>
+  <artifactId>spider</artifactId>
+  <version>1.2.0</version>
+  <properties>
+    <jsoup.version>1.15.3</jsoup.version>
+  </properties>
+  <dependencies>
+    <dependency>
+      <groupId>org.jsoup</groupId>
+      <artifactId>jsoup</artifactId>
+      <version>${jsoup.version}</version>
+    </dependency>
+    <dependency>
+      <groupId>com.example</groupId>
+      <artifactId>common</artifactId>
+      <version>${project.version}</version>
+    </dependency>
+    <dependency>
+      <groupId>org.slf4j</groupId>
+      <artifactId>slf4j-api</artifactId>
+    </dependency>
+  </dependencies>
+</project>`
+	if err := ioutil.WriteFile(filepath.Join(dir, constants.DependencyConfigPomXml), []byte(pom), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := &JavaService{}
+	deps, _, err := svc.GetManifestDependencies(dir, constants.DependencyConfigPomXml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []models.Dependency{
+		{Name: "org.jsoup:jsoup", Version: "1.15.3"},
+		{Name: "com.example:common", Version: "1.2.0"},
+		{Name: "org.slf4j:slf4j-api"},
+	}
+	if !reflect.DeepEqual(deps, expected) {
+		t.Errorf("expected %v, got %v", expected, deps)
+	}
+}
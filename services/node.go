@@ -1,34 +1,31 @@
 package services
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/blang/semver/v4"
 	"github.com/crawlab-team/crawlab-core/controllers"
+	"github.com/crawlab-team/crawlab-core/spider/fs"
 	"github.com/crawlab-team/go-trace"
 	"github.com/crawlab-team/plugin-dependency/constants"
 	"github.com/crawlab-team/plugin-dependency/entity"
 	"github.com/crawlab-team/plugin-dependency/models"
 	"github.com/gin-gonic/gin"
-	"github.com/imroc/req"
-	"go.mongodb.org/mongo-driver/bson"
-	mongo2 "go.mongodb.org/mongo-driver/mongo"
-	"net/url"
+	"os"
 	"os/exec"
-	"time"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 )
 
+// npmDistTagPattern matches dist-tags, e.g. "latest", "next" or "beta"
+var npmDistTagPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9._-]*$`)
+
 type NodeService struct {
 	*baseService
 }
 
-func (svc *NodeService) Init() {
-	svc.api.GET("/node", svc.getList)
-	svc.api.POST("/node/update", svc.update)
-	svc.api.POST("/node/install", svc.install)
-	svc.api.POST("/node/uninstall", svc.uninstall)
-}
-
 func (svc *NodeService) GetRepoList(c *gin.Context) {
 	// query
 	query := c.Query("query")
@@ -40,39 +37,21 @@ func (svc *NodeService) GetRepoList(c *gin.Context) {
 		return
 	}
 
-	// request session
-	reqSession := req.New()
-
-	// set timeout
-	reqSession.SetTimeout(15 * time.Second)
-
-	// user agent
-	ua := req.Header{"user-agent": "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_14_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/94.0.4606.61 Safari/537.36"}
-
-	// request url
-	requestUrl := fmt.Sprintf("https://api.npms.io/v2/search?from=%d&q=%s&size=20", (pagination.Page-1)*pagination.Size, url.QueryEscape(query))
-
-	// perform request
-	res, err := reqSession.Get(requestUrl, ua)
-	if err != nil {
-		if res != nil {
-			_, _ = c.Writer.Write(res.Bytes())
-			_ = c.AbortWithError(res.Response().StatusCode, err)
-			return
-		}
+	// setting
+	if err := svc._getSetting(); err != nil {
 		controllers.HandleErrorInternalServerError(c, err)
 		return
 	}
 
-	// response
-	var npmRes entity.NpmResponseList
-	if err := res.ToJSON(&npmRes); err != nil {
+	// packages in registry
+	pkgs, total, err := svc._getRegistryClient().Search(query, pagination.Page, pagination.Size)
+	if err != nil {
 		controllers.HandleErrorInternalServerError(c, err)
 		return
 	}
 
 	// empty results
-	if npmRes.Total == 0 {
+	if total == 0 {
 		controllers.HandleSuccess(c)
 		return
 	}
@@ -80,62 +59,23 @@ func (svc *NodeService) GetRepoList(c *gin.Context) {
 	// dependencies
 	var deps []models.Dependency
 	var depNames []string
-	for _, r := range npmRes.Results {
+	for _, pkg := range pkgs {
 		d := models.Dependency{
-			Name:          r.Package.Name,
-			LatestVersion: r.Package.Version,
+			Name:          pkg.Name,
+			LatestVersion: pkg.LatestVersion,
+			Description:   pkg.Description,
 		}
 		deps = append(deps, d)
 		depNames = append(depNames, d.Name)
 	}
 
-	// total
-	total := npmRes.Total
-
 	// dependencies in db
-	var depsResults []entity.DependencyResult
-	pipelines := mongo2.Pipeline{
-		{{
-			"$match",
-			bson.M{
-				"type": constants.DependencyTypeNode,
-				"name": bson.M{
-					"$in": depNames,
-				},
-			},
-		}},
-		{{
-			"$group",
-			bson.M{
-				"_id": "$name",
-				"node_ids": bson.M{
-					"$push": "$node_id",
-				},
-				"versions": bson.M{
-					"$addToSet": "$version",
-				},
-			},
-		}},
-		{{
-			"$project",
-			bson.M{
-				"name":     "$_id",
-				"node_ids": "$node_ids",
-				"versions": "$versions",
-			},
-		}},
-	}
-	if err := svc.parent.colD.Aggregate(pipelines, nil).All(&depsResults); err != nil {
+	depsResultsMap, err := svc._getDependencyResultsMap(depNames)
+	if err != nil {
 		controllers.HandleErrorInternalServerError(c, err)
 		return
 	}
 
-	// dependencies map
-	depsResultsMap := map[string]entity.DependencyResult{}
-	for _, dr := range depsResults {
-		depsResultsMap[dr.Name] = dr
-	}
-
 	// iterate dependencies
 	for i, d := range deps {
 		dr, ok := depsResultsMap[d.Name]
@@ -148,136 +88,240 @@ func (svc *NodeService) GetRepoList(c *gin.Context) {
 }
 
 func (svc *NodeService) GetDependencies(params entity.UpdateParams) (deps []models.Dependency, err error) {
-	cmd := exec.Command(params.Cmd, "list", "-g", "--json", "--depth", "0")
-	data, err := cmd.Output()
+	// package manager
+	pm, err := svc._getPackageManager(params.Cmd)
 	if err != nil {
 		return nil, err
 	}
-	var res entity.NpmListResult
-	if err := json.Unmarshal(data, &res); err != nil {
+	if !pm.global {
+		return nil, trace.TraceError(errors.New(fmt.Sprintf("global packages are not supported by %s", pm.name)))
+	}
+
+	// installed packages
+	data, err := exec.Command(params.Cmd, pm.listArgs...).Output()
+	if err != nil {
+		return nil, trace.TraceError(err)
+	}
+	deps, err = pm.parseList(data)
+	if err != nil {
 		return nil, err
 	}
-	for name, p := range res.Dependencies {
-		d := models.Dependency{
-			Name:    name,
-			Version: p.Version,
-		}
-		d.Type = constants.DependencyTypeNode
-		deps = append(deps, d)
+	for i := range deps {
+		deps[i].Type = constants.DependencyTypeNode
 	}
 	return deps, nil
 }
 
 func (svc *NodeService) InstallDependencies(params entity.InstallParams) (err error) {
+	// package manager
+	pm, err := svc._getPackageManager(params.Cmd)
+	if err != nil {
+		return err
+	}
+
 	// arguments
 	var args []string
 
-	// install
-	args = append(args, "install")
-
-	// global
-	args = append(args, "-g")
-
-	// proxy
-	if params.Proxy != "" {
-		args = append(args, "--registry")
-		args = append(args, params.Proxy)
-	}
-
 	if params.UseConfig {
-		// use config
-	} else {
-		// dependency names
-		for _, depName := range params.Names {
-			// upgrade
-			if params.Upgrade {
-				depName = depName + "@latest"
-			}
-
-			args = append(args, depName)
+		// workspace path
+		workspacePath, err := svc._getInstallWorkspacePath(params)
+		if err != nil {
+			return err
 		}
-	}
 
-	// command
-	cmd := exec.Command(params.Cmd, args...)
+		// install by package.json, or by lock file if present
+		args = append(args, pm.getLocalInstallArgs(workspacePath)...)
+		args = append(args, pm.getRegistryArgs(params.Proxy)...)
 
-	// logging
-	svc.parent._configureLogging(params.TaskId, cmd)
+		// command
+		cmd := svc._getCommand(pm, params.Cmd, args, params.Proxy)
+		cmd.Dir = workspacePath
 
-	// start
-	if err := cmd.Start(); err != nil {
-		return trace.TraceError(err)
+		return svc._runCmd(params.TaskId, cmd)
 	}
 
-	// wait
-	if err := cmd.Wait(); err != nil {
-		return trace.TraceError(err)
+	// validate
+	if !pm.global {
+		return trace.TraceError(errors.New(fmt.Sprintf("global packages are not supported by %s", pm.name)))
 	}
 
-	return nil
+	// install
+	args = append(args, pm.installArgs...)
+
+	// proxy
+	args = append(args, pm.getRegistryArgs(params.Proxy)...)
+
+	// dependency names with version specs, e.g. "lodash@^4.17.0"
+	for _, pkg := range params.Packages {
+		switch {
+		case pkg.Spec != "":
+			args = append(args, pkg.Name+"@"+pkg.Spec)
+		case params.Upgrade:
+			args = append(args, pkg.Name+"@latest")
+		default:
+			args = append(args, pkg.Name)
+		}
+	}
+
+	// run
+	return svc._runCmd(params.TaskId, svc._getCommand(pm, params.Cmd, args, params.Proxy))
 }
 
 func (svc *NodeService) UninstallDependencies(params entity.UninstallParams) (err error) {
+	// package manager
+	pm, err := svc._getPackageManager(params.Cmd)
+	if err != nil {
+		return err
+	}
+
 	// arguments
 	var args []string
 
+	if params.Local {
+		// spider fs service
+		fsSvc, err := fs.NewSpiderFsService(params.SpiderId)
+		if err != nil {
+			return err
+		}
+
+		// uninstall from package.json and node_modules of workspace
+		args = append(args, pm.localUninstallArgs...)
+		args = append(args, params.Names...)
+
+		// command
+		cmd := svc._getCommand(pm, params.Cmd, args, "")
+		cmd.Dir = fsSvc.GetWorkspacePath()
+
+		return svc._runCmd(params.TaskId, cmd)
+	}
+
+	// validate
+	if !pm.global {
+		return trace.TraceError(errors.New(fmt.Sprintf("global packages are not supported by %s", pm.name)))
+	}
+
 	// uninstall
-	args = append(args, "uninstall")
-	args = append(args, "-g")
+	args = append(args, pm.uninstallArgs...)
 
 	// dependency names
 	for _, depName := range params.Names {
 		args = append(args, depName)
 	}
 
-	// command
-	cmd := exec.Command(params.Cmd, args...)
+	// run
+	return svc._runCmd(params.TaskId, svc._getCommand(pm, params.Cmd, args, ""))
+}
 
-	// logging
-	svc.parent._configureLogging(params.TaskId, cmd)
+// GetLocalDependencies returns packages installed in node_modules of the
+// spider workspace, which are reported separately from global packages
+func (svc *NodeService) GetLocalDependencies(params entity.UpdateParams) (deps []models.Dependency, err error) {
+	// spider fs service
+	fsSvc, err := fs.NewSpiderFsService(params.SpiderId)
+	if err != nil {
+		return nil, err
+	}
 
-	// start
-	if err := cmd.Start(); err != nil {
-		return trace.TraceError(err)
+	// installed packages
+	deps, err = readNodeModulesDependencies(fsSvc.GetWorkspacePath(), true)
+	if err != nil {
+		return nil, err
+	}
+	for i := range deps {
+		deps[i].Type = constants.DependencyTypeNode
 	}
+	return deps, nil
+}
 
-	// wait
-	if err := cmd.Wait(); err != nil {
-		return trace.TraceError(err)
+// GetManifestDependencies returns packages declared in package.json with
+// their semver ranges, e.g. "axios": "^0.24.0"
+func (svc *NodeService) GetManifestDependencies(workspacePath, manifest string) (deps []models.Dependency, parseErrors []entity.ManifestError, err error) {
+	// package.json
+	packageJson, err := readPackageJson(filepath.Join(workspacePath, manifest))
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return nil
+	// dependencies and dev dependencies
+	for _, packages := range []map[string]string{packageJson.Dependencies, packageJson.DevDependencies} {
+		var names []string
+		for name := range packages {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			deps = append(deps, models.Dependency{
+				Name:    name,
+				Version: packages[name],
+			})
+		}
+	}
+
+	return deps, nil, nil
 }
 
 func (svc *NodeService) GetLatestVersion(dep models.Dependency) (v string, err error) {
-	// not exists in cache, request from pypi
-	reqSession := req.New()
-
-	// set timeout
-	reqSession.SetTimeout(60 * time.Second)
-
-	// user agent
-	ua := req.Header{"user-agent": "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_14_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/94.0.4606.61 Safari/537.36"}
+	return svc._getRegistryClient().GetLatestVersion(dep.Name)
+}
 
-	// request url
-	requestUrl := fmt.Sprintf("https://api.npms.io/v2/package/%s", dep.Name)
+// ValidateVersionSpec validates npm range, e.g. "^4.17.0" or "4.17.21", or
+// dist-tag, e.g. "next"
+func (svc *NodeService) ValidateVersionSpec(spec string) (err error) {
+	if _, err := parseNpmRange(spec); err == nil {
+		return nil
+	}
+	if !npmDistTagPattern.MatchString(spec) {
+		return errors.New(fmt.Sprintf("invalid npm range or dist-tag: %s", spec))
+	}
+	return nil
+}
 
-	// perform request
-	res, err := reqSession.Get(requestUrl, ua)
+// CompareVersions compares semantic versions, which may be prefixed with "v"
+func (svc *NodeService) CompareVersions(v1, v2 string) (res int, err error) {
+	sv1, err := semver.ParseTolerant(v1)
+	if err != nil {
+		return 0, trace.TraceError(err)
+	}
+	sv2, err := semver.ParseTolerant(v2)
 	if err != nil {
-		return "", trace.TraceError(err)
+		return 0, trace.TraceError(err)
+	}
+	return sv1.Compare(sv2), nil
+}
+
+// _getPackageManager returns the package manager of the command, where
+// yarn berry is distinguished from yarn classic by its version
+func (svc *NodeService) _getPackageManager(cmd string) (pm nodePackageManager, err error) {
+	// name of command, e.g. "/usr/local/bin/yarn" or "pnpm.cmd"
+	name := filepath.Base(cmd)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+
+	// yarn version
+	if name == constants.NodePackageManagerYarn {
+		data, err := exec.Command(cmd, "--version").Output()
+		if err != nil {
+			return pm, trace.TraceError(err)
+		}
+		if v := strings.TrimSpace(string(data)); !strings.HasPrefix(v, "0.") && !strings.HasPrefix(v, "1.") {
+			name = constants.NodePackageManagerYarnBerry
+		}
 	}
 
-	// response
-	var npmRes entity.NpmResponseDetail
-	if err := res.ToJSON(&npmRes); err != nil {
-		return "", trace.TraceError(err)
+	for _, pm := range nodePackageManagers {
+		if pm.name == name {
+			return pm, nil
+		}
 	}
 
-	// version
-	v = npmRes.Collected.Metadata.Version
+	// npm-compatible by default
+	return nodePackageManagers[0], nil
+}
 
-	return v, nil
+func (svc *NodeService) _getCommand(pm nodePackageManager, name string, args []string, registry string) (cmd *exec.Cmd) {
+	cmd = exec.Command(name, args...)
+	if env := pm.getRegistryEnv(registry); len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	return cmd
 }
 
 func NewNodeService(parent *Service) (svc *NodeService) {
@@ -292,7 +336,17 @@ func NewNodeService(parent *Service) (svc *NodeService) {
 			Install:   constants.MessageCodeNodeInstall,
 			Uninstall: constants.MessageCodeNodeUninstall,
 		},
+		models.Setting{
+			Name:        "Node.js",
+			Description: "settings.description.node",
+			Cmd:         "npm",
+			Enabled:     true,
+		},
 	)
+	baseSvc.newRegistryClient = func(s models.Setting) (client RegistryClient) {
+		return NewNpmRegistryClient(s.Proxy)
+	}
+	baseSvc.manifests = []string{constants.DependencyConfigPackageJson}
 	svc.baseService = baseSvc
 	return svc
 }
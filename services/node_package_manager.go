@@ -0,0 +1,222 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/crawlab-team/crawlab-core/utils"
+	"github.com/crawlab-team/go-trace"
+	"github.com/crawlab-team/plugin-dependency/constants"
+	"github.com/crawlab-team/plugin-dependency/entity"
+	"github.com/crawlab-team/plugin-dependency/models"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// nodePackageManager describes how a Node.js package manager lists,
+// installs and uninstalls global packages, and installs and uninstalls
+// packages of a project, strictly from its lock file if present. Args do not include
+// the command, which is taken from setting.
+type nodePackageManager struct {
+	name               string
+	global             bool
+	listArgs           []string
+	parseList          func(data []byte) (deps []models.Dependency, err error)
+	installArgs        []string
+	uninstallArgs      []string
+	localInstallArgs   []string
+	localUninstallArgs []string
+	lockFile           string
+	lockedInstallArgs  []string
+	registryFlag       string
+	registryEnv        string
+}
+
+var nodePackageManagers = []nodePackageManager{
+	{
+		name:               constants.NodePackageManagerNpm,
+		global:             true,
+		listArgs:           []string{"list", "-g", "--json", "--depth", "0"},
+		parseList:          parseNpmList,
+		installArgs:        []string{"install", "-g"},
+		uninstallArgs:      []string{"uninstall", "-g"},
+		localInstallArgs:   []string{"install"},
+		localUninstallArgs: []string{"uninstall"},
+		lockFile:           constants.DependencyConfigPackageLockJson,
+		lockedInstallArgs:  []string{"ci"},
+		registryFlag:       "--registry",
+	},
+	{
+		// yarn classic (v1) keeps global packages in a project
+		// under "yarn global dir"
+		name:               constants.NodePackageManagerYarn,
+		global:             true,
+		listArgs:           []string{"global", "dir"},
+		parseList:          parseYarnGlobalDir,
+		installArgs:        []string{"global", "add"},
+		uninstallArgs:      []string{"global", "remove"},
+		localInstallArgs:   []string{"install", "--non-interactive"},
+		localUninstallArgs: []string{"remove", "--non-interactive"},
+		lockFile:           constants.DependencyConfigYarnLock,
+		lockedInstallArgs:  []string{"install", "--non-interactive", "--frozen-lockfile"},
+		registryFlag:       "--registry",
+	},
+	{
+		// yarn berry (v2+) has no global packages, and reads
+		// registry from environment variables instead of flags
+		name:               constants.NodePackageManagerYarnBerry,
+		global:             false,
+		localInstallArgs:   []string{"install"},
+		localUninstallArgs: []string{"remove"},
+		lockFile:           constants.DependencyConfigYarnLock,
+		lockedInstallArgs:  []string{"install", "--immutable"},
+		registryEnv:        "YARN_NPM_REGISTRY_SERVER",
+	},
+	{
+		name:               constants.NodePackageManagerPnpm,
+		global:             true,
+		listArgs:           []string{"list", "-g", "--json", "--depth", "0"},
+		parseList:          parsePnpmList,
+		installArgs:        []string{"add", "-g"},
+		uninstallArgs:      []string{"remove", "-g"},
+		localInstallArgs:   []string{"install"},
+		localUninstallArgs: []string{"remove"},
+		lockFile:           constants.DependencyConfigPnpmLockYaml,
+		lockedInstallArgs:  []string{"install", "--frozen-lockfile"},
+		registryFlag:       "--registry",
+	},
+}
+
+// getLocalInstallArgs returns arguments to install packages of the project
+// in the directory, which are strictly from lock file if present
+func (pm nodePackageManager) getLocalInstallArgs(dir string) (args []string) {
+	if pm.lockFile != "" && utils.Exists(filepath.Join(dir, pm.lockFile)) {
+		return pm.lockedInstallArgs
+	}
+	return pm.localInstallArgs
+}
+
+// getRegistryArgs returns arguments to override registry
+func (pm nodePackageManager) getRegistryArgs(registry string) (args []string) {
+	if registry == "" || pm.registryFlag == "" {
+		return nil
+	}
+	return []string{pm.registryFlag, registry}
+}
+
+// getRegistryEnv returns environment variables to override registry
+func (pm nodePackageManager) getRegistryEnv(registry string) (env []string) {
+	if registry == "" || pm.registryEnv == "" {
+		return nil
+	}
+	return []string{pm.registryEnv + "=" + registry}
+}
+
+// parseNpmList parses output of "npm list -g --json --depth 0", e.g.
+// {"dependencies": {"typescript": {"version": "4.5.4"}}}
+func parseNpmList(data []byte) (deps []models.Dependency, err error) {
+	var res entity.NpmListResult
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, trace.TraceError(err)
+	}
+	return getNpmListDependencies(res), nil
+}
+
+// parsePnpmList parses output of "pnpm list -g --json --depth 0", which
+// is an array of npm-like list results, one for each project
+func parsePnpmList(data []byte) (deps []models.Dependency, err error) {
+	var res []entity.NpmListResult
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, trace.TraceError(err)
+	}
+	for _, r := range res {
+		deps = append(deps, getNpmListDependencies(r)...)
+	}
+	return deps, nil
+}
+
+// parseYarnGlobalDir parses output of "yarn global dir", and reads
+// installed packages from package.json of the global directory
+func parseYarnGlobalDir(data []byte) (deps []models.Dependency, err error) {
+	// global directory
+	dir := strings.TrimSpace(string(data))
+	if dir == "" {
+		return nil, trace.TraceError(errors.New("empty yarn global dir"))
+	}
+
+	return readNodeModulesDependencies(dir, false)
+}
+
+// readNodeModulesDependencies reads packages declared in package.json of the
+// directory, with versions installed in its node_modules
+func readNodeModulesDependencies(dir string, dev bool) (deps []models.Dependency, err error) {
+	// package.json of directory
+	packageJsonPath := filepath.Join(dir, constants.DependencyConfigPackageJson)
+	if !utils.Exists(packageJsonPath) {
+		return nil, nil
+	}
+	packageJson, err := readPackageJson(packageJsonPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// declared packages
+	var names []string
+	for name := range packageJson.Dependencies {
+		names = append(names, name)
+	}
+	if dev {
+		for name := range packageJson.DevDependencies {
+			if _, ok := packageJson.Dependencies[name]; !ok {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	// installed versions
+	for _, name := range names {
+		// skip if not installed
+		filePath := filepath.Join(dir, "node_modules", name, constants.DependencyConfigPackageJson)
+		if !utils.Exists(filePath) {
+			continue
+		}
+		p, err := readPackageJson(filePath)
+		if err != nil {
+			return nil, err
+		}
+		deps = append(deps, models.Dependency{
+			Name:    name,
+			Version: p.Version,
+		})
+	}
+
+	return deps, nil
+}
+
+func getNpmListDependencies(res entity.NpmListResult) (deps []models.Dependency) {
+	var names []string
+	for name := range res.Dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		deps = append(deps, models.Dependency{
+			Name:    name,
+			Version: res.Dependencies[name].Version,
+		})
+	}
+	return deps
+}
+
+func readPackageJson(filePath string) (p entity.NpmPackageJson, err error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return p, trace.TraceError(err)
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return p, trace.TraceError(err)
+	}
+	return p, nil
+}
@@ -0,0 +1,328 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/blang/semver/v4"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// npmComparator is a primitive comparator of npm range, e.g. ">=1.2.0"
+type npmComparator struct {
+	op string
+	v  semver.Version
+}
+
+// npmRange is a npm version range, which is a union of comparator sets,
+// each of which is an intersection of primitive comparators, e.g.
+// "^1.2.0 || >=3 <4" is parsed as [[>=1.2.0 <2.0.0-0] [>=3.0.0 <4.0.0-0]]
+type npmRange [][]npmComparator
+
+// npmPartial is a partial version in npm range, e.g. "1", "1.2.x" or "1.2.3-beta.1",
+// where parts is the number of specified components before the first wildcard
+type npmPartial struct {
+	major int
+	minor int
+	patch int
+	pre   string
+	parts int
+}
+
+var npmHyphenPattern = regexp.MustCompile(`^\s*(\S+)\s+-\s+(\S+)\s*$`)
+var npmOperatorSpacePattern = regexp.MustCompile(`(<=|>=|<|>|=|~>|~|\^)\s+`)
+var npmComparatorPattern = regexp.MustCompile(`^(<=|>=|<|>|=|~>|~|\^)?(.*)$`)
+
+// parseNpmRange parses npm range, e.g. "^1.2.0", "~2.x", "1.2 - 1.4" or
+// ">=1 <3 || 4.x", where empty range and "*" match any version
+func parseNpmRange(s string) (r npmRange, err error) {
+	for _, part := range strings.Split(s, "||") {
+		set, err := parseNpmComparatorSet(part)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, set)
+	}
+	return r, nil
+}
+
+func parseNpmComparatorSet(s string) (set []npmComparator, err error) {
+	s = strings.TrimSpace(s)
+
+	// hyphen range, e.g. "1.2.3 - 2.3.4"
+	if matches := npmHyphenPattern.FindStringSubmatch(s); len(matches) == 3 {
+		from, err := parseNpmPartial(matches[1])
+		if err != nil {
+			return nil, err
+		}
+		to, err := parseNpmPartial(matches[2])
+		if err != nil {
+			return nil, err
+		}
+		if from.parts > 0 {
+			set = append(set, npmComparator{">=", from.floor()})
+		}
+		if to.parts == 3 {
+			set = append(set, npmComparator{"<=", to.floor()})
+		} else if to.parts > 0 {
+			set = append(set, npmComparator{"<", to.ceil()})
+		}
+		return set, nil
+	}
+
+	// primitive comparators, e.g. ">= 1.2.0 < 2"
+	s = npmOperatorSpacePattern.ReplaceAllString(s, "$1")
+	for _, token := range strings.Fields(s) {
+		comparators, err := parseNpmComparator(token)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, comparators...)
+	}
+	return set, nil
+}
+
+func parseNpmComparator(token string) (comparators []npmComparator, err error) {
+	matches := npmComparatorPattern.FindStringSubmatch(token)
+	op := matches[1]
+	p, err := parseNpmPartial(matches[2])
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "", "=":
+		if p.parts == 0 {
+			return nil, nil
+		}
+		if p.parts == 3 {
+			return []npmComparator{{"=", p.floor()}}, nil
+		}
+		return []npmComparator{{">=", p.floor()}, {"<", p.ceil()}}, nil
+	case "~", "~>":
+		// allows patch changes if minor is specified, minor changes otherwise
+		if p.parts == 0 {
+			return nil, nil
+		}
+		upper := npmPartial{major: p.major, minor: p.minor, parts: 2}
+		if p.parts == 1 {
+			upper.parts = 1
+		}
+		return []npmComparator{{">=", p.floor()}, {"<", upper.ceil()}}, nil
+	case "^":
+		// allows changes that do not modify the left-most non-zero component
+		if p.parts == 0 {
+			return nil, nil
+		}
+		upper := npmPartial{major: p.major, parts: 1}
+		if p.major == 0 && p.parts >= 2 {
+			upper = npmPartial{minor: p.minor, parts: 2}
+			if p.minor == 0 && p.parts == 3 {
+				upper = npmPartial{patch: p.patch, parts: 3}
+			}
+		}
+		return []npmComparator{{">=", p.floor()}, {"<", upper.ceil()}}, nil
+	case ">":
+		if p.parts == 0 {
+			return []npmComparator{{"<", npmVersion(0, 0, 0, "0")}}, nil
+		}
+		if p.parts == 3 {
+			return []npmComparator{{">", p.floor()}}, nil
+		}
+		return []npmComparator{{">=", p.ceil()}}, nil
+	case ">=":
+		if p.parts == 0 {
+			return nil, nil
+		}
+		return []npmComparator{{">=", p.floor()}}, nil
+	case "<":
+		if p.parts == 0 {
+			return []npmComparator{{"<", npmVersion(0, 0, 0, "0")}}, nil
+		}
+		if p.parts == 3 {
+			return []npmComparator{{"<", p.floor()}}, nil
+		}
+		return []npmComparator{{"<", npmVersion(p.major, p.minor, p.patch, "0")}}, nil
+	case "<=":
+		if p.parts == 0 {
+			return nil, nil
+		}
+		if p.parts == 3 {
+			return []npmComparator{{"<=", p.floor()}}, nil
+		}
+		return []npmComparator{{"<", p.ceil()}}, nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("invalid npm comparator: %s", token))
+}
+
+// parseNpmPartial parses partial version, where missing components and
+// wildcards "x", "X" and "*" are treated the same
+func parseNpmPartial(s string) (p npmPartial, err error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), "="), "v")
+
+	// build metadata and pre-release
+	if i := strings.Index(s, "+"); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.Index(s, "-"); i >= 0 {
+		p.pre = s[i+1:]
+		s = s[:i]
+	}
+
+	// components
+	if s == "" {
+		return p, nil
+	}
+	components := strings.Split(s, ".")
+	if len(components) > 3 {
+		return p, errors.New(fmt.Sprintf("invalid npm version: %s", s))
+	}
+	values := []*int{&p.major, &p.minor, &p.patch}
+	for i, c := range components {
+		if c == "x" || c == "X" || c == "*" {
+			break
+		}
+		n, err := strconv.Atoi(c)
+		if err != nil || n < 0 {
+			return p, errors.New(fmt.Sprintf("invalid npm version: %s", s))
+		}
+		*values[i] = n
+		p.parts = i + 1
+	}
+	if p.parts < 3 {
+		p.pre = ""
+	}
+	return p, nil
+}
+
+// floor returns the lowest version matching the partial version
+func (p npmPartial) floor() (v semver.Version) {
+	return npmVersion(p.major, p.minor, p.patch, p.pre)
+}
+
+// ceil returns the lowest version above the partial version, which
+// excludes pre-releases of the next version, e.g. "2.0.0-0" for "1.x"
+func (p npmPartial) ceil() (v semver.Version) {
+	switch p.parts {
+	case 1:
+		return npmVersion(p.major+1, 0, 0, "0")
+	case 2:
+		return npmVersion(p.major, p.minor+1, 0, "0")
+	default:
+		return npmVersion(p.major, p.minor, p.patch+1, "0")
+	}
+}
+
+func npmVersion(major, minor, patch int, pre string) (v semver.Version) {
+	v = semver.Version{
+		Major: uint64(major),
+		Minor: uint64(minor),
+		Patch: uint64(patch),
+	}
+	if pre != "" {
+		for _, s := range strings.Split(pre, ".") {
+			prv, err := semver.NewPRVersion(s)
+			if err != nil {
+				continue
+			}
+			v.Pre = append(v.Pre, prv)
+		}
+	}
+	return v
+}
+
+// test returns true if the version satisfies the range, where pre-release
+// versions only satisfy comparator sets with a pre-release of the same
+// major, minor and patch
+func (r npmRange) test(v semver.Version) (res bool) {
+	for _, set := range r {
+		if npmTestComparatorSet(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLower returns true if the version is lower than the lower bound of
+// any comparator set of the range, i.e. upgrading would satisfy the range
+func (r npmRange) isLower(v semver.Version) (res bool) {
+	for _, set := range r {
+		for _, c := range set {
+			if (c.op == ">" || c.op == ">=" || c.op == "=") && !c.test(v) && v.LT(c.v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// maxSatisfying returns the highest version satisfying the range, or
+// empty string if none of the versions satisfies it
+func (r npmRange) maxSatisfying(versions []string) (res string) {
+	var max semver.Version
+	for _, s := range versions {
+		v, err := semver.ParseTolerant(s)
+		if err != nil || !r.test(v) {
+			continue
+		}
+		if res == "" || v.GT(max) {
+			max = v
+			res = s
+		}
+	}
+	return res
+}
+
+// sortNpmVersions sorts semantic versions in ascending order, where
+// invalid versions are sorted first
+func sortNpmVersions(versions []string) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		vi, errI := semver.ParseTolerant(versions[i])
+		vj, errJ := semver.ParseTolerant(versions[j])
+		if errI != nil || errJ != nil {
+			return errI != nil && errJ == nil
+		}
+		return vi.LT(vj)
+	})
+}
+
+func npmTestComparatorSet(set []npmComparator, v semver.Version) (res bool) {
+	for _, c := range set {
+		if !c.test(v) {
+			return false
+		}
+	}
+	if len(v.Pre) == 0 {
+		return true
+	}
+	for _, c := range set {
+		if len(c.v.Pre) > 0 && c.v.Major == v.Major && c.v.Minor == v.Minor && c.v.Patch == v.Patch {
+			// "-0" bounds derived from partial versions are not explicit
+			// pre-releases, e.g. "<2.0.0-0" of "^1" or ">=2.0.0-0" of ">1"
+			if (c.op == "<" || c.op == ">=") && len(c.v.Pre) == 1 && c.v.Pre[0].IsNum && c.v.Pre[0].VersionNum == 0 {
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func (c npmComparator) test(v semver.Version) (res bool) {
+	switch c.op {
+	case "=":
+		return v.EQ(c.v)
+	case ">":
+		return v.GT(c.v)
+	case ">=":
+		return v.GTE(c.v)
+	case "<":
+		return v.LT(c.v)
+	case "<=":
+		return v.LTE(c.v)
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/blang/semver/v4"
+)
+
+func TestNpmRange_Test(t *testing.T) {
+	cases := []struct {
+		r       string
+		version string
+		res     bool
+	}{
+		// caret
+		{"^1.2.3", "1.9.0", true},
+		{"^1.2.3", "2.0.0", false},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.0.3", "0.0.3", true},
+		{"^0.0.3", "0.0.4", false},
+
+		// tilde
+		{"~1.2", "1.2.9", true},
+		{"~1.2", "1.3.0", false},
+		{"~1", "1.9.0", true},
+		{"~1.2.3", "1.2.2", false},
+
+		// hyphen
+		{"1.2 - 1.4", "1.2.0", true},
+		{"1.2 - 1.4", "1.4.9", true},
+		{"1.2 - 1.4", "1.5.0", false},
+		{"1.2.3 - 1.4.5", "1.4.6", false},
+
+		// union
+		{"^1.0.0 || >=3 <4", "1.5.0", true},
+		{"^1.0.0 || >=3 <4", "2.0.0", false},
+		{"^1.0.0 || >=3 <4", "3.1.0", true},
+
+		// partial comparators
+		{">1", "2.0.0", true},
+		{">1", "1.9.9", false},
+		{"<=1.2", "1.2.9", true},
+		{"<1.2", "1.1.9", true},
+		{"*", "0.0.1", true},
+		{"1.x", "1.4.0", true},
+
+		// pre-releases only match sets with a pre-release of the same version
+		{"^1.2.3-beta.1", "1.2.3-beta.2", true},
+		{"^1.2.3-beta.1", "1.2.4-beta.1", false},
+		{">=1.0.0-rc.1 <2", "1.0.0-rc.2", true},
+		{"^1.0.0", "2.0.0-beta.1", false},
+		{"^1.0.0", "1.5.0-beta.1", false},
+		{">1", "2.0.0-beta.1", false},
+		{"1.x", "2.0.0-0", false},
+		{"<2", "2.0.0-beta.1", false},
+	}
+	for _, c := range cases {
+		r, err := parseNpmRange(c.r)
+		if err != nil {
+			t.Fatalf("parse %s: %v", c.r, err)
+		}
+		if res := r.test(semver.MustParse(c.version)); res != c.res {
+			t.Errorf("%s matches %s: expected %t, got %t", c.r, c.version, c.res, res)
+		}
+	}
+}
+
+func TestNpmRange_MaxSatisfying(t *testing.T) {
+	versions := []string{"1.0.0", "1.2.0", "1.10.0", "2.0.0-beta.1", "2.0.0", "2.1.0"}
+	cases := map[string]string{
+		"^1.0.0": "1.10.0",
+		"~1.2":   "1.2.0",
+		">1":     "2.1.0",
+		"<2":     "1.10.0",
+		"^3":     "",
+	}
+	for spec, expected := range cases {
+		r, err := parseNpmRange(spec)
+		if err != nil {
+			t.Fatalf("parse %s: %v", spec, err)
+		}
+		if res := r.maxSatisfying(versions); res != expected {
+			t.Errorf("max satisfying %s: expected %q, got %q", spec, expected, res)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"errors"
+	constants2 "github.com/crawlab-team/crawlab-core/constants"
+	"github.com/crawlab-team/crawlab-core/controllers"
+	mongo2 "github.com/crawlab-team/crawlab-db/mongo"
+	"github.com/crawlab-team/go-trace"
+	"github.com/crawlab-team/plugin-dependency/constants"
+	"github.com/crawlab-team/plugin-dependency/entity"
+	"github.com/crawlab-team/plugin-dependency/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type OperationService struct {
+	parent *Service
+	api    *gin.Engine
+}
+
+func (svc *OperationService) Init() {
+	svc.api.GET("/operations/:id", svc.getOperation)
+	svc.api.POST("/operations/:id/retry", svc.retry)
+}
+
+func (svc *OperationService) getOperation(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		controllers.HandleErrorBadRequest(c, err)
+		return
+	}
+
+	// operation
+	var op models.Operation
+	if err := svc.parent.colO.FindId(id).One(&op); err != nil {
+		controllers.HandleErrorNotFound(c, err)
+		return
+	}
+
+	// status on each node
+	nodes, err := svc._getNodes(op)
+	if err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+	op.Status = svc._getStatus(nodes)
+
+	controllers.HandleSuccessWithData(c, models.OperationDetail{
+		Operation: op,
+		Nodes:     nodes,
+	})
+}
+
+// retry sends the failed or cancelled tasks of operation again to their
+// nodes, including nodes which the task was not sent to, where succeeded
+// nodes are not affected
+func (svc *OperationService) retry(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		controllers.HandleErrorBadRequest(c, err)
+		return
+	}
+
+	// operation
+	var op models.Operation
+	if err := svc.parent.colO.FindId(id).One(&op); err != nil {
+		controllers.HandleErrorNotFound(c, err)
+		return
+	}
+
+	// failed nodes
+	nodes, err := svc._getNodes(op)
+	if err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+	var failedNodes []entity.OperationNode
+	for _, n := range nodes {
+		if svc._isFailed(n.Status) {
+			failedNodes = append(failedNodes, n)
+		}
+	}
+	if len(failedNodes) == 0 {
+		controllers.HandleErrorBadRequest(c, errors.New("no failed nodes to retry"))
+		return
+	}
+
+	// dependency provider
+	p, err := svc.parent.getProvider(op.Type)
+	if err != nil {
+		controllers.HandleErrorBadRequest(c, err)
+		return
+	}
+
+	// retry
+	if err := p._retryOperation(&op, failedNodes); err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// status
+	if err := svc.updateStatus(op.Id); err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	controllers.HandleSuccess(c)
+}
+
+// updateStatus aggregates statuses of tasks of the operation on nodes
+func (svc *OperationService) updateStatus(id primitive.ObjectID) (err error) {
+	var op models.Operation
+	if err := svc.parent.colO.FindId(id).One(&op); err != nil {
+		return trace.TraceError(err)
+	}
+	nodes, err := svc._getNodes(op)
+	if err != nil {
+		return err
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":    svc._getStatus(nodes),
+			"update_ts": time.Now(),
+		},
+	}
+	if err := svc.parent.colO.UpdateId(id, update); err != nil {
+		return trace.TraceError(err)
+	}
+	return nil
+}
+
+// _getNodes returns status of the operation on each node of the operation,
+// which is of the latest task of the node, or failed if the task has not
+// been sent to the node
+func (svc *OperationService) _getNodes(op models.Operation) (nodes []entity.OperationNode, err error) {
+	// tasks
+	var tasks []models.Task
+	opts := &mongo2.FindOptions{
+		Sort: bson.D{{"_id", 1}},
+	}
+	if err := svc.parent.colT.Find(bson.M{"operation_id": op.Id}, opts).All(&tasks); err != nil {
+		return nil, trace.TraceError(err)
+	}
+
+	// nodes of operation
+	indexes := map[primitive.ObjectID]int{}
+	for _, nodeId := range op.NodeIds {
+		if _, ok := indexes[nodeId]; ok {
+			continue
+		}
+		indexes[nodeId] = len(nodes)
+		nodes = append(nodes, entity.OperationNode{
+			NodeId: nodeId,
+			Status: constants2.TaskStatusError,
+			Error:  constants.OperationErrorTaskNotSent,
+		})
+	}
+
+	// latest task of each node
+	for _, t := range tasks {
+		i, ok := indexes[t.NodeId]
+		if !ok {
+			nodes = append(nodes, entity.OperationNode{})
+			i = len(nodes) - 1
+			indexes[t.NodeId] = i
+		}
+		nodes[i] = entity.OperationNode{
+			NodeId:   t.NodeId,
+			TaskId:   t.Id,
+			Status:   t.Status,
+			Error:    t.Error,
+			DepNames: t.DepNames,
+			Attempts: nodes[i].Attempts + 1,
+		}
+	}
+
+	return nodes, nil
+}
+
+// _getStatus returns the aggregated status of operation, which is running
+// until tasks on all nodes are done, and failed if there is no node
+func (svc *OperationService) _getStatus(nodes []entity.OperationNode) (status string) {
+	succeeded := 0
+	for _, n := range nodes {
+		switch {
+		case n.Status == constants.TaskStatusQueued || n.Status == constants2.TaskStatusRunning:
+			return constants.OperationStatusRunning
+		case n.Status == constants2.TaskStatusFinished:
+			succeeded++
+		}
+	}
+	switch {
+	case succeeded == 0:
+		return constants.OperationStatusFailure
+	case succeeded == len(nodes):
+		return constants.OperationStatusSuccess
+	default:
+		return constants.OperationStatusPartialFailure
+	}
+}
+
+func (svc *OperationService) _isFailed(status string) (res bool) {
+	return status == constants2.TaskStatusError || status == constants2.TaskStatusCancelled
+}
+
+func NewOperationService(parent *Service) (svc *OperationService) {
+	svc = &OperationService{
+		parent: parent,
+		api:    parent.GetApi(),
+	}
+
+	return svc
+}
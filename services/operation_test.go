@@ -0,0 +1,85 @@
+package services
+
+import (
+	"testing"
+
+	constants2 "github.com/crawlab-team/crawlab-core/constants"
+	mongo2 "github.com/crawlab-team/crawlab-db/mongo"
+	"github.com/crawlab-team/plugin-dependency/constants"
+	"github.com/crawlab-team/plugin-dependency/entity"
+	"github.com/crawlab-team/plugin-dependency/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestOperationService_GetStatus(t *testing.T) {
+	notSent := entity.OperationNode{Status: constants2.TaskStatusError, Error: constants.OperationErrorTaskNotSent}
+	cases := []struct {
+		name     string
+		statuses []string
+		nodes    []entity.OperationNode
+		status   string
+	}{
+		{"no nodes", nil, nil, constants.OperationStatusFailure},
+		{"all finished", []string{constants2.TaskStatusFinished, constants2.TaskStatusFinished}, nil, constants.OperationStatusSuccess},
+		{"queued", []string{constants2.TaskStatusFinished, constants.TaskStatusQueued}, nil, constants.OperationStatusRunning},
+		{"running", []string{constants2.TaskStatusError, constants2.TaskStatusRunning}, nil, constants.OperationStatusRunning},
+		{"partial", []string{constants2.TaskStatusFinished, constants2.TaskStatusCancelled}, nil, constants.OperationStatusPartialFailure},
+		{"all failed", []string{constants2.TaskStatusError, constants2.TaskStatusCancelled}, nil, constants.OperationStatusFailure},
+		{"not sent", []string{constants2.TaskStatusFinished}, []entity.OperationNode{notSent}, constants.OperationStatusPartialFailure},
+		{"none sent", nil, []entity.OperationNode{notSent, notSent}, constants.OperationStatusFailure},
+	}
+	svc := &OperationService{}
+	for _, c := range cases {
+		nodes := c.nodes
+		for _, s := range c.statuses {
+			nodes = append(nodes, entity.OperationNode{Status: s})
+		}
+		if status := svc._getStatus(nodes); status != c.status {
+			t.Errorf("%s: expected %s, got %s", c.name, c.status, status)
+		}
+	}
+	if !svc._isFailed(notSent.Status) {
+		t.Error("node which the task is not sent to is not retried")
+	}
+}
+
+func TestBaseService_InsertFinishedTask(t *testing.T) {
+	parent := newRegistryTestService(t)
+	parent.colT = mongo2.GetMongoColWithDb(constants.DependencyTasksColName, registryTestDbName)
+	t.Cleanup(func() {
+		_ = parent.colT.Delete(bson.M{})
+	})
+
+	// uninstall operation on a node which the task is not sent to
+	p := &baseService{parent: parent, key: constants.DependencyTypePython}
+	op := &models.Operation{
+		Id:      primitive.NewObjectID(),
+		Type:    p.key,
+		Action:  constants.ActionUninstall,
+		NodeIds: []primitive.ObjectID{primitive.NewObjectID()},
+	}
+	opSvc := &OperationService{parent: parent}
+	nodes, err := opSvc._getNodes(*op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || !opSvc._isFailed(nodes[0].Status) {
+		t.Fatalf("expected node to retry, got %v", nodes)
+	}
+
+	// nothing to uninstall on the node
+	if err := p._insertFinishedTask(op, op.NodeIds[0]); err != nil {
+		t.Fatal(err)
+	}
+	nodes, err = opSvc._getNodes(*op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes[0].Status != constants2.TaskStatusFinished || nodes[0].Error != "" {
+		t.Errorf("expected finished node, got %v", nodes)
+	}
+	if status := opSvc._getStatus(nodes); status != constants.OperationStatusSuccess {
+		t.Errorf("expected %s, got %s", constants.OperationStatusSuccess, status)
+	}
+}
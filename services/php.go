@@ -0,0 +1,297 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/blang/semver/v4"
+	"github.com/crawlab-team/crawlab-core/controllers"
+	"github.com/crawlab-team/go-trace"
+	"github.com/crawlab-team/plugin-dependency/constants"
+	"github.com/crawlab-team/plugin-dependency/entity"
+	"github.com/crawlab-team/plugin-dependency/models"
+	"github.com/gin-gonic/gin"
+	"github.com/imroc/req"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type PhpService struct {
+	*baseService
+}
+
+func (svc *PhpService) GetRepoList(c *gin.Context) {
+	// query
+	query := c.Query("query")
+	pagination := controllers.MustGetPagination(c)
+
+	// validate
+	if query == "" {
+		controllers.HandleErrorBadRequest(c, errors.New("empty query"))
+		return
+	}
+
+	// request session
+	reqSession := req.New()
+
+	// set timeout
+	reqSession.SetTimeout(15 * time.Second)
+
+	// request url
+	requestUrl := fmt.Sprintf("%s?q=%s&page=%d&per_page=%d", constants.PackagistSearchUrl, url.QueryEscape(query), pagination.Page, pagination.Size)
+
+	// perform request
+	res, err := reqSession.Get(requestUrl)
+	if err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// response
+	var searchRes entity.PackagistSearchResponse
+	if err := res.ToJSON(&searchRes); err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// empty results
+	if searchRes.Total == 0 {
+		controllers.HandleSuccess(c)
+		return
+	}
+
+	// dependencies
+	var deps []models.Dependency
+	var depNames []string
+	for _, r := range searchRes.Results {
+		d := models.Dependency{
+			Name:        r.Name,
+			Description: r.Description,
+		}
+		deps = append(deps, d)
+		depNames = append(depNames, d.Name)
+	}
+
+	// dependencies in db
+	depsResultsMap, err := svc._getDependencyResultsMap(depNames)
+	if err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// iterate dependencies
+	for i, d := range deps {
+		dr, ok := depsResultsMap[d.Name]
+		if ok {
+			deps[i].Result = dr
+		}
+	}
+
+	controllers.HandleSuccessWithListData(c, deps, searchRes.Total)
+}
+
+func (svc *PhpService) GetDependencies(params entity.UpdateParams) (deps []models.Dependency, err error) {
+	cmd := exec.Command(params.Cmd, "global", "show", "--format=json", "--no-interaction")
+	data, err := cmd.Output()
+	if err != nil {
+		return nil, trace.TraceError(err)
+	}
+	var res entity.ComposerShowResult
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, trace.TraceError(err)
+	}
+	for _, p := range res.Installed {
+		d := models.Dependency{
+			Name:        p.Name,
+			Version:     strings.TrimPrefix(p.Version, "v"),
+			Description: p.Description,
+		}
+		d.Type = constants.DependencyTypePhp
+		deps = append(deps, d)
+	}
+	return deps, nil
+}
+
+func (svc *PhpService) InstallDependencies(params entity.InstallParams) (err error) {
+	if params.UseConfig {
+		// workspace path
+		workspacePath, err := svc._getInstallWorkspacePath(params)
+		if err != nil {
+			return err
+		}
+
+		// install by composer.json, respecting composer.lock
+		cmd := exec.Command(params.Cmd, "install", "--no-interaction", "--working-dir", workspacePath)
+
+		return svc._runCmd(params.TaskId, cmd)
+	}
+
+	// proxy
+	if params.Proxy != "" {
+		cmd := exec.Command(params.Cmd, "global", "config", "--no-interaction", "repo.packagist", "composer", params.Proxy)
+		if err := svc._runCmd(params.TaskId, cmd); err != nil {
+			return err
+		}
+	}
+
+	// arguments
+	var args []string
+
+	// require, or update if upgrade
+	args = append(args, "global")
+	if params.Upgrade {
+		args = append(args, "update", "--with-dependencies")
+	} else {
+		args = append(args, "require")
+	}
+	args = append(args, "--no-interaction")
+
+	// dependency names
+	for _, depName := range params.Names {
+		args = append(args, depName)
+	}
+
+	// run
+	return svc._runCmd(params.TaskId, exec.Command(params.Cmd, args...))
+}
+
+func (svc *PhpService) UninstallDependencies(params entity.UninstallParams) (err error) {
+	// arguments
+	var args []string
+
+	// remove
+	args = append(args, "global", "remove", "--no-interaction")
+
+	// dependency names
+	for _, depName := range params.Names {
+		args = append(args, depName)
+	}
+
+	// run
+	return svc._runCmd(params.TaskId, exec.Command(params.Cmd, args...))
+}
+
+func (svc *PhpService) GetLatestVersion(dep models.Dependency) (v string, err error) {
+	// request session
+	reqSession := req.New()
+
+	// set timeout
+	reqSession.SetTimeout(60 * time.Second)
+
+	// request url of composer v2 metadata
+	requestUrl := fmt.Sprintf("%s/p2/%s.json", svc._getRepoUrl(svc.s.Proxy), dep.Name)
+
+	// perform request
+	res, err := reqSession.Get(requestUrl)
+	if err != nil {
+		return "", trace.TraceError(err)
+	}
+	if res.Response().StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if res.Response().StatusCode != http.StatusOK {
+		return "", trace.TraceError(errors.New(fmt.Sprintf("request %s failed: %s", requestUrl, res.Response().Status)))
+	}
+
+	// response
+	var metadata entity.PackagistMetadata
+	if err := res.ToJSON(&metadata); err != nil {
+		return "", trace.TraceError(err)
+	}
+
+	// versions are sorted from the latest
+	versions := metadata.Packages[dep.Name]
+	if len(versions) == 0 {
+		return "", nil
+	}
+	return strings.TrimPrefix(versions[0].Version, "v"), nil
+}
+
+// CompareVersions compares composer versions, which may be prefixed with
+// "v" or have missing components, e.g. "1.2"
+func (svc *PhpService) CompareVersions(v1, v2 string) (res int, err error) {
+	sv1, err := semver.ParseTolerant(v1)
+	if err != nil {
+		return 0, trace.TraceError(err)
+	}
+	sv2, err := semver.ParseTolerant(v2)
+	if err != nil {
+		return 0, trace.TraceError(err)
+	}
+	return sv1.Compare(sv2), nil
+}
+
+// GetManifestDependencies returns packages required in composer.json with
+// their version constraints, where platform requirements are skipped
+func (svc *PhpService) GetManifestDependencies(workspacePath, manifest string) (deps []models.Dependency, parseErrors []entity.ManifestError, err error) {
+	// file path
+	filePath := filepath.Join(workspacePath, manifest)
+
+	// file content
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, nil, trace.TraceError(err)
+	}
+	var composerJson entity.ComposerJson
+	if err := json.Unmarshal(data, &composerJson); err != nil {
+		return nil, nil, trace.TraceError(err)
+	}
+
+	// iterate requirements
+	for _, require := range []map[string]string{composerJson.Require, composerJson.RequireDev} {
+		var names []string
+		for name := range require {
+			// skip platform requirements, e.g. "php" and "ext-json"
+			if !strings.Contains(name, "/") {
+				continue
+			}
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			deps = append(deps, models.Dependency{
+				Name:    name,
+				Version: require[name],
+			})
+		}
+	}
+
+	return deps, nil, nil
+}
+
+func (svc *PhpService) _getRepoUrl(proxy string) (repoUrl string) {
+	if proxy == "" {
+		return constants.PackagistDefaultUrl
+	}
+	return strings.TrimSuffix(proxy, "/")
+}
+
+func NewPhpService(parent *Service) (svc *PhpService) {
+	svc = &PhpService{}
+	baseSvc := newBaseService(
+		svc,
+		parent,
+		constants.DependencyTypePhp,
+		entity.MessageCodes{
+			Update:    constants.MessageCodePhpUpdate,
+			Save:      constants.MessageCodePhpSave,
+			Install:   constants.MessageCodePhpInstall,
+			Uninstall: constants.MessageCodePhpUninstall,
+		},
+		models.Setting{
+			Name:        "PHP",
+			Description: "settings.description.php",
+			Cmd:         "composer",
+			Enabled:     true,
+		},
+	)
+	baseSvc.manifests = []string{constants.DependencyConfigComposerJson}
+	svc.baseService = baseSvc
+	return svc
+}
@@ -1,39 +1,32 @@
 package services
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/PuerkitoBio/goquery"
 	"github.com/crawlab-team/crawlab-core/controllers"
+	"github.com/crawlab-team/crawlab-core/utils"
 	"github.com/crawlab-team/go-trace"
 	"github.com/crawlab-team/plugin-dependency/constants"
 	"github.com/crawlab-team/plugin-dependency/entity"
 	"github.com/crawlab-team/plugin-dependency/models"
 	"github.com/gin-gonic/gin"
-	"github.com/imroc/req"
-	"go.mongodb.org/mongo-driver/bson"
-	mongo2 "go.mongodb.org/mongo-driver/mongo"
-	"net/url"
+	"github.com/pelletier/go-toml"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"os"
 	"os/exec"
 	"path"
-	"strconv"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
 	"strings"
-	"time"
 )
 
 type PythonService struct {
 	*baseService
 }
 
-func (svc *PythonService) Init() {
-	svc.api.GET("/python", svc.getList)
-	svc.api.POST("/python/update", svc.update)
-	svc.api.POST("/python/install", svc.install)
-	svc.api.POST("/python/uninstall", svc.uninstall)
-}
-
 func (svc *PythonService) GetRepoList(c *gin.Context) {
 	// query
 	query := c.Query("query")
@@ -45,112 +38,45 @@ func (svc *PythonService) GetRepoList(c *gin.Context) {
 		return
 	}
 
-	// request session
-	reqSession := req.New()
-
-	// set timeout
-	reqSession.SetTimeout(15 * time.Second)
-
-	// user agent
-	ua := req.Header{"user-agent": "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_14_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/94.0.4606.61 Safari/537.36"}
-
-	// request url
-	requestUrl := fmt.Sprintf("https://pypi.org/search?page=%d&q=%s", pagination.Page, url.QueryEscape(query))
-
-	// perform request
-	res, err := reqSession.Get(requestUrl, ua)
-	if err != nil {
-		if res != nil {
-			_, _ = c.Writer.Write(res.Bytes())
-			_ = c.AbortWithError(res.Response().StatusCode, err)
-			return
-		}
+	// setting
+	if err := svc._getSetting(); err != nil {
 		controllers.HandleErrorInternalServerError(c, err)
 		return
 	}
 
-	// response bytes
-	data, err := res.ToBytes()
+	// packages in registry
+	pkgs, total, err := svc._getRegistryClient().Search(query, pagination.Page, pagination.Size)
 	if err != nil {
 		controllers.HandleErrorInternalServerError(c, err)
 		return
 	}
-	buf := bytes.NewBuffer(data)
 
-	// parse html
-	doc, err := goquery.NewDocumentFromReader(buf)
-	if err != nil {
-		controllers.HandleErrorInternalServerError(c, err)
+	// empty results
+	if total == 0 {
+		controllers.HandleSuccess(c)
 		return
 	}
 
 	// dependencies
 	var deps []models.Dependency
 	var depNames []string
-	doc.Find(".left-layout__main > form ul > li").Each(func(i int, s *goquery.Selection) {
+	for _, pkg := range pkgs {
 		d := models.Dependency{
-			Name:          s.Find(".package-snippet__name").Text(),
-			LatestVersion: s.Find(".package-snippet__version").Text(),
+			Name:          pkg.Name,
+			LatestVersion: pkg.LatestVersion,
+			Description:   pkg.Description,
 		}
 		deps = append(deps, d)
 		depNames = append(depNames, d.Name)
-	})
-
-	// total
-	totalStr := doc.Find(".left-layout__main .split-layout p > strong").Text()
-	totalStr = strings.ReplaceAll(totalStr, ",", "")
-	total, _ := strconv.Atoi(totalStr)
-
-	// empty results
-	if total == 0 {
-		controllers.HandleSuccess(c)
-		return
 	}
 
 	// dependencies in db
-	var depsResults []entity.DependencyResult
-	pipelines := mongo2.Pipeline{
-		{{
-			"$match",
-			bson.M{
-				"type": constants.DependencyTypePython,
-				"name": bson.M{
-					"$in": depNames,
-				},
-			},
-		}},
-		{{
-			"$group",
-			bson.M{
-				"_id": "$name",
-				"node_ids": bson.M{
-					"$push": "$node_id",
-				},
-				"versions": bson.M{
-					"$addToSet": "$version",
-				},
-			},
-		}},
-		{{
-			"$project",
-			bson.M{
-				"name":     "$_id",
-				"node_ids": "$node_ids",
-				"versions": "$versions",
-			},
-		}},
-	}
-	if err := svc.parent.colD.Aggregate(pipelines, nil).All(&depsResults); err != nil {
+	depsResultsMap, err := svc._getDependencyResultsMap(depNames)
+	if err != nil {
 		controllers.HandleErrorInternalServerError(c, err)
 		return
 	}
 
-	// dependencies map
-	depsResultsMap := map[string]entity.DependencyResult{}
-	for _, dr := range depsResults {
-		depsResultsMap[dr.Name] = dr
-	}
-
 	// iterate dependencies
 	for i, d := range deps {
 		dr, ok := depsResultsMap[d.Name]
@@ -163,7 +89,22 @@ func (svc *PythonService) GetRepoList(c *gin.Context) {
 }
 
 func (svc *PythonService) GetDependencies(params entity.UpdateParams) (deps []models.Dependency, err error) {
-	cmd := exec.Command(params.Cmd, "list", "--format", "json")
+	// pip command
+	pipCmd := params.Cmd
+
+	// isolated environment of spider
+	if params.Isolated {
+		envPath, err := svc.GetEnvPath(params.EnvPath, params.SpiderId)
+		if err != nil {
+			return nil, err
+		}
+		if !utils.Exists(envPath) {
+			return nil, nil
+		}
+		pipCmd = svc._getEnvCmd(envPath, "pip")
+	}
+
+	cmd := exec.Command(pipCmd, "list", "--format", "json")
 	data, err := cmd.Output()
 	if err != nil {
 		return nil, err
@@ -183,6 +124,46 @@ func (svc *PythonService) GetDependencies(params entity.UpdateParams) (deps []mo
 }
 
 func (svc *PythonService) InstallDependencies(params entity.InstallParams) (err error) {
+	// pip command
+	pipCmd := params.Cmd
+
+	// isolated environment of spider, which is created on first install
+	var envPath string
+	if params.Isolated {
+		envPath, err = svc._createEnv(params.TaskId, params.Cmd, params.EnvPath, params.SpiderId)
+		if err != nil {
+			return err
+		}
+		pipCmd = svc._getEnvCmd(envPath, "pip")
+	}
+
+	// install by poetry or pipenv
+	if params.UseConfig {
+		// workspace path
+		workspacePath, err := svc._getInstallWorkspacePath(params)
+		if err != nil {
+			return err
+		}
+
+		// project manifest, which is managed by poetry only if it has
+		// [tool.poetry] table
+		if utils.Exists(path.Join(workspacePath, constants.DependencyConfigPyprojectToml)) &&
+			!utils.Exists(path.Join(workspacePath, constants.DependencyConfigRequirementsTxt)) {
+			tree, err := toml.LoadFile(path.Join(workspacePath, constants.DependencyConfigPyprojectToml))
+			if err != nil {
+				return trace.TraceError(err)
+			}
+			if tree.Has("tool.poetry") {
+				return svc._installPoetry(params, workspacePath, envPath, pipCmd)
+			}
+			return svc._installPyproject(params, workspacePath, pipCmd, tree)
+		}
+		if utils.Exists(path.Join(workspacePath, constants.DependencyConfigPipfile)) &&
+			!utils.Exists(path.Join(workspacePath, constants.DependencyConfigRequirementsTxt)) {
+			return svc._installPipenv(params, workspacePath, envPath)
+		}
+	}
+
 	// arguments
 	var args []string
 
@@ -214,32 +195,32 @@ func (svc *PythonService) InstallDependencies(params entity.InstallParams) (err
 			args = append(args, "-U")
 		}
 
-		// dependency names
-		for _, depName := range params.Names {
-			args = append(args, depName)
+		// dependency names with version specs, e.g. "requests==2.28.1"
+		for _, pkg := range params.Packages {
+			args = append(args, svc._getRequirement(pkg))
 		}
 	}
 
-	// command
-	cmd := exec.Command(params.Cmd, args...)
-
-	// logging
-	svc.parent._configureLogging(params.TaskId, cmd)
+	// run
+	return svc._runCmd(params.TaskId, exec.Command(pipCmd, args...))
+}
 
-	// start
-	if err := cmd.Start(); err != nil {
-		return trace.TraceError(err)
-	}
+func (svc *PythonService) UninstallDependencies(params entity.UninstallParams) (err error) {
+	// pip command
+	pipCmd := params.Cmd
 
-	// wait
-	if err := cmd.Wait(); err != nil {
-		return trace.TraceError(err)
+	// isolated environment of spider
+	if params.Isolated {
+		envPath, err := svc.GetEnvPath(params.EnvPath, params.SpiderId)
+		if err != nil {
+			return err
+		}
+		if !utils.Exists(envPath) {
+			return nil
+		}
+		pipCmd = svc._getEnvCmd(envPath, "pip")
 	}
 
-	return nil
-}
-
-func (svc *PythonService) UninstallDependencies(params entity.UninstallParams) (err error) {
 	// arguments
 	var args []string
 
@@ -252,62 +233,372 @@ func (svc *PythonService) UninstallDependencies(params entity.UninstallParams) (
 		args = append(args, depName)
 	}
 
-	// command
-	cmd := exec.Command(params.Cmd, args...)
+	// run
+	return svc._runCmd(params.TaskId, exec.Command(pipCmd, args...))
+}
 
-	// logging
-	svc.parent._configureLogging(params.TaskId, cmd)
+func (svc *PythonService) GetLatestVersion(dep models.Dependency) (v string, err error) {
+	return svc._getRegistryClient().GetLatestVersion(dep.Name)
+}
 
-	// start
-	if err := cmd.Start(); err != nil {
-		return trace.TraceError(err)
+// NormalizeName returns the normalized project name of PEP 503, e.g.
+// "scrapy-redis" of "Scrapy_Redis"
+func (svc *PythonService) NormalizeName(name string) (res string) {
+	return normalizePythonName(name)
+}
+
+// GetNamePattern returns the pattern of project names which are normalized
+// to the same name, e.g. "^scrapy[-_.]+redis$"
+func (svc *PythonService) GetNamePattern(name string) (pattern string) {
+	var parts []string
+	for _, part := range strings.Split(normalizePythonName(name), "-") {
+		parts = append(parts, regexp.QuoteMeta(part))
 	}
+	return "^" + strings.Join(parts, "[-_.]+") + "$"
+}
+
+// CompareVersions compares PEP 440 versions, e.g. "2.28" and "3.0rc1"
+func (svc *PythonService) CompareVersions(v1, v2 string) (res int, err error) {
+	return comparePythonVersions(v1, v2)
+}
 
-	// wait
-	if err := cmd.Wait(); err != nil {
+// ValidateVersionSpec validates PEP 440 specifier set, e.g. ">=2,<3", or
+// a bare version which is pinned, e.g. "2.28.1"
+func (svc *PythonService) ValidateVersionSpec(spec string) (err error) {
+	if _, err := parsePythonVersion(spec); err == nil {
+		return nil
+	}
+	set, err := parsePythonSpecifierSet(spec)
+	if err != nil {
+		return err
+	}
+	if len(set) == 0 {
+		return errors.New(fmt.Sprintf("invalid python specifier: %s", spec))
+	}
+	return nil
+}
+
+// _getRequirement returns the requirement of package in pip syntax
+func (svc *PythonService) _getRequirement(pkg entity.PackageSpec) (req string) {
+	if pkg.Spec == "" {
+		return pkg.Name
+	}
+	if _, err := parsePythonVersion(pkg.Spec); err == nil {
+		return pkg.Name + "==" + pkg.Spec
+	}
+	return pkg.Name + pkg.Spec
+}
+
+// _installPoetry installs dependencies of poetry project into system python
+// environment, or the isolated environment if envPath is not empty. As
+// poetry only downloads from sources declared in pyproject.toml, the locked
+// dependencies are exported and installed by pip from the proxy if set
+func (svc *PythonService) _installPoetry(params entity.InstallParams, workspacePath, envPath, pipCmd string) (err error) {
+	poetryCmd := svc._getSiblingCmd(params.Cmd, "poetry")
+
+	// install by poetry
+	if params.Proxy == "" {
+		cmd := exec.Command(poetryCmd, "install", "--no-interaction", "--no-root")
+		cmd.Dir = workspacePath
+		cmd.Env = append(svc._getEnvVars(envPath), "POETRY_VIRTUALENVS_CREATE=false")
+		return svc._runCmd(params.TaskId, cmd)
+	}
+
+	// exported requirements file
+	f, err := os.CreateTemp("", "poetry-requirements-*.txt")
+	if err != nil {
 		return trace.TraceError(err)
 	}
+	_ = f.Close()
+	defer os.Remove(f.Name())
+
+	// export locked dependencies
+	cmd := exec.Command(poetryCmd, "export", "--no-interaction", "--without-hashes", "-f", "requirements.txt", "-o", f.Name())
+	cmd.Dir = workspacePath
+	cmd.Env = append(svc._getEnvVars(envPath), "POETRY_VIRTUALENVS_CREATE=false")
+	if err := svc._runCmd(params.TaskId, cmd); err != nil {
+		return err
+	}
 
-	return nil
+	// install from proxy
+	return svc._runCmd(params.TaskId, exec.Command(pipCmd, "install", "-i", params.Proxy, "-r", f.Name()))
 }
 
-func (svc *PythonService) GetLatestVersion(dep models.Dependency) (v string, err error) {
-	// not exists in cache, request from pypi
-	reqSession := req.New()
+// _installPyproject installs PEP 621 dependencies of pyproject.toml by pip,
+// or the project itself if its dependencies are not declared statically
+func (svc *PythonService) _installPyproject(params entity.InstallParams, workspacePath, pipCmd string, tree *toml.Tree) (err error) {
+	// arguments
+	var args []string
+	args = append(args, "install")
 
-	// set timeout
-	reqSession.SetTimeout(60 * time.Second)
+	// proxy
+	if params.Proxy != "" {
+		args = append(args, "-i", params.Proxy)
+	}
+
+	// dependencies, e.g. "requests>=2.26"
+	requirements, ok := tree.Get("project.dependencies").([]interface{})
+	if ok && len(requirements) == 0 {
+		return nil
+	}
+	if !ok {
+		args = append(args, ".")
+	}
+	for _, r := range requirements {
+		if s, ok := r.(string); ok {
+			args = append(args, s)
+		}
+	}
 
-	// user agent
-	ua := req.Header{"user-agent": "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_14_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/94.0.4606.61 Safari/537.36"}
+	cmd := exec.Command(pipCmd, args...)
+	cmd.Dir = workspacePath
+	return svc._runCmd(params.TaskId, cmd)
+}
+
+// _installPipenv installs dependencies of Pipfile with pipenv into system
+// python environment, or the isolated environment if envPath is not empty,
+// which installs exactly from Pipfile.lock if exists
+func (svc *PythonService) _installPipenv(params entity.InstallParams, workspacePath, envPath string) (err error) {
+	// arguments
+	var args []string
+	args = append(args, "install")
 
-	// request url
-	requestUrl := fmt.Sprintf("https://pypi.org/project/%s/", dep.Name)
+	// pipenv uses the active virtual environment
+	if envPath == "" {
+		args = append(args, "--system")
+	}
 
-	// perform request
-	res, err := reqSession.Get(requestUrl, ua)
+	// lock file
+	if utils.Exists(path.Join(workspacePath, constants.DependencyConfigPipfileLock)) {
+		args = append(args, "--deploy")
+	}
+
+	// proxy
+	if params.Proxy != "" {
+		args = append(args, "--pypi-mirror", params.Proxy)
+	}
+
+	cmd := exec.Command(svc._getSiblingCmd(params.Cmd, "pipenv"), args...)
+	cmd.Dir = workspacePath
+	cmd.Env = svc._getEnvVars(envPath)
+	return svc._runCmd(params.TaskId, cmd)
+}
+
+// GetEnvPath returns the path of virtual environment of spider under root,
+// which is ~/.crawlab/venvs by default
+func (svc *PythonService) GetEnvPath(root string, spiderId primitive.ObjectID) (envPath string, err error) {
+	if spiderId.IsZero() {
+		return "", trace.TraceError(errors.New("empty spider id"))
+	}
+	if root == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", trace.TraceError(err)
+		}
+		root = filepath.Join(homeDir, constants.PythonVenvDefaultDir)
+	}
+	return filepath.Join(root, spiderId.Hex()), nil
+}
+
+// _createEnv creates virtual environment of spider if not exists
+func (svc *PythonService) _createEnv(taskId primitive.ObjectID, pipCmd, root string, spiderId primitive.ObjectID) (envPath string, err error) {
+	// env path
+	envPath, err = svc.GetEnvPath(root, spiderId)
 	if err != nil {
+		return "", err
+	}
+
+	// skip if exists
+	if utils.Exists(svc._getEnvCmd(envPath, "python")) || utils.Exists(svc._getEnvCmd(envPath, "python.exe")) {
+		return envPath, nil
+	}
+
+	// python command next to pip command, e.g. "pip3" -> "python3"
+	pythonName := "python3"
+	if name := filepath.Base(pipCmd); strings.HasPrefix(name, "pip") {
+		pythonName = "python" + strings.TrimPrefix(name, "pip")
+	}
+
+	// create
+	if err := os.MkdirAll(filepath.Dir(envPath), os.ModePerm); err != nil {
 		return "", trace.TraceError(err)
 	}
+	cmd := exec.Command(svc._getSiblingCmd(pipCmd, pythonName), "-m", "venv", envPath)
+	if err := svc._runCmd(taskId, cmd); err != nil {
+		return "", err
+	}
+
+	return envPath, nil
+}
+
+// _getEnvCmd returns the path of command in virtual environment
+func (svc *PythonService) _getEnvCmd(envPath, name string) (cmd string) {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(envPath, "Scripts", name)
+	}
+	return filepath.Join(envPath, "bin", name)
+}
+
+// _getEnvVars returns environment variables activating the virtual
+// environment if envPath is not empty
+func (svc *PythonService) _getEnvVars(envPath string) (env []string) {
+	env = os.Environ()
+	if envPath == "" {
+		return env
+	}
+	binPath := filepath.Dir(svc._getEnvCmd(envPath, "python"))
+	return append(env,
+		"VIRTUAL_ENV="+envPath,
+		"PATH="+binPath+string(os.PathListSeparator)+os.Getenv("PATH"),
+	)
+}
+
+// _getSiblingCmd returns the command of given name next to pip command
+func (svc *PythonService) _getSiblingCmd(pipCmd, name string) (cmd string) {
+	dir := filepath.Dir(pipCmd)
+	if dir == "." {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
 
-	// response bytes
-	data, err := res.ToBytes()
+// GetManifestDependencies returns dependencies declared in requirements.txt,
+// pyproject.toml or Pipfile with their version specifiers, which are
+// either PEP 440 specifiers or constraints of poetry, e.g. "^2.26"
+func (svc *PythonService) GetManifestDependencies(workspacePath, manifest string) (deps []models.Dependency, parseErrors []entity.ManifestError, err error) {
+	// file path
+	filePath := path.Join(workspacePath, manifest)
+
+	switch manifest {
+	case constants.DependencyConfigRequirementsTxt:
+		return svc._getRequirementsTxtDependencies(filePath)
+	case constants.DependencyConfigPyprojectToml:
+		deps, err = svc._getPyprojectTomlDependencies(filePath)
+	case constants.DependencyConfigPipfile:
+		deps, err = svc._getPipfileDependencies(filePath)
+	default:
+		return nil, nil, errors.New(fmt.Sprintf("invalid manifest: %s", manifest))
+	}
 	if err != nil {
-		return "", trace.TraceError(err)
+		return nil, nil, err
 	}
-	buf := bytes.NewBuffer(data)
+	return deps, nil, nil
+}
 
-	// parse html
-	doc, err := goquery.NewDocumentFromReader(buf)
+func (svc *PythonService) _getRequirementsTxtDependencies(filePath string) (deps []models.Dependency, parseErrors []entity.ManifestError, err error) {
+	// requirements
+	reqs, parseErrors, err := parsePythonRequirements(filePath)
 	if err != nil {
-		return "", trace.TraceError(err)
+		return nil, nil, err
+	}
+
+	// iterate requirements
+	for _, r := range reqs {
+		// skip distributions without names, e.g. "-e ."
+		if r.Name == "" {
+			continue
+		}
+
+		// add to dependencies
+		deps = append(deps, models.Dependency{
+			Name:    r.Name,
+			Version: r.Specifier,
+		})
+	}
+
+	return deps, parseErrors, nil
+}
+
+func (svc *PythonService) _getPyprojectTomlDependencies(filePath string) (deps []models.Dependency, err error) {
+	// toml tree
+	tree, err := toml.LoadFile(filePath)
+	if err != nil {
+		return nil, trace.TraceError(err)
+	}
+
+	// poetry dependencies, e.g. requests = "^2.26" or
+	// scrapy = { version = "^2.5", extras = ["http2"] }
+	for _, key := range []string{
+		"tool.poetry.dependencies",
+		"tool.poetry.dev-dependencies",
+	} {
+		deps = append(deps, svc._getTomlTableDependencies(tree, key)...)
+	}
+
+	// poetry dependency groups
+	if groups, ok := tree.Get("tool.poetry.group").(*toml.Tree); ok {
+		for _, name := range groups.Keys() {
+			deps = append(deps, svc._getTomlTableDependencies(groups, name+".dependencies")...)
+		}
+	}
+
+	// PEP 621 dependencies, e.g. "requests>=2.26"
+	if requirements, ok := tree.Get("project.dependencies").([]interface{}); ok {
+		pattern := regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)\s*(?:\[[^\]]*\])?\s*(.*)$`)
+		for _, r := range requirements {
+			s, ok := r.(string)
+			if !ok {
+				continue
+			}
+			matches := pattern.FindStringSubmatch(strings.TrimSpace(s))
+			if len(matches) < 3 {
+				continue
+			}
+			deps = append(deps, models.Dependency{
+				Name:    matches[1],
+				Version: strings.TrimSpace(matches[2]),
+			})
+		}
 	}
 
-	// latest version
-	v = doc.Find(".release-timeline .release--current .release__version").Text()
-	v = strings.TrimSpace(v)
+	return deps, nil
+}
+
+func (svc *PythonService) _getPipfileDependencies(filePath string) (deps []models.Dependency, err error) {
+	// toml tree
+	tree, err := toml.LoadFile(filePath)
+	if err != nil {
+		return nil, trace.TraceError(err)
+	}
 
-	return v, nil
+	// packages, e.g. requests = "*" or scrapy = { version = ">=2.5" }
+	for _, key := range []string{"packages", "dev-packages"} {
+		deps = append(deps, svc._getTomlTableDependencies(tree, key)...)
+	}
+
+	return deps, nil
+}
+
+// _getTomlTableDependencies returns dependencies declared in the toml
+// table of the given key, whose values are either version strings or
+// tables with "version" field
+func (svc *PythonService) _getTomlTableDependencies(tree *toml.Tree, key string) (deps []models.Dependency) {
+	table, ok := tree.Get(key).(*toml.Tree)
+	if !ok {
+		return nil
+	}
+	names := table.Keys()
+	sort.Strings(names)
+	for _, name := range names {
+		// skip python version requirement
+		if name == "python" {
+			continue
+		}
+		d := models.Dependency{
+			Name: name,
+		}
+		switch v := table.GetPath([]string{name}).(type) {
+		case string:
+			d.Version = v
+		case *toml.Tree:
+			d.Version, _ = v.Get("version").(string)
+		}
+		if d.Version == "*" {
+			d.Version = ""
+		}
+		deps = append(deps, d)
+	}
+	return deps
 }
 
 func NewPythonService(parent *Service) (svc *PythonService) {
@@ -322,7 +613,21 @@ func NewPythonService(parent *Service) (svc *PythonService) {
 			Install:   constants.MessageCodePythonInstall,
 			Uninstall: constants.MessageCodePythonUninstall,
 		},
+		models.Setting{
+			Name:        "Python",
+			Description: "settings.description.python",
+			Cmd:         "pip",
+			Enabled:     true,
+		},
 	)
+	baseSvc.newRegistryClient = func(s models.Setting) (client RegistryClient) {
+		return NewPypiRegistryClient(s.Proxy)
+	}
+	baseSvc.manifests = []string{
+		constants.DependencyConfigRequirementsTxt,
+		constants.DependencyConfigPyprojectToml,
+		constants.DependencyConfigPipfile,
+	}
 	svc.baseService = baseSvc
 	return svc
 }
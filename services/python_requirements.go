@@ -0,0 +1,383 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/crawlab-team/crawlab-core/utils"
+	"github.com/crawlab-team/go-trace"
+	"github.com/crawlab-team/plugin-dependency/entity"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var pythonRequirementNamePattern = regexp.MustCompile(`^([A-Za-z0-9](?:[A-Za-z0-9._-]*[A-Za-z0-9])?)`)
+var pythonRequirementSpecPattern = regexp.MustCompile(`^(~=|===|==|!=|<=|>=|<|>)\s*([A-Za-z0-9.*+!_-]+)$`)
+var pythonRequirementCommentPattern = regexp.MustCompile(`(^|\s+)#.*$`)
+var pythonRequirementOptionPattern = regexp.MustCompile(`\s+--(hash|global-option|install-option|config-settings)(=|\s+)\S+`)
+var pythonRequirementArchivePattern = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._]*?)-[0-9][^/]*\.(whl|tar\.gz|tar\.bz2|zip)$`)
+
+// pythonRequirementOptions are global options of requirements.txt which
+// do not declare requirements, with whether they take a value
+var pythonRequirementOptions = map[string]bool{
+	"-i":                         true,
+	"--index-url":                true,
+	"--extra-index-url":          true,
+	"--no-index":                 false,
+	"-f":                         true,
+	"--find-links":               true,
+	"--trusted-host":             true,
+	"--no-binary":                true,
+	"--only-binary":              true,
+	"--prefer-binary":            false,
+	"--pre":                      false,
+	"--require-hashes":           false,
+	"--use-feature":              true,
+	"-c":                         true,
+	"--constraint":               true,
+	"-Z":                         false,
+	"--always-unzip":             false,
+	"--process-dependency-links": false,
+}
+
+// pythonRequirementsParser parses requirements.txt in the pip format, which
+// follows included requirement files, and collects errors of invalid
+// lines instead of failing the whole file
+type pythonRequirementsParser struct {
+	root    string
+	visited map[string]bool
+	reqs    []entity.PythonRequirement
+	errs    []entity.ManifestError
+}
+
+// parsePythonRequirements parses the requirements file, where file paths
+// of requirements and errors are relative to the directory of the file
+func parsePythonRequirements(filePath string) (reqs []entity.PythonRequirement, errs []entity.ManifestError, err error) {
+	p := &pythonRequirementsParser{
+		root:    filepath.Dir(filePath),
+		visited: map[string]bool{},
+	}
+	if err := p.parseFile(filePath); err != nil {
+		return nil, nil, err
+	}
+	return p.reqs, p.errs, nil
+}
+
+func (p *pythonRequirementsParser) parseFile(filePath string) (err error) {
+	// skip if already parsed, e.g. circular includes
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return trace.TraceError(err)
+	}
+	if p.visited[absPath] {
+		return nil
+	}
+	p.visited[absPath] = true
+
+	// file content
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return trace.TraceError(err)
+	}
+	fileName := p._getRelPath(filePath)
+
+	// iterate logical lines, which may be continued with trailing backslash
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNum := i + 1
+		line := lines[i]
+		for strings.HasSuffix(line, "\\") && i+1 < len(lines) {
+			i++
+			line = strings.TrimSuffix(line, "\\") + lines[i]
+		}
+
+		// strip comments and blank lines
+		line = strings.TrimSpace(pythonRequirementCommentPattern.ReplaceAllString(line, ""))
+		if line == "" {
+			continue
+		}
+
+		if err := p.parseLine(filePath, fileName, lineNum, line); err != nil {
+			p.errs = append(p.errs, entity.ManifestError{
+				File:    fileName,
+				Line:    lineNum,
+				Content: line,
+				Error:   err.Error(),
+			})
+		}
+	}
+
+	return nil
+}
+
+func (p *pythonRequirementsParser) parseLine(filePath, fileName string, lineNum int, line string) (err error) {
+	// options
+	if strings.HasPrefix(line, "-") {
+		name, value := p._splitOption(line)
+		switch name {
+		case "-r", "--requirement":
+			// included requirements file
+			if value == "" {
+				return errors.New(fmt.Sprintf("missing value of %s", name))
+			}
+			includePath := filepath.Join(filepath.Dir(filePath), value)
+			if !p._isInRoot(includePath) {
+				return errors.New(fmt.Sprintf("requirements file outside workspace: %s", value))
+			}
+			if !utils.Exists(includePath) {
+				return errors.New(fmt.Sprintf("requirements file not found: %s", value))
+			}
+			return p.parseFile(includePath)
+		case "-e", "--editable":
+			// editable requirement
+			if value == "" {
+				return errors.New(fmt.Sprintf("missing value of %s", name))
+			}
+			req, err := p.parseRequirement(value)
+			if err != nil {
+				return err
+			}
+			req.Editable = true
+			req.File = fileName
+			req.Line = lineNum
+			p.reqs = append(p.reqs, req)
+			return nil
+		default:
+			hasValue, ok := pythonRequirementOptions[name]
+			if !ok {
+				return errors.New(fmt.Sprintf("unknown option: %s", name))
+			}
+			if hasValue && value == "" {
+				return errors.New(fmt.Sprintf("missing value of %s", name))
+			}
+			return nil
+		}
+	}
+
+	// requirement
+	req, err := p.parseRequirement(line)
+	if err != nil {
+		return err
+	}
+	req.File = fileName
+	req.Line = lineNum
+	p.reqs = append(p.reqs, req)
+	return nil
+}
+
+// parseRequirement parses a PEP 508 requirement, e.g.
+// "scrapy-redis[extra]>=0.7,<1.0; python_version >= '3.6'", or a url,
+// vcs url or local path of distribution, e.g. "git+https://host/repo.git#egg=name"
+func (p *pythonRequirementsParser) parseRequirement(s string) (req entity.PythonRequirement, err error) {
+	// per-requirement options, e.g. --hash=sha256:...
+	s = strings.TrimSpace(pythonRequirementOptionPattern.ReplaceAllString(s, ""))
+
+	// distribution url or path
+	if p._isSource(s) {
+		return p.parseSource(s)
+	}
+
+	// name
+	matches := pythonRequirementNamePattern.FindStringSubmatch(s)
+	if len(matches) < 2 {
+		return req, errors.New("invalid requirement name")
+	}
+	req.Name = matches[1]
+	rest := strings.TrimSpace(s[len(matches[0]):])
+
+	// extras
+	if strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "]")
+		if end < 0 {
+			return req, errors.New("unclosed extras")
+		}
+		for _, extra := range strings.Split(rest[1:end], ",") {
+			extra = strings.TrimSpace(extra)
+			if extra == "" {
+				continue
+			}
+			if m := pythonRequirementNamePattern.FindString(extra); m != extra {
+				return req, errors.New(fmt.Sprintf("invalid extra: %s", extra))
+			}
+			req.Extras = append(req.Extras, extra)
+		}
+		rest = strings.TrimSpace(rest[end+1:])
+	}
+
+	// url, e.g. "name @ https://host/name-1.0.tar.gz ; marker"
+	if strings.HasPrefix(rest, "@") {
+		rest = strings.TrimSpace(rest[1:])
+		source := rest
+		if i := strings.Index(rest, " ;"); i >= 0 {
+			source = strings.TrimSpace(rest[:i])
+			rest = rest[i+1:]
+		} else {
+			rest = ""
+		}
+		if source == "" {
+			return req, errors.New("missing url")
+		}
+		if _, err := url.Parse(source); err != nil {
+			return req, errors.New(fmt.Sprintf("invalid url: %s", source))
+		}
+		req.Source = source
+	} else {
+		// version specifiers, optionally enclosed in parentheses
+		spec := rest
+		if i := strings.Index(rest, ";"); i >= 0 {
+			spec = rest[:i]
+			rest = rest[i:]
+		} else {
+			rest = ""
+		}
+		spec = strings.TrimSpace(spec)
+		if strings.HasPrefix(spec, "(") {
+			if !strings.HasSuffix(spec, ")") {
+				return req, errors.New("unclosed version specifier")
+			}
+			spec = strings.TrimSpace(spec[1 : len(spec)-1])
+		}
+		if spec != "" {
+			var items []string
+			for _, item := range strings.Split(spec, ",") {
+				item = strings.TrimSpace(item)
+				m := pythonRequirementSpecPattern.FindStringSubmatch(item)
+				if len(m) < 3 {
+					return req, errors.New(fmt.Sprintf("invalid version specifier: %s", item))
+				}
+				items = append(items, m[1]+m[2])
+			}
+			req.Specifier = strings.Join(items, ",")
+		}
+	}
+
+	// environment marker
+	rest = strings.TrimSpace(rest)
+	if strings.HasPrefix(rest, ";") {
+		req.Marker = strings.TrimSpace(rest[1:])
+		if req.Marker == "" {
+			return req, errors.New("empty environment marker")
+		}
+		if strings.Count(req.Marker, "(") != strings.Count(req.Marker, ")") {
+			return req, errors.New(fmt.Sprintf("invalid environment marker: %s", req.Marker))
+		}
+	} else if rest != "" {
+		return req, errors.New(fmt.Sprintf("unexpected content: %s", rest))
+	}
+
+	return req, nil
+}
+
+// parseSource parses a url, vcs url or local path of distribution, whose
+// name is taken from "#egg=" fragment or file name of archive if any
+func (p *pythonRequirementsParser) parseSource(s string) (req entity.PythonRequirement, err error) {
+	// environment marker
+	if i := strings.Index(s, "; "); i >= 0 {
+		req.Marker = strings.TrimSpace(s[i+1:])
+		s = strings.TrimSpace(s[:i])
+	}
+	req.Source = s
+
+	// name from egg fragment
+	if i := strings.Index(s, "#"); i >= 0 {
+		fragment, err := url.ParseQuery(s[i+1:])
+		if err != nil {
+			return req, errors.New(fmt.Sprintf("invalid url fragment: %s", s[i+1:]))
+		}
+		egg := fragment.Get("egg")
+		if egg != "" {
+			// e.g. "#egg=name[extra]"
+			if j := strings.Index(egg, "["); j >= 0 {
+				egg = egg[:j]
+			}
+			if m := pythonRequirementNamePattern.FindString(egg); m != egg {
+				return req, errors.New(fmt.Sprintf("invalid egg name: %s", egg))
+			}
+			req.Name = egg
+			return req, nil
+		}
+		s = s[:i]
+	}
+
+	// name from archive file name, e.g. "name-1.0.tar.gz"
+	if m := pythonRequirementArchivePattern.FindStringSubmatch(filepath.Base(s)); len(m) > 1 {
+		req.Name = m[1]
+	}
+
+	return req, nil
+}
+
+// _isSource returns true if the requirement is a url or local path
+func (p *pythonRequirementsParser) _isSource(s string) (res bool) {
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "/") || strings.HasPrefix(s, "~") {
+		return true
+	}
+	if i := strings.Index(s, "://"); i > 0 && !strings.ContainsAny(s[:i], " @<>=!~;[") {
+		return true
+	}
+	return strings.HasPrefix(s, "file:")
+}
+
+// _splitOption splits option line into name and value, e.g.
+// "-r base.txt", "--requirement=base.txt" or "-rbase.txt"
+func (p *pythonRequirementsParser) _splitOption(line string) (name, value string) {
+	if strings.HasPrefix(line, "--") {
+		if i := strings.IndexAny(line, "= \t"); i >= 0 {
+			return line[:i], strings.TrimSpace(strings.TrimLeft(line[i:], "= \t"))
+		}
+		return line, ""
+	}
+	if len(line) > 2 {
+		return line[:2], strings.TrimSpace(line[2:])
+	}
+	return line, ""
+}
+
+// _isInRoot returns true if the file is in the root directory, with
+// symbolic links resolved if it exists, so that included files cannot be
+// read from outside of the workspace
+func (p *pythonRequirementsParser) _isInRoot(filePath string) (res bool) {
+	root, err := filepath.Abs(p.root)
+	if err != nil {
+		return false
+	}
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return false
+	}
+	if !p._isRelPathInRoot(root, absPath) {
+		return false
+	}
+
+	// symbolic links
+	if !utils.Exists(absPath) {
+		return true
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return false
+	}
+	realPath, err := filepath.EvalSymlinks(absPath)
+	if err != nil {
+		return false
+	}
+	return p._isRelPathInRoot(realRoot, realPath)
+}
+
+func (p *pythonRequirementsParser) _isRelPathInRoot(root, filePath string) (res bool) {
+	relPath, err := filepath.Rel(root, filePath)
+	if err != nil {
+		return false
+	}
+	return relPath != ".." && !strings.HasPrefix(relPath, ".."+string(filepath.Separator))
+}
+
+func (p *pythonRequirementsParser) _getRelPath(filePath string) (res string) {
+	relPath, err := filepath.Rel(p.root, filePath)
+	if err != nil {
+		return filepath.Base(filePath)
+	}
+	return filepath.ToSlash(relPath)
+}
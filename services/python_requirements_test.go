@@ -0,0 +1,193 @@
+package services
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/crawlab-team/plugin-dependency/entity"
+)
+
+func writeRequirementsFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		filePath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParsePythonRequirements(t *testing.T) {
+	cases := []struct {
+		name string
+		line string
+		req  entity.PythonRequirement
+	}{
+		{
+			name: "extras",
+			line: "scrapy-redis[http2, socks]>=0.7,<1.0",
+			req:  entity.PythonRequirement{Name: "scrapy-redis", Extras: []string{"http2", "socks"}, Specifier: ">=0.7,<1.0"},
+		},
+		{
+			name: "marker",
+			line: "requests>=2.26; python_version >= '3.6'",
+			req:  entity.PythonRequirement{Name: "requests", Specifier: ">=2.26", Marker: "python_version >= '3.6'"},
+		},
+		{
+			name: "compatible and exclusion",
+			line: "django ~= 3.2, != 3.2.1",
+			req:  entity.PythonRequirement{Name: "django", Specifier: "~=3.2,!=3.2.1"},
+		},
+		{
+			name: "parenthesized",
+			line: "lxml (>=4.6)",
+			req:  entity.PythonRequirement{Name: "lxml", Specifier: ">=4.6"},
+		},
+		{
+			name: "editable",
+			line: "-e git+https://github.com/org/spider-utils.git#egg=spider-utils",
+			req:  entity.PythonRequirement{Name: "spider-utils", Source: "git+https://github.com/org/spider-utils.git#egg=spider-utils", Editable: true},
+		},
+		{
+			name: "vcs",
+			line: "git+https://github.com/org/parsel.git@v1.6#egg=parsel[cssselect]",
+			req:  entity.PythonRequirement{Name: "parsel", Source: "git+https://github.com/org/parsel.git@v1.6#egg=parsel[cssselect]"},
+		},
+		{
+			name: "direct url",
+			line: "pyquery @ https://host/pyquery-1.4.3.tar.gz ; python_version < '4'",
+			req:  entity.PythonRequirement{Name: "pyquery", Source: "https://host/pyquery-1.4.3.tar.gz", Marker: "python_version < '4'"},
+		},
+		{
+			name: "archive",
+			line: "./dist/w3lib-1.22.0-py2.py3-none-any.whl",
+			req:  entity.PythonRequirement{Name: "w3lib", Source: "./dist/w3lib-1.22.0-py2.py3-none-any.whl"},
+		},
+		{
+			name: "hash",
+			line: "idna==3.3 --hash=sha256:abc",
+			req:  entity.PythonRequirement{Name: "idna", Specifier: "==3.3"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeRequirementsFiles(t, dir, map[string]string{"requirements.txt": c.line + "\n"})
+
+			reqs, errs, err := parsePythonRequirements(filepath.Join(dir, "requirements.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(errs) != 0 {
+				t.Fatalf("unexpected errors: %v", errs)
+			}
+			c.req.File = "requirements.txt"
+			c.req.Line = 1
+			if len(reqs) != 1 || !reflect.DeepEqual(reqs[0], c.req) {
+				t.Errorf("expected %+v, got %+v", c.req, reqs)
+			}
+		})
+	}
+}
+
+func TestParsePythonRequirements_Include(t *testing.T) {
+	dir := t.TempDir()
+	writeRequirementsFiles(t, dir, map[string]string{
+		"requirements.txt": "-i https://pypi.org/simple\n" +
+			"# comment\n" +
+			"-r requirements/base.txt\n" +
+			"scrapy==2.5.1\n",
+		"requirements/base.txt": "requests \\\n" +
+			"  >=2.26\n" +
+			"--requirement=../requirements.txt\n" +
+			"lxml\n",
+	})
+
+	reqs, errs, err := parsePythonRequirements(filepath.Join(dir, "requirements.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	var res []string
+	for _, r := range reqs {
+		res = append(res, fmt.Sprintf("%s%s@%s:%d", r.Name, r.Specifier, r.File, r.Line))
+	}
+	expected := []string{
+		"requests>=2.26@requirements/base.txt:1",
+		"lxml@requirements/base.txt:4",
+		"scrapy==2.5.1@requirements.txt:4",
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("expected %v, got %v", expected, res)
+	}
+}
+
+func TestParsePythonRequirements_Errors(t *testing.T) {
+	dir := t.TempDir()
+	writeRequirementsFiles(t, dir, map[string]string{
+		"requirements.txt": "requests\n" +
+			"==1.0\n" +
+			"\n" +
+			"scrapy >= \\\n" +
+			"  2.5 ; \n" +
+			"--unknown-option\n" +
+			"-r missing.txt\n" +
+			"pandas[sql>=1.0\n",
+	})
+
+	_, errs, err := parsePythonRequirements(filepath.Join(dir, "requirements.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []int
+	for _, e := range errs {
+		if e.File != "requirements.txt" {
+			t.Errorf("unexpected file of error: %s", e.File)
+		}
+		lines = append(lines, e.Line)
+	}
+	expected := []int{2, 4, 6, 7, 8}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected errors on lines %v, got %v: %v", expected, lines, errs)
+	}
+}
+
+func TestParsePythonRequirements_OutsideWorkspace(t *testing.T) {
+	root := t.TempDir()
+	writeRequirementsFiles(t, root, map[string]string{
+		"secret.txt": "not a requirement!\n",
+		"workspace/requirements.txt": "-r ../secret.txt\n" +
+			"-r link.txt\n",
+	})
+	if err := os.Symlink(filepath.Join(root, "secret.txt"), filepath.Join(root, "workspace", "link.txt")); err != nil {
+		t.Skip(err)
+	}
+
+	reqs, errs, err := parsePythonRequirements(filepath.Join(root, "workspace", "requirements.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reqs) != 0 {
+		t.Errorf("unexpected requirements: %v", reqs)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs)
+	}
+	for _, e := range errs {
+		if e.File != "requirements.txt" || !strings.Contains(e.Error, "outside workspace") {
+			t.Errorf("unexpected error: %+v", e)
+		}
+		if strings.Contains(e.Content, "not a requirement") {
+			t.Errorf("content of file outside workspace is leaked: %+v", e)
+		}
+	}
+}
@@ -0,0 +1,418 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// pythonVersionPattern is the permissive pattern of PEP 440 versions,
+// which accepts alternative spellings normalized by pip
+var pythonVersionPattern = regexp.MustCompile(`(?i)^v?` +
+	`(?:(?P<epoch>[0-9]+)!)?` +
+	`(?P<release>[0-9]+(?:\.[0-9]+)*)` +
+	`(?:[-_.]?(?P<pre_l>alpha|beta|preview|pre|rc|a|b|c)[-_.]?(?P<pre_n>[0-9]+)?)?` +
+	`(?:-(?P<post_n1>[0-9]+)|[-_.]?(?P<post_l>post|rev|r)[-_.]?(?P<post_n2>[0-9]+)?)?` +
+	`(?:[-_.]?(?P<dev_l>dev)[-_.]?(?P<dev_n>[0-9]+)?)?` +
+	`(?:\+(?P<local>[a-z0-9]+(?:[-_.][a-z0-9]+)*))?$`)
+
+var pythonNameSeparatorPattern = regexp.MustCompile(`[-_.]+`)
+
+var pythonSpecifierPattern = regexp.MustCompile(`^(~=|===|==|!=|<=|>=|<|>)\s*(\S+)$`)
+
+// pythonVersion is a PEP 440 version, where pre-release kind is one of "a",
+// "b" and "rc", and post and dev are -1 if absent
+type pythonVersion struct {
+	epoch   int
+	release []int
+	preKind string
+	pre     int
+	post    int
+	dev     int
+	local   []string
+}
+
+// parsePythonVersion parses PEP 440 version, e.g. "2.28", "1.0.post1",
+// "3.0rc1", "1!2.0.dev3" or "1.0+local.1"
+func parsePythonVersion(s string) (v pythonVersion, err error) {
+	s = strings.TrimSpace(s)
+	matches := pythonVersionPattern.FindStringSubmatch(s)
+	if matches == nil {
+		return v, errors.New(fmt.Sprintf("invalid python version: %s", s))
+	}
+	groups := map[string]string{}
+	for i, name := range pythonVersionPattern.SubexpNames() {
+		if name != "" {
+			groups[name] = strings.ToLower(matches[i])
+		}
+	}
+
+	// epoch
+	if groups["epoch"] != "" {
+		v.epoch, _ = strconv.Atoi(groups["epoch"])
+	}
+
+	// release
+	for _, part := range strings.Split(groups["release"], ".") {
+		n, _ := strconv.Atoi(part)
+		v.release = append(v.release, n)
+	}
+
+	// pre-release, e.g. "alpha" -> "a", "c" -> "rc"
+	switch groups["pre_l"] {
+	case "":
+	case "a", "alpha":
+		v.preKind = "a"
+	case "b", "beta":
+		v.preKind = "b"
+	default:
+		v.preKind = "rc"
+	}
+	if v.preKind != "" {
+		v.pre, _ = strconv.Atoi(groups["pre_n"])
+	}
+
+	// post-release, e.g. "1.0-1" or "1.0.post1"
+	v.post = -1
+	if groups["post_n1"] != "" {
+		v.post, _ = strconv.Atoi(groups["post_n1"])
+	} else if groups["post_l"] != "" {
+		v.post, _ = strconv.Atoi(groups["post_n2"])
+	}
+
+	// development release
+	v.dev = -1
+	if groups["dev_l"] != "" {
+		v.dev, _ = strconv.Atoi(groups["dev_n"])
+	}
+
+	// local version label
+	if groups["local"] != "" {
+		v.local = strings.FieldsFunc(groups["local"], func(r rune) bool {
+			return r == '-' || r == '_' || r == '.'
+		})
+	}
+
+	return v, nil
+}
+
+// isPrerelease returns true if the version is a pre-release or development release
+func (v pythonVersion) isPrerelease() (res bool) {
+	return v.preKind != "" || v.dev >= 0
+}
+
+func (v pythonVersion) isPostrelease() (res bool) {
+	return v.post >= 0
+}
+
+// public returns the version without local version label
+func (v pythonVersion) public() (res pythonVersion) {
+	res = v
+	res.local = nil
+	return res
+}
+
+// base returns the version with epoch and release segments only
+func (v pythonVersion) base() (res pythonVersion) {
+	return pythonVersion{
+		epoch:   v.epoch,
+		release: v.release,
+		post:    -1,
+		dev:     -1,
+	}
+}
+
+// compare returns -1, 0 or 1 if the version is lower than, equal to or
+// higher than the other, in the order of epoch, release, pre-release,
+// post-release, development release and local version label
+func (v pythonVersion) compare(o pythonVersion) (res int) {
+	if res = compareInt(v.epoch, o.epoch); res != 0 {
+		return res
+	}
+	if res = comparePythonRelease(v.release, o.release); res != 0 {
+		return res
+	}
+	if res = compareInt(v._preKey(), o._preKey()); res != 0 {
+		return res
+	}
+	if res = compareInt(v.pre, o.pre); res != 0 {
+		return res
+	}
+	if res = compareInt(v.post, o.post); res != 0 {
+		return res
+	}
+	if res = compareInt(v._devKey(), o._devKey()); res != 0 {
+		return res
+	}
+	return comparePythonLocal(v.local, o.local)
+}
+
+// _preKey returns sort key of pre-release, where development releases of
+// final releases sort before pre-releases, and final releases after them
+func (v pythonVersion) _preKey() (key int) {
+	switch v.preKind {
+	case "a":
+		return 1
+	case "b":
+		return 2
+	case "rc":
+		return 3
+	}
+	if v.post < 0 && v.dev >= 0 {
+		return 0
+	}
+	return 4
+}
+
+// _devKey returns sort key of development release, where releases without
+// development release sort after development releases
+func (v pythonVersion) _devKey() (key int) {
+	if v.dev < 0 {
+		return int(^uint(0) >> 1)
+	}
+	return v.dev
+}
+
+func comparePythonRelease(a, b []int) (res int) {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if res = compareInt(x, y); res != 0 {
+			return res
+		}
+	}
+	return 0
+}
+
+// comparePythonLocal compares local version labels segment by segment,
+// where numeric segments sort after alphanumeric ones
+func comparePythonLocal(a, b []string) (res int) {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		x, errX := strconv.Atoi(a[i])
+		y, errY := strconv.Atoi(b[i])
+		switch {
+		case errX == nil && errY == nil:
+			res = compareInt(x, y)
+		case errX == nil:
+			res = 1
+		case errY == nil:
+			res = -1
+		default:
+			res = strings.Compare(a[i], b[i])
+		}
+		if res != 0 {
+			return res
+		}
+	}
+	return compareInt(len(a), len(b))
+}
+
+func compareInt(a, b int) (res int) {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+// comparePythonVersions compares PEP 440 versions
+func comparePythonVersions(v1, v2 string) (res int, err error) {
+	pv1, err := parsePythonVersion(v1)
+	if err != nil {
+		return 0, err
+	}
+	pv2, err := parsePythonVersion(v2)
+	if err != nil {
+		return 0, err
+	}
+	return pv1.compare(pv2), nil
+}
+
+// pythonSpecifier is a PEP 440 version specifier, e.g. ">=2.0" or "==1.*"
+type pythonSpecifier struct {
+	op       string
+	version  string
+	v        pythonVersion
+	wildcard bool
+}
+
+// pythonSpecifierSet is a comma-separated set of version specifiers,
+// which are satisfied if all specifiers are satisfied
+type pythonSpecifierSet []pythonSpecifier
+
+// parsePythonSpecifierSet parses PEP 440 specifier set, e.g. ">=2.0,!=2.1.*,<3"
+func parsePythonSpecifierSet(s string) (set pythonSpecifierSet, err error) {
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		spec, err := parsePythonSpecifier(item)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, spec)
+	}
+	return set, nil
+}
+
+func parsePythonSpecifier(s string) (spec pythonSpecifier, err error) {
+	matches := pythonSpecifierPattern.FindStringSubmatch(s)
+	if len(matches) < 3 {
+		return spec, errors.New(fmt.Sprintf("invalid python specifier: %s", s))
+	}
+	spec.op = matches[1]
+	spec.version = matches[2]
+
+	// arbitrary equality compares strings only
+	if spec.op == "===" {
+		return spec, nil
+	}
+
+	// prefix matching, e.g. "==1.*"
+	version := spec.version
+	if strings.HasSuffix(version, ".*") {
+		if spec.op != "==" && spec.op != "!=" {
+			return spec, errors.New(fmt.Sprintf("invalid python specifier: %s", s))
+		}
+		spec.wildcard = true
+		version = strings.TrimSuffix(version, ".*")
+	}
+
+	spec.v, err = parsePythonVersion(version)
+	if err != nil {
+		return spec, err
+	}
+
+	// compatible release requires at least two release segments
+	if spec.op == "~=" && len(spec.v.release) < 2 {
+		return spec, errors.New(fmt.Sprintf("invalid python specifier: %s", s))
+	}
+
+	return spec, nil
+}
+
+// isPrerelease returns true if the specifier explicitly mentions a
+// pre-release, which allows pre-releases to satisfy the specifier set
+func (spec pythonSpecifier) isPrerelease() (res bool) {
+	return spec.op != "!=" && spec.op != "===" && spec.v.isPrerelease()
+}
+
+// contains returns true if the version satisfies the specifier
+func (spec pythonSpecifier) contains(v pythonVersion, raw string) (res bool) {
+	switch spec.op {
+	case "===":
+		return strings.EqualFold(strings.TrimSpace(raw), spec.version)
+	case "==":
+		return spec._equals(v)
+	case "!=":
+		return !spec._equals(v)
+	case "~=":
+		// e.g. "~=2.2.1" is ">=2.2.1,==2.2.*"
+		prefix := pythonSpecifier{
+			op:       "==",
+			v:        pythonVersion{epoch: spec.v.epoch, release: spec.v.release[:len(spec.v.release)-1], post: -1, dev: -1},
+			wildcard: true,
+		}
+		return v.public().compare(spec.v) >= 0 && prefix._equals(v)
+	case "<=":
+		return v.public().compare(spec.v) <= 0
+	case ">=":
+		return v.public().compare(spec.v) >= 0
+	case "<":
+		// pre-releases of the specified version are excluded
+		if v.compare(spec.v) >= 0 {
+			return false
+		}
+		return spec.v.isPrerelease() || !v.isPrerelease() || v.base().compare(spec.v.base()) != 0
+	case ">":
+		// post-releases and local versions of the specified version are excluded
+		if v.compare(spec.v) <= 0 {
+			return false
+		}
+		if !spec.v.isPostrelease() && v.isPostrelease() && v.base().compare(spec.v.base()) == 0 {
+			return false
+		}
+		return len(v.local) == 0 || v.base().compare(spec.v.base()) != 0
+	}
+	return false
+}
+
+func (spec pythonSpecifier) _equals(v pythonVersion) (res bool) {
+	if spec.wildcard {
+		// prefix matching of release segments, where candidate is zero-padded
+		if v.epoch != spec.v.epoch {
+			return false
+		}
+		for i, n := range spec.v.release {
+			var m int
+			if i < len(v.release) {
+				m = v.release[i]
+			}
+			if m != n {
+				return false
+			}
+		}
+		return true
+	}
+
+	// local version label is ignored if not specified
+	if len(spec.v.local) == 0 {
+		v = v.public()
+	}
+	return v.compare(spec.v) == 0
+}
+
+// contains returns true if the version satisfies all specifiers, where
+// pre-releases are only allowed if prereleases is true or any specifier
+// mentions a pre-release
+func (set pythonSpecifierSet) contains(raw string, prereleases bool) (res bool, err error) {
+	v, err := parsePythonVersion(raw)
+	if err != nil {
+		return false, err
+	}
+	if v.isPrerelease() && !prereleases && !set._isPrerelease() {
+		return false, nil
+	}
+	for _, spec := range set {
+		if !spec.contains(v, raw) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// isLower returns true if the version is lower than a lower bound of the
+// specifier set, i.e. upgrading would satisfy the specifier set
+func (set pythonSpecifierSet) isLower(raw string) (res bool) {
+	v, err := parsePythonVersion(raw)
+	if err != nil {
+		return false
+	}
+	for _, spec := range set {
+		switch spec.op {
+		case "==", "~=", ">=", ">":
+			if !spec.contains(v, raw) && v.public().compare(spec.v) < 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (set pythonSpecifierSet) _isPrerelease() (res bool) {
+	for _, spec := range set {
+		if spec.isPrerelease() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package services
+
+import "testing"
+
+func TestComparePythonVersions(t *testing.T) {
+	cases := []struct {
+		a, b string
+		res  int
+	}{
+		{"2.28", "2.28.0", 0},
+		{"2.28.1", "2.28", 1},
+		{"3.0rc1", "3.0", -1},
+		{"3.0rc1", "3.0b2", 1},
+		{"3.0.dev1", "3.0a1", -1},
+		{"1.0.post1.dev0", "1.0.post1", -1},
+		{"1.0.post1.dev0", "1.0", 1},
+		{"1.0.post1", "1.0.post0", 1},
+		{"1.0+local.1", "1.0", 1},
+		{"1!1.0", "2.0", 1},
+		{"1.0-RC1", "1.0rc1", 0},
+	}
+	for _, c := range cases {
+		res, err := comparePythonVersions(c.a, c.b)
+		if err != nil {
+			t.Fatalf("compare %s with %s: %v", c.a, c.b, err)
+		}
+		if res != c.res {
+			t.Errorf("compare %s with %s: expected %d, got %d", c.a, c.b, c.res, res)
+		}
+	}
+}
+
+func TestPythonSpecifierSet_Contains(t *testing.T) {
+	cases := []struct {
+		spec        string
+		version     string
+		prereleases bool
+		res         bool
+	}{
+		// compatible release
+		{"~=2.2", "2.3", false, true},
+		{"~=2.2", "3.0", false, false},
+		{"~=1.4.5", "1.4.9", false, true},
+		{"~=1.4.5", "1.5.0", false, false},
+		{"~=1.4.5", "1.4.4", false, false},
+
+		// prefix matching
+		{"==1.0.*", "1.0", false, true},
+		{"==1.0.*", "1.0.5", false, true},
+		{"==1.0.*", "1.1", false, false},
+		{"!=1.0.*", "1.1", false, true},
+		{"==2.28", "2.28.0", false, true},
+
+		// exclusive ordered comparison with pre- and post-releases
+		{">1.7", "1.7.post1", false, false},
+		{">1.7", "1.7.1", false, true},
+		{">1.7.post2", "1.7.post3", false, true},
+		{"<1.7", "1.7rc1", true, false},
+		{"<1.7", "1.6.9", false, true},
+		{"<1.7rc2", "1.7rc1", false, true},
+		{">1.7", "1.7+local", false, false},
+
+		// pre-releases are excluded unless allowed or mentioned
+		{">=3.0rc1", "3.0rc1", false, true},
+		{">=2.0", "3.0rc1", false, false},
+		{">=2.0", "3.0rc1", true, true},
+	}
+	for _, c := range cases {
+		set, err := parsePythonSpecifierSet(c.spec)
+		if err != nil {
+			t.Fatalf("parse %s: %v", c.spec, err)
+		}
+		res, err := set.contains(c.version, c.prereleases)
+		if err != nil {
+			t.Fatalf("%s contains %s: %v", c.spec, c.version, err)
+		}
+		if res != c.res {
+			t.Errorf("%s contains %s: expected %t, got %t", c.spec, c.version, c.res, res)
+		}
+	}
+}
+
+func TestParsePythonSpecifier_Invalid(t *testing.T) {
+	for _, spec := range []string{"~=1", ">=1.0.*", "=>1.0", "==foo"} {
+		if _, err := parsePythonSpecifierSet(spec); err == nil {
+			t.Errorf("expected error of %s", spec)
+		}
+	}
+}
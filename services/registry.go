@@ -0,0 +1,126 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	grpc "github.com/crawlab-team/crawlab-grpc"
+	"github.com/crawlab-team/plugin-dependency/entity"
+	"sync"
+)
+
+type messageHandler func(msg *grpc.StreamMessage, msgData entity.MessageData)
+
+// providerRegistry keeps track of registered dependency providers
+// and the stream message handlers derived from their message codes
+type providerRegistry struct {
+	mu        sync.RWMutex
+	keys      []string
+	providers map[string]*baseService
+	handlers  map[string]messageHandler
+}
+
+func (r *providerRegistry) register(p *baseService) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// validate key
+	if p.key == "" {
+		return errors.New("empty provider key")
+	}
+	if _, ok := r.providers[p.key]; ok {
+		return errors.New(fmt.Sprintf("provider already registered: %s", p.key))
+	}
+
+	// message handlers
+	handlers := map[string]messageHandler{
+		p.codes.Update:    p.updateDependencyList,
+		p.codes.Save:      p._saveDependencyList,
+		p.codes.Install:   p.installDependency,
+		p.codes.Uninstall: p.uninstallDependency,
+	}
+	for code := range handlers {
+		if code == "" {
+			return errors.New(fmt.Sprintf("empty message code for provider: %s", p.key))
+		}
+		if _, ok := r.handlers[code]; ok {
+			return errors.New(fmt.Sprintf("message code already registered: %s", code))
+		}
+	}
+
+	// register
+	for code, h := range handlers {
+		r.handlers[code] = h
+	}
+	r.providers[p.key] = p
+	r.keys = append(r.keys, p.key)
+
+	return nil
+}
+
+func (r *providerRegistry) unregister(key string) (ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// provider
+	p, ok := r.providers[key]
+	if !ok {
+		return false
+	}
+
+	// unregister
+	for _, code := range []string{p.codes.Update, p.codes.Save, p.codes.Install, p.codes.Uninstall} {
+		delete(r.handlers, code)
+	}
+	delete(r.providers, key)
+	for i, k := range r.keys {
+		if k == key {
+			r.keys = append(r.keys[:i], r.keys[i+1:]...)
+			break
+		}
+	}
+
+	return true
+}
+
+func (r *providerRegistry) get(key string) (p *baseService, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok = r.providers[key]
+	return p, ok
+}
+
+func (r *providerRegistry) getHandler(code string) (h messageHandler, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	h, ok = r.handlers[code]
+	return h, ok
+}
+
+// getByManifest returns the first provider in order of registration, and
+// its manifest file name which satisfy the given function
+func (r *providerRegistry) getByManifest(fn func(p *baseService, manifest string) bool) (p *baseService, manifest string, ok bool) {
+	for _, p := range r.list() {
+		for _, manifest := range p._getManifests() {
+			if fn(p, manifest) {
+				return p, manifest, true
+			}
+		}
+	}
+	return nil, "", false
+}
+
+func (r *providerRegistry) list() (providers []*baseService) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, key := range r.keys {
+		providers = append(providers, r.providers[key])
+	}
+	return providers
+}
+
+func newProviderRegistry() (r *providerRegistry) {
+	return &providerRegistry{
+		providers: map[string]*baseService{},
+		handlers:  map[string]messageHandler{},
+	}
+}
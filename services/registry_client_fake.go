@@ -0,0 +1,105 @@
+package services
+
+import (
+	"encoding/json"
+	"github.com/crawlab-team/crawlab-core/utils"
+	"github.com/crawlab-team/go-trace"
+	"github.com/crawlab-team/plugin-dependency/entity"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FakeRegistryClient looks up packages in json files of a directory, one
+// file for each package named "<name>.json" with "/" replaced by "__",
+// which allows providers to be tested without network
+type FakeRegistryClient struct {
+	dirPath string
+}
+
+// Search returns packages whose names contain the query, sorted by name
+func (client *FakeRegistryClient) Search(query string, page, size int) (pkgs []entity.RegistryPackage, total int, err error) {
+	// package files
+	filePaths, err := filepath.Glob(filepath.Join(client.dirPath, "*.json"))
+	if err != nil {
+		return nil, 0, trace.TraceError(err)
+	}
+
+	// matched packages
+	var matched []entity.RegistryPackage
+	query = strings.ToLower(strings.TrimSpace(query))
+	for _, filePath := range filePaths {
+		pkg, err := client._readPackage(filePath)
+		if err != nil {
+			return nil, 0, err
+		}
+		if strings.Contains(strings.ToLower(pkg.Name), query) {
+			matched = append(matched, *pkg)
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].Name < matched[j].Name
+	})
+
+	// pagination
+	total = len(matched)
+	start := (page - 1) * size
+	if start < 0 || start >= total {
+		return nil, total, nil
+	}
+	end := start + size
+	if end > total {
+		end = total
+	}
+	return matched[start:end], total, nil
+}
+
+func (client *FakeRegistryClient) GetVersions(name string) (versions []string, err error) {
+	pkg, err := client.GetPackage(name)
+	if err != nil {
+		return nil, err
+	}
+	if pkg == nil {
+		return nil, nil
+	}
+	return pkg.Versions, nil
+}
+
+func (client *FakeRegistryClient) GetLatestVersion(name string) (v string, err error) {
+	pkg, err := client.GetPackage(name)
+	if err != nil {
+		return "", err
+	}
+	if pkg == nil {
+		return "", nil
+	}
+	return pkg.LatestVersion, nil
+}
+
+func (client *FakeRegistryClient) GetPackage(name string) (pkg *entity.RegistryPackage, err error) {
+	filePath := filepath.Join(client.dirPath, strings.ReplaceAll(name, "/", "__")+".json")
+	if !utils.Exists(filePath) {
+		return nil, nil
+	}
+	return client._readPackage(filePath)
+}
+
+func (client *FakeRegistryClient) _readPackage(filePath string) (pkg *entity.RegistryPackage, err error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, trace.TraceError(err)
+	}
+	pkg = &entity.RegistryPackage{}
+	if err := json.Unmarshal(data, pkg); err != nil {
+		return nil, trace.TraceError(err)
+	}
+	return pkg, nil
+}
+
+func NewFakeRegistryClient(dirPath string) (client *FakeRegistryClient) {
+	return &FakeRegistryClient{
+		dirPath: dirPath,
+	}
+}
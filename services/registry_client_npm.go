@@ -0,0 +1,217 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/crawlab-team/go-trace"
+	"github.com/crawlab-team/plugin-dependency/constants"
+	"github.com/crawlab-team/plugin-dependency/entity"
+	"github.com/imroc/req"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// NpmRegistryClient looks up packages with the npm registry protocol in
+// npm registry, or in the private registry or mirror if set
+type NpmRegistryClient struct {
+	registryUrl string
+}
+
+// Search searches packages with "/-/v1/search" of the registry, which is
+// also supported by mirrors such as Verdaccio and Nexus
+func (client *NpmRegistryClient) Search(query string, page, size int) (pkgs []entity.RegistryPackage, total int, err error) {
+	// request session
+	reqSession := req.New()
+
+	// set timeout
+	reqSession.SetTimeout(15 * time.Second)
+
+	// request url
+	requestUrl := fmt.Sprintf("%s/-/v1/search?text=%s&from=%d&size=%d", client.registryUrl, url.QueryEscape(query), (page-1)*size, size)
+
+	// perform request
+	res, err := reqSession.Get(requestUrl)
+	if err != nil {
+		return nil, 0, trace.TraceError(err)
+	}
+	switch res.Response().StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		// registry without search endpoint, where the query is looked up
+		// as a package name
+		pkg, err := client.GetPackage(strings.TrimSpace(query))
+		if err != nil || pkg == nil || page > 1 {
+			return nil, 0, err
+		}
+		return []entity.RegistryPackage{*pkg}, 1, nil
+	default:
+		return nil, 0, trace.TraceError(errors.New(fmt.Sprintf("request %s failed: %s", requestUrl, res.Response().Status)))
+	}
+
+	// response
+	var searchRes entity.NpmSearchResponse
+	if err := res.ToJSON(&searchRes); err != nil {
+		return nil, 0, trace.TraceError(err)
+	}
+	for _, o := range searchRes.Objects {
+		pkgs = append(pkgs, entity.RegistryPackage{
+			Name:          o.Package.Name,
+			Description:   o.Package.Description,
+			LatestVersion: o.Package.Version,
+			Homepage:      o.Package.Links.Homepage,
+		})
+	}
+
+	return pkgs, searchRes.Total, nil
+}
+
+func (client *NpmRegistryClient) GetVersions(name string) (versions []string, err error) {
+	packument, err := client._getPackument(name, true)
+	if err != nil {
+		return nil, err
+	}
+	if packument == nil {
+		return nil, nil
+	}
+	for v := range packument.Versions {
+		versions = append(versions, v)
+	}
+	sortNpmVersions(versions)
+	return versions, nil
+}
+
+// GetLatestVersion returns the version tagged as latest
+func (client *NpmRegistryClient) GetLatestVersion(name string) (v string, err error) {
+	packument, err := client._getPackument(name, true)
+	if err != nil {
+		return "", err
+	}
+	if packument == nil {
+		return "", nil
+	}
+	return packument.DistTags["latest"], nil
+}
+
+// GetPackage returns the full metadata of package, including release time,
+// deprecation and engines of each version
+func (client *NpmRegistryClient) GetPackage(name string) (pkg *entity.RegistryPackage, err error) {
+	packument, err := client._getPackument(name, false)
+	if err != nil {
+		return nil, err
+	}
+	if packument == nil {
+		return nil, nil
+	}
+	pkg = &entity.RegistryPackage{
+		Name:          packument.Name,
+		Description:   packument.Description,
+		LatestVersion: packument.DistTags["latest"],
+		DistTags:      packument.DistTags,
+		Homepage:      packument.Homepage,
+		License:       getNpmLicense(packument.License),
+	}
+	releasesMap := map[string]entity.RegistryRelease{}
+	for v, pv := range packument.Versions {
+		pkg.Versions = append(pkg.Versions, v)
+		release := entity.RegistryRelease{
+			Version: v,
+			Engines: getNpmEngines(pv.Engines),
+		}
+		// deprecated is a message, or false in some legacy packages
+		if deprecated, ok := pv.Deprecated.(string); ok {
+			release.Deprecated = deprecated
+		}
+		if ts, err := time.Parse(time.RFC3339, packument.Time[v]); err == nil {
+			release.ReleaseTs = ts
+		}
+		releasesMap[v] = release
+	}
+	sortNpmVersions(pkg.Versions)
+	for _, v := range pkg.Versions {
+		pkg.Releases = append(pkg.Releases, releasesMap[v])
+	}
+	if release, ok := releasesMap[pkg.LatestVersion]; ok {
+		pkg.Engines = release.Engines
+	}
+
+	return pkg, nil
+}
+
+// _getPackument returns the metadata of package, which is abbreviated to
+// versions and dist-tags if abbreviated is true
+func (client *NpmRegistryClient) _getPackument(name string, abbreviated bool) (packument *entity.NpmPackument, err error) {
+	// request session
+	reqSession := req.New()
+
+	// set timeout
+	reqSession.SetTimeout(15 * time.Second)
+
+	// metadata format
+	header := req.Header{"accept": "application/json"}
+	if abbreviated {
+		header["accept"] = "application/vnd.npm.install-v1+json"
+	}
+
+	// request url, where scoped names are escaped, e.g. "@types%2fnode"
+	requestUrl := fmt.Sprintf("%s/%s", client.registryUrl, strings.Replace(name, "/", "%2f", 1))
+
+	// perform request
+	res, err := reqSession.Get(requestUrl, header)
+	if err != nil {
+		return nil, trace.TraceError(err)
+	}
+	if res.Response().StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.Response().StatusCode != http.StatusOK {
+		return nil, trace.TraceError(errors.New(fmt.Sprintf("request %s failed: %s", requestUrl, res.Response().Status)))
+	}
+
+	// response
+	packument = &entity.NpmPackument{}
+	if err := res.ToJSON(packument); err != nil {
+		return nil, trace.TraceError(err)
+	}
+
+	return packument, nil
+}
+
+// getNpmLicense returns the license of package.json, which is a SPDX
+// expression or a legacy object such as {"type": "MIT"}
+func getNpmLicense(license interface{}) (res string) {
+	switch l := license.(type) {
+	case string:
+		return l
+	case map[string]interface{}:
+		res, _ = l["type"].(string)
+		return res
+	}
+	return ""
+}
+
+// getNpmEngines returns the engines of package.json, ignoring legacy
+// engines declared as array
+func getNpmEngines(engines interface{}) (res map[string]string) {
+	m, ok := engines.(map[string]interface{})
+	if !ok || len(m) == 0 {
+		return nil
+	}
+	res = map[string]string{}
+	for k, v := range m {
+		if s, ok := v.(string); ok {
+			res[k] = s
+		}
+	}
+	return res
+}
+
+func NewNpmRegistryClient(registryUrl string) (client *NpmRegistryClient) {
+	if registryUrl == "" {
+		registryUrl = constants.NpmRegistryDefaultUrl
+	}
+	return &NpmRegistryClient{
+		registryUrl: strings.TrimSuffix(registryUrl, "/"),
+	}
+}
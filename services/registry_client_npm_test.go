@@ -0,0 +1,220 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const npmTestFullPackument = `{
+	"name": "@types/node",
+	"description": "TypeScript definitions for Node.js",
+	"homepage": "https://github.com/DefinitelyTyped/DefinitelyTyped",
+	"license": {"type": "MIT"},
+	"dist-tags": {"latest": "18.7.14", "next": "19.0.0-beta.1"},
+	"versions": {
+		"0.0.1": {"version": "0.0.1", "engines": ["node >= 0.4"], "deprecated": false},
+		"18.7.14": {"version": "18.7.14", "engines": {"node": ">=12"}},
+		"8.10.66": {"version": "8.10.66", "deprecated": "use newer versions"},
+		"19.0.0-beta.1": {"version": "19.0.0-beta.1"}
+	},
+	"time": {
+		"created": "2016-05-17T04:21:56.146Z",
+		"0.0.1": "2016-05-17T04:21:56.146Z",
+		"18.7.14": "2022-08-30T18:03:57.839Z"
+	}
+}`
+
+const npmTestAbbreviatedPackument = `{
+	"name": "@types/node",
+	"dist-tags": {"latest": "18.7.14"},
+	"versions": {
+		"18.7.14": {"version": "18.7.14"},
+		"8.10.66": {"version": "8.10.66"}
+	}
+}`
+
+// newNpmTestServer returns a registry which responds to search of the third
+// page of size 10 with the given status, and to metadata of "@types/node" escaped as "@types%2fnode"
+// in abbreviated or full format by the accept header
+func newNpmTestServer(t *testing.T, searchStatus int) (server *httptest.Server) {
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/-/v1/search":
+			if searchStatus != http.StatusOK {
+				w.WriteHeader(searchStatus)
+				return
+			}
+			if r.URL.Query().Get("from") != "20" || r.URL.Query().Get("size") != "10" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{
+				"objects": [{"package": {"name": "@types/node", "version": "18.7.14", "links": {"homepage": "https://example.com"}}}],
+				"total": 21
+			}`))
+		case r.RequestURI == "/@types%2fnode":
+			if r.Header.Get("accept") == "application/vnd.npm.install-v1+json" {
+				_, _ = w.Write([]byte(npmTestAbbreviatedPackument))
+				return
+			}
+			_, _ = w.Write([]byte(npmTestFullPackument))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNpmRegistryClient_Search(t *testing.T) {
+	// search endpoint
+	server := newNpmTestServer(t, http.StatusOK)
+	client := NewNpmRegistryClient(server.URL + "/")
+	pkgs, total, err := client.Search("node", 3, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 21 || len(pkgs) != 1 || pkgs[0].Name != "@types/node" || pkgs[0].Homepage != "https://example.com" {
+		t.Errorf("unexpected search result: %d %v", total, pkgs)
+	}
+
+	// registries without search endpoint, where the query is looked
+	// up as a package name
+	for _, status := range []int{http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented} {
+		server := newNpmTestServer(t, status)
+		client := NewNpmRegistryClient(server.URL)
+		pkgs, total, err := client.Search(" @types/node ", 1, 10)
+		if err != nil {
+			t.Fatalf("search with status %d: %v", status, err)
+		}
+		if total != 1 || len(pkgs) != 1 || pkgs[0].Name != "@types/node" {
+			t.Errorf("search with status %d: unexpected result %d %v", status, total, pkgs)
+		}
+		pkgs, total, err = client.Search("missing", 1, 10)
+		if err != nil || total != 0 || len(pkgs) != 0 {
+			t.Errorf("search with status %d: expected no results, got %d %v %v", status, total, pkgs, err)
+		}
+		pkgs, _, err = client.Search("@types/node", 2, 10)
+		if err != nil || len(pkgs) != 0 {
+			t.Errorf("search with status %d: expected empty second page, got %v %v", status, pkgs, err)
+		}
+	}
+
+	// other errors
+	server = newNpmTestServer(t, http.StatusInternalServerError)
+	if _, _, err := NewNpmRegistryClient(server.URL).Search("node", 1, 10); err == nil {
+		t.Error("expected error of internal server error")
+	}
+}
+
+func TestNpmRegistryClient_Abbreviated(t *testing.T) {
+	server := newNpmTestServer(t, http.StatusOK)
+	client := NewNpmRegistryClient(server.URL)
+
+	// versions and latest version from abbreviated metadata
+	versions, err := client.GetVersions("@types/node")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"8.10.66", "18.7.14"}; !reflect.DeepEqual(versions, expected) {
+		t.Errorf("expected versions %v, got %v", expected, versions)
+	}
+	v, err := client.GetLatestVersion("@types/node")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "18.7.14" {
+		t.Errorf("expected latest version 18.7.14, got %s", v)
+	}
+
+	// not found
+	versions, err = client.GetVersions("@types/missing")
+	if err != nil || versions != nil {
+		t.Errorf("expected not found, got %v %v", versions, err)
+	}
+	v, err = client.GetLatestVersion("missing")
+	if err != nil || v != "" {
+		t.Errorf("expected not found, got %q %v", v, err)
+	}
+}
+
+func TestNpmRegistryClient_GetPackage(t *testing.T) {
+	server := newNpmTestServer(t, http.StatusOK)
+	client := NewNpmRegistryClient(server.URL)
+
+	// full metadata
+	pkg, err := client.GetPackage("@types/node")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg == nil {
+		t.Fatal("package not found")
+	}
+	if pkg.Description != "TypeScript definitions for Node.js" || pkg.LatestVersion != "18.7.14" || pkg.DistTags["next"] != "19.0.0-beta.1" {
+		t.Errorf("unexpected package: %+v", pkg)
+	}
+	if expected := []string{"0.0.1", "8.10.66", "18.7.14", "19.0.0-beta.1"}; !reflect.DeepEqual(pkg.Versions, expected) {
+		t.Errorf("expected versions %v, got %v", expected, pkg.Versions)
+	}
+
+	// legacy license, engines and deprecated
+	if pkg.License != "MIT" {
+		t.Errorf("expected license MIT, got %q", pkg.License)
+	}
+	if expected := map[string]string{"node": ">=12"}; !reflect.DeepEqual(pkg.Engines, expected) {
+		t.Errorf("expected engines %v, got %v", expected, pkg.Engines)
+	}
+	releases := map[string]int{}
+	for i, r := range pkg.Releases {
+		releases[r.Version] = i
+	}
+	legacy := pkg.Releases[releases["0.0.1"]]
+	if legacy.Engines != nil || legacy.Deprecated != "" {
+		t.Errorf("unexpected legacy release: %+v", legacy)
+	}
+	if !legacy.ReleaseTs.Equal(time.Date(2016, 5, 17, 4, 21, 56, 146000000, time.UTC)) {
+		t.Errorf("unexpected release time: %v", legacy.ReleaseTs)
+	}
+	if r := pkg.Releases[releases["8.10.66"]]; r.Deprecated != "use newer versions" || !r.ReleaseTs.IsZero() {
+		t.Errorf("unexpected deprecated release: %+v", r)
+	}
+}
+
+func TestGetNpmLicense(t *testing.T) {
+	cases := []struct {
+		license interface{}
+		res     string
+	}{
+		{"MIT", "MIT"},
+		{"(MIT OR Apache-2.0)", "(MIT OR Apache-2.0)"},
+		{map[string]interface{}{"type": "BSD", "url": "https://example.com"}, "BSD"},
+		{[]interface{}{map[string]interface{}{"type": "MIT"}}, ""},
+		{nil, ""},
+	}
+	for _, c := range cases {
+		if res := getNpmLicense(c.license); res != c.res {
+			t.Errorf("license of %v: expected %q, got %q", c.license, c.res, res)
+		}
+	}
+}
+
+func TestGetNpmEngines(t *testing.T) {
+	cases := []struct {
+		engines interface{}
+		res     map[string]string
+	}{
+		{map[string]interface{}{"node": ">=12", "npm": ">=6"}, map[string]string{"node": ">=12", "npm": ">=6"}},
+		{map[string]interface{}{"node": ">=12", "invalid": 1}, map[string]string{"node": ">=12"}},
+		{[]interface{}{"node >= 0.4"}, nil},
+		{map[string]interface{}{}, nil},
+		{nil, nil},
+	}
+	for _, c := range cases {
+		if res := getNpmEngines(c.engines); !reflect.DeepEqual(res, c.res) {
+			t.Errorf("engines of %v: expected %v, got %v", c.engines, c.res, res)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/crawlab-team/go-trace"
+	"github.com/crawlab-team/plugin-dependency/constants"
+	"github.com/crawlab-team/plugin-dependency/entity"
+	"github.com/imroc/req"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PypiRegistryClient looks up projects with the PyPI JSON API, or with
+// the simple API of the index if set, which is supported by mirrors such
+// as devpi, Nexus and Artifactory
+type PypiRegistryClient struct {
+	indexUrl string
+}
+
+// Search looks up the query as a project name, as neither PyPI nor simple
+// index mirrors provide a search api
+func (client *PypiRegistryClient) Search(query string, page, size int) (pkgs []entity.RegistryPackage, total int, err error) {
+	pkg, err := client.GetPackage(strings.TrimSpace(query))
+	if err != nil {
+		return nil, 0, err
+	}
+	if pkg == nil {
+		return nil, 0, nil
+	}
+	if page > 1 {
+		return nil, 1, nil
+	}
+	return []entity.RegistryPackage{*pkg}, 1, nil
+}
+
+func (client *PypiRegistryClient) GetVersions(name string) (versions []string, err error) {
+	pkg, err := client.GetPackage(name)
+	if err != nil {
+		return nil, err
+	}
+	if pkg == nil {
+		return nil, nil
+	}
+	return pkg.Versions, nil
+}
+
+func (client *PypiRegistryClient) GetLatestVersion(name string) (v string, err error) {
+	pkg, err := client.GetPackage(name)
+	if err != nil {
+		return "", err
+	}
+	if pkg == nil {
+		return "", nil
+	}
+	return pkg.LatestVersion, nil
+}
+
+func (client *PypiRegistryClient) GetPackage(name string) (pkg *entity.RegistryPackage, err error) {
+	if client.indexUrl == "" || client.indexUrl == constants.PypiSimpleDefaultUrl {
+		return client._getJsonPackage(name)
+	}
+	return client._getSimplePackage(name)
+}
+
+func (client *PypiRegistryClient) _getJsonPackage(name string) (pkg *entity.RegistryPackage, err error) {
+	// request session
+	reqSession := req.New()
+
+	// set timeout
+	reqSession.SetTimeout(60 * time.Second)
+
+	// request url
+	requestUrl := fmt.Sprintf("%s/pypi/%s/json", constants.PypiDefaultUrl, url.PathEscape(name))
+
+	// perform request
+	res, err := reqSession.Get(requestUrl)
+	if err != nil {
+		return nil, trace.TraceError(err)
+	}
+	if res.Response().StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.Response().StatusCode != http.StatusOK {
+		return nil, trace.TraceError(errors.New(fmt.Sprintf("request %s failed: %s", requestUrl, res.Response().Status)))
+	}
+
+	// response
+	var pypiRes entity.PypiJsonResponse
+	if err := res.ToJSON(&pypiRes); err != nil {
+		return nil, trace.TraceError(err)
+	}
+
+	// versions, excluding those with all files yanked
+	pkg = &entity.RegistryPackage{
+		Name:           pypiRes.Info.Name,
+		Description:    pypiRes.Info.Summary,
+		LatestVersion:  pypiRes.Info.Version,
+		Homepage:       pypiRes.Info.HomePage,
+		License:        pypiRes.Info.License,
+		RequiresPython: pypiRes.Info.RequiresPython,
+	}
+	for v, files := range pypiRes.Releases {
+		if len(files) == 0 {
+			continue
+		}
+		release := client._getRelease(v, files)
+		pkg.Releases = append(pkg.Releases, release)
+		if release.Yanked {
+			continue
+		}
+		pkg.Versions = append(pkg.Versions, v)
+	}
+	sortPythonVersions(pkg.Versions)
+	sortPythonReleases(pkg.Releases)
+
+	return pkg, nil
+}
+
+func (client *PypiRegistryClient) _getSimplePackage(name string) (pkg *entity.RegistryPackage, err error) {
+	// request session
+	reqSession := req.New()
+
+	// set timeout
+	reqSession.SetTimeout(60 * time.Second)
+
+	// prefer json (PEP 691) to html (PEP 503)
+	header := req.Header{"accept": "application/vnd.pypi.simple.v1+json, text/html;q=0.1"}
+
+	// request url of normalized name
+	requestUrl := fmt.Sprintf("%s/%s/", client.indexUrl, normalizePythonName(name))
+
+	// perform request
+	res, err := reqSession.Get(requestUrl, header)
+	if err != nil {
+		return nil, trace.TraceError(err)
+	}
+	if res.Response().StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.Response().StatusCode != http.StatusOK {
+		return nil, trace.TraceError(errors.New(fmt.Sprintf("request %s failed: %s", requestUrl, res.Response().Status)))
+	}
+
+	// distribution files
+	var files []entity.PypiFile
+	if strings.Contains(res.Response().Header.Get("content-type"), "json") {
+		var simpleRes entity.PypiSimpleResponse
+		if err := res.ToJSON(&simpleRes); err != nil {
+			return nil, trace.TraceError(err)
+		}
+		for _, sf := range simpleRes.Files {
+			f := entity.PypiFile{
+				Filename:       sf.Filename,
+				UploadTime:     sf.UploadTime,
+				RequiresPython: sf.RequiresPython,
+			}
+			switch yanked := sf.Yanked.(type) {
+			case bool:
+				f.Yanked = yanked
+			case string:
+				f.Yanked = true
+				f.YankedReason = yanked
+			}
+			files = append(files, f)
+		}
+	} else {
+		data, err := res.ToBytes()
+		if err != nil {
+			return nil, trace.TraceError(err)
+		}
+		doc, err := goquery.NewDocumentFromReader(bytes.NewBuffer(data))
+		if err != nil {
+			return nil, trace.TraceError(err)
+		}
+		doc.Find("a").Each(func(i int, s *goquery.Selection) {
+			f := entity.PypiFile{
+				Filename: strings.TrimSpace(s.Text()),
+			}
+			if reason, ok := s.Attr("data-yanked"); ok {
+				f.Yanked = true
+				f.YankedReason = reason
+			}
+			f.RequiresPython, _ = s.Attr("data-requires-python")
+			files = append(files, f)
+		})
+	}
+
+	// versions of files, excluding those with all files yanked
+	filesMap := map[string][]entity.PypiFile{}
+	for _, f := range files {
+		v := client._getFileVersion(name, f.Filename)
+		if v == "" {
+			continue
+		}
+		filesMap[v] = append(filesMap[v], f)
+	}
+	pkg = &entity.RegistryPackage{
+		Name: name,
+	}
+	for v, files := range filesMap {
+		release := client._getRelease(v, files)
+		pkg.Releases = append(pkg.Releases, release)
+		if release.Yanked {
+			continue
+		}
+		pkg.Versions = append(pkg.Versions, v)
+	}
+	sortPythonVersions(pkg.Versions)
+	sortPythonReleases(pkg.Releases)
+
+	// latest version, excluding pre-releases
+	for i := len(pkg.Versions) - 1; i >= 0; i-- {
+		pv, err := parsePythonVersion(pkg.Versions[i])
+		if err != nil || pv.isPrerelease() {
+			continue
+		}
+		pkg.LatestVersion = pkg.Versions[i]
+		break
+	}
+
+	return pkg, nil
+}
+
+// _getFileVersion returns the version of distribution file, e.g. "2.28.1" of
+// "requests-2.28.1.tar.gz" or "requests-2.28.1-py3-none-any.whl"
+func (client *PypiRegistryClient) _getFileVersion(name, filename string) (v string) {
+	// file extension
+	var isBinary bool
+	switch {
+	case strings.HasSuffix(filename, ".whl"), strings.HasSuffix(filename, ".egg"):
+		isBinary = true
+		filename = filename[:len(filename)-4]
+	case strings.HasSuffix(filename, ".tar.gz"), strings.HasSuffix(filename, ".tar.bz2"):
+		filename = filename[:strings.LastIndex(filename, ".tar.")]
+	case strings.HasSuffix(filename, ".zip"):
+		filename = filename[:len(filename)-4]
+	default:
+		return ""
+	}
+
+	// split after the project name, whose separators may be normalized
+	normalizedName := normalizePythonName(name)
+	for i, c := range filename {
+		if c != '-' || normalizePythonName(filename[:i]) != normalizedName {
+			continue
+		}
+		v = filename[i+1:]
+		if isBinary {
+			v = strings.SplitN(v, "-", 2)[0]
+		}
+		if _, err := parsePythonVersion(v); err != nil {
+			return ""
+		}
+		return v
+	}
+	return ""
+}
+
+// _getRelease returns the release of files, which is yanked if all files
+// are yanked and released at the earliest upload time of files
+func (client *PypiRegistryClient) _getRelease(v string, files []entity.PypiFile) (release entity.RegistryRelease) {
+	release = entity.RegistryRelease{
+		Version: v,
+		Yanked:  len(files) > 0,
+	}
+	for _, f := range files {
+		if !f.Yanked {
+			release.Yanked = false
+		} else if release.YankedReason == "" {
+			release.YankedReason = f.YankedReason
+		}
+		if release.RequiresPython == "" {
+			release.RequiresPython = f.RequiresPython
+		}
+		ts, err := time.Parse(time.RFC3339, f.UploadTime)
+		if err != nil {
+			continue
+		}
+		if release.ReleaseTs.IsZero() || ts.Before(release.ReleaseTs) {
+			release.ReleaseTs = ts
+		}
+	}
+	if !release.Yanked {
+		release.YankedReason = ""
+	}
+	return release
+}
+
+// normalizePythonName returns the normalized project name of PEP 503
+func normalizePythonName(name string) (res string) {
+	return strings.ToLower(pythonNameSeparatorPattern.ReplaceAllString(name, "-"))
+}
+
+// sortPythonVersions sorts PEP 440 versions in ascending order, where
+// invalid versions are sorted first in order of strings
+func sortPythonVersions(versions []string) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		res, err := comparePythonVersions(versions[i], versions[j])
+		if err != nil {
+			_, errI := parsePythonVersion(versions[i])
+			_, errJ := parsePythonVersion(versions[j])
+			if errI != nil && errJ != nil {
+				return versions[i] < versions[j]
+			}
+			return errI != nil
+		}
+		return res < 0
+	})
+}
+
+// sortPythonReleases sorts releases by PEP 440 versions in ascending order
+func sortPythonReleases(releases []entity.RegistryRelease) {
+	versions := make([]string, len(releases))
+	releasesMap := map[string]entity.RegistryRelease{}
+	for i, r := range releases {
+		versions[i] = r.Version
+		releasesMap[r.Version] = r
+	}
+	sortPythonVersions(versions)
+	for i, v := range versions {
+		releases[i] = releasesMap[v]
+	}
+}
+
+func NewPypiRegistryClient(indexUrl string) (client *PypiRegistryClient) {
+	return &PypiRegistryClient{
+		indexUrl: strings.TrimSuffix(indexUrl, "/"),
+	}
+}
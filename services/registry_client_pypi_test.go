@@ -0,0 +1,181 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/crawlab-team/plugin-dependency/entity"
+)
+
+func newPypiTestServer(t *testing.T) (server *httptest.Server) {
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json/requests/":
+			// PEP 691
+			w.Header().Set("content-type", "application/vnd.pypi.simple.v1+json")
+			_, _ = w.Write([]byte(`{
+				"name": "requests",
+				"files": [
+					{"filename": "requests-2.28.1.tar.gz", "upload-time": "2022-06-29T15:13:00Z", "yanked": false},
+					{"filename": "requests-2.28.1-py3-none-any.whl", "upload-time": "2022-06-29T15:12:00Z", "yanked": false, "requires-python": ">=3.7"},
+					{"filename": "requests-2.28.2.tar.gz", "yanked": true},
+					{"filename": "requests-2.28.2-py3-none-any.whl", "yanked": false},
+					{"filename": "requests-2.29.0.tar.gz", "yanked": true},
+					{"filename": "requests-2.29.0-py3-none-any.whl", "yanked": "broken release"},
+					{"filename": "requests-3.0.0rc1.tar.gz"},
+					{"filename": "requests-toolbelt-0.9.1.tar.gz"},
+					{"filename": "README.txt"}
+				]
+			}`))
+		case "/html/scrapy-redis/":
+			// PEP 503
+			w.Header().Set("content-type", "text/html")
+			_, _ = w.Write([]byte(`<!DOCTYPE html>
+<html><body>
+<a href="scrapy-redis-0.7.1.tar.gz" data-requires-python="&gt;=3.6">scrapy-redis-0.7.1.tar.gz</a>
+<a href="scrapy_redis-0.7.2-py2.py3-none-any.whl">scrapy_redis-0.7.2-py2.py3-none-any.whl</a>
+<a href="scrapy-redis-0.7.3.tar.gz" data-yanked="">scrapy-redis-0.7.3.tar.gz</a>
+<a href="scrapy_redis-0.7.3-py2.py3-none-any.whl" data-yanked="wrong dependencies">scrapy_redis-0.7.3-py2.py3-none-any.whl</a>
+</body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestPypiRegistryClient_GetSimplePackage(t *testing.T) {
+	server := newPypiTestServer(t)
+
+	// json
+	client := NewPypiRegistryClient(server.URL + "/json/")
+	pkg, err := client.GetPackage("requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg == nil {
+		t.Fatal("package not found")
+	}
+	if expected := []string{"2.28.1", "2.28.2", "3.0.0rc1"}; !reflect.DeepEqual(pkg.Versions, expected) {
+		t.Errorf("expected versions %v, got %v", expected, pkg.Versions)
+	}
+	if pkg.LatestVersion != "2.28.2" {
+		t.Errorf("expected latest version 2.28.2, got %s", pkg.LatestVersion)
+	}
+	var versions []string
+	for _, r := range pkg.Releases {
+		versions = append(versions, r.Version)
+	}
+	if expected := []string{"2.28.1", "2.28.2", "2.29.0", "3.0.0rc1"}; !reflect.DeepEqual(versions, expected) {
+		t.Errorf("expected releases %v, got %v", expected, versions)
+	}
+	if r := pkg.Releases[0]; r.RequiresPython != ">=3.7" || !r.ReleaseTs.Equal(time.Date(2022, 6, 29, 15, 12, 0, 0, time.UTC)) {
+		t.Errorf("unexpected release: %+v", r)
+	}
+	if r := pkg.Releases[1]; r.Yanked {
+		t.Errorf("release with files not yanked is yanked: %+v", r)
+	}
+	if r := pkg.Releases[2]; !r.Yanked || r.YankedReason != "broken release" {
+		t.Errorf("expected yanked release, got %+v", r)
+	}
+
+	// html of normalized name
+	client = NewPypiRegistryClient(server.URL + "/html")
+	pkg, err = client.GetPackage("Scrapy_Redis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg == nil {
+		t.Fatal("package not found")
+	}
+	if expected := []string{"0.7.1", "0.7.2"}; !reflect.DeepEqual(pkg.Versions, expected) {
+		t.Errorf("expected versions %v, got %v", expected, pkg.Versions)
+	}
+	if pkg.LatestVersion != "0.7.2" {
+		t.Errorf("expected latest version 0.7.2, got %s", pkg.LatestVersion)
+	}
+	if len(pkg.Releases) != 3 {
+		t.Fatalf("expected 3 releases, got %v", pkg.Releases)
+	}
+	if r := pkg.Releases[0]; r.RequiresPython != ">=3.6" {
+		t.Errorf("unexpected release: %+v", r)
+	}
+	if r := pkg.Releases[2]; !r.Yanked || r.YankedReason != "wrong dependencies" {
+		t.Errorf("expected yanked release, got %+v", r)
+	}
+
+	// not found
+	pkg, err = client.GetPackage("missing")
+	if err != nil || pkg != nil {
+		t.Errorf("expected not found, got %v %v", pkg, err)
+	}
+}
+
+func TestPypiRegistryClient_GetFileVersion(t *testing.T) {
+	client := NewPypiRegistryClient("")
+	cases := []struct {
+		name     string
+		filename string
+		v        string
+	}{
+		{"requests", "requests-2.28.1.tar.gz", "2.28.1"},
+		{"requests", "requests-2.28.1-py3-none-any.whl", "2.28.1"},
+		{"scrapy-redis", "scrapy_redis-0.7.2-py2.py3-none-any.whl", "0.7.2"},
+		{"Scrapy_Redis", "scrapy-redis-0.7.3.tar.bz2", "0.7.3"},
+		{"Django", "Django-4.1.zip", "4.1"},
+		{"setuptools", "setuptools-0.6c11-py2.7.egg", "0.6c11"},
+		{"numpy", "numpy-1.23.0rc1-cp39-cp39-manylinux_2_17_x86_64.whl", "1.23.0rc1"},
+		{"requests", "requests-toolbelt-0.9.1.tar.gz", ""},
+		{"requests", "requests-2.28.1.exe", ""},
+		{"requests", "README.txt", ""},
+	}
+	for _, c := range cases {
+		if v := client._getFileVersion(c.name, c.filename); v != c.v {
+			t.Errorf("version of %s in %s: expected %q, got %q", c.name, c.filename, c.v, v)
+		}
+	}
+}
+
+func TestPypiRegistryClient_GetRelease(t *testing.T) {
+	client := NewPypiRegistryClient("")
+	cases := []struct {
+		name   string
+		files  []entity.PypiFile
+		yanked bool
+		reason string
+	}{
+		{"no files", nil, false, ""},
+		{"none yanked", []entity.PypiFile{{}, {}}, false, ""},
+		{"partially yanked", []entity.PypiFile{{Yanked: true, YankedReason: "broken"}, {}}, false, ""},
+		{"all yanked", []entity.PypiFile{{Yanked: true}, {Yanked: true, YankedReason: "broken"}}, true, "broken"},
+	}
+	for _, c := range cases {
+		r := client._getRelease("1.0", c.files)
+		if r.Yanked != c.yanked || r.YankedReason != c.reason {
+			t.Errorf("%s: expected %t %q, got %t %q", c.name, c.yanked, c.reason, r.Yanked, r.YankedReason)
+		}
+	}
+
+	// earliest upload time of files
+	r := client._getRelease("1.0", []entity.PypiFile{
+		{UploadTime: "2022-06-29T15:13:00Z"},
+		{UploadTime: "invalid"},
+		{UploadTime: "2022-06-29T15:12:00Z"},
+	})
+	if !r.ReleaseTs.Equal(time.Date(2022, 6, 29, 15, 12, 0, 0, time.UTC)) {
+		t.Errorf("unexpected release time: %v", r.ReleaseTs)
+	}
+}
+
+func TestSortPythonVersions(t *testing.T) {
+	versions := []string{"b-invalid", "1.0", "a-invalid", "1.0rc1", "0.9", "c-invalid"}
+	sortPythonVersions(versions)
+	expected := []string{"a-invalid", "b-invalid", "c-invalid", "0.9", "1.0rc1", "1.0"}
+	if !reflect.DeepEqual(versions, expected) {
+		t.Errorf("expected %v, got %v", expected, versions)
+	}
+}
@@ -0,0 +1,389 @@
+package services
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+
+	entity2 "github.com/crawlab-team/crawlab-core/entity"
+	mongo2 "github.com/crawlab-team/crawlab-db/mongo"
+	"github.com/crawlab-team/crawlab-plugin"
+	"github.com/crawlab-team/plugin-dependency/constants"
+	"github.com/crawlab-team/plugin-dependency/entity"
+	"github.com/crawlab-team/plugin-dependency/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const registryTestDbName = "crawlab_plugin_dependency_test"
+
+// newRegistryTestService returns a service whose collections are in the
+// test database of local mongo, which is skipped if not available
+func newRegistryTestService(t *testing.T) (svc *Service) {
+	conn, err := net.DialTimeout("tcp", "localhost:27017", time.Second)
+	if err != nil {
+		t.Skip("mongo is not available: ", err)
+	}
+	_ = conn.Close()
+
+	svc = &Service{
+		Internal: &plugin.Internal{},
+		colS:     mongo2.GetMongoColWithDb(constants.DependencySettingsColName, registryTestDbName),
+		colD:     mongo2.GetMongoColWithDb(constants.DependenciesColName, registryTestDbName),
+	}
+	t.Cleanup(func() {
+		_ = svc.colS.Delete(bson.M{})
+		_ = svc.colD.Delete(bson.M{})
+	})
+	return svc
+}
+
+// performRegistryTestRequest calls the handler with the request, and
+// decodes data of the response
+func performRegistryTestRequest(t *testing.T, handler gin.HandlerFunc, target string, params gin.Params, data interface{}) (res entity2.ListResponse) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+	c.Params = params
+	handler(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("request %s: %d %s", target, w.Code, w.Body.String())
+	}
+	res.Data = data
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestFakeRegistryClient_GetLatestVersion(t *testing.T) {
+	cases := []struct {
+		key      string
+		versions map[string]string
+	}{
+		{
+			key: constants.DependencyTypePython,
+			versions: map[string]string{
+				"requests": "2.28.1",
+				"scrapy":   "2.6.1",
+				"missing":  "",
+			},
+		},
+		{
+			key: constants.DependencyTypeNode,
+			versions: map[string]string{
+				"lodash":      "4.17.21",
+				"@types/node": "18.7.14",
+				"missing":     "",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.key, func(t *testing.T) {
+			baseSvc := &baseService{
+				key:            c.key,
+				registryClient: NewFakeRegistryClient(filepath.Join("testdata", "registry", c.key)),
+			}
+			var svc DependencyService
+			switch c.key {
+			case constants.DependencyTypePython:
+				svc = &PythonService{baseService: baseSvc}
+			case constants.DependencyTypeNode:
+				svc = &NodeService{baseService: baseSvc}
+			}
+			for name, expected := range c.versions {
+				v, err := svc.GetLatestVersion(models.Dependency{Name: name})
+				if err != nil {
+					t.Fatalf("latest version of %s: %v", name, err)
+				}
+				if v != expected {
+					t.Errorf("latest version of %s: expected %q, got %q", name, expected, v)
+				}
+			}
+		})
+	}
+}
+
+func TestFakeRegistryClient_Search(t *testing.T) {
+	client := NewFakeRegistryClient(filepath.Join("testdata", "registry", constants.DependencyTypePython))
+
+	pkgs, total, err := client.Search("Requests", 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(pkgs) != 1 || pkgs[0].Name != "requests" {
+		t.Errorf("unexpected first page: %d %v", total, pkgs)
+	}
+
+	pkgs, total, err = client.Search("requests", 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(pkgs) != 1 || pkgs[0].Name != "requests-html" {
+		t.Errorf("unexpected second page: %d %v", total, pkgs)
+	}
+}
+
+func TestBaseService_GetRepoList(t *testing.T) {
+	parent := newRegistryTestService(t)
+	nodeId := primitive.NewObjectID()
+
+	cases := []struct {
+		svc      DependencyService
+		key      string
+		query    string
+		names    []string
+		versions map[string][]string
+	}{
+		{
+			svc:   NewPythonService(parent),
+			key:   constants.DependencyTypePython,
+			query: "requests",
+			names: []string{"requests", "requests-html"},
+			versions: map[string][]string{
+				"requests": {"2.27.1"},
+			},
+		},
+		{
+			svc:   NewNodeService(parent),
+			key:   constants.DependencyTypeNode,
+			query: "o",
+			names: []string{"@types/node", "lodash"},
+			versions: map[string][]string{
+				"lodash": {"4.17.20"},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.key, func(t *testing.T) {
+			// provider with fake registry
+			p := getTestBaseService(c.svc)
+			p.registryClient = NewFakeRegistryClient(filepath.Join("testdata", "registry", c.key))
+			if _, err := parent.colS.Insert(models.Setting{Id: primitive.NewObjectID(), Key: c.key}); err != nil {
+				t.Fatal(err)
+			}
+
+			// installed dependencies, where those of spiders are excluded
+			for name, versions := range c.versions {
+				for _, v := range versions {
+					if _, err := parent.colD.Insert(models.Dependency{Id: primitive.NewObjectID(), NodeId: nodeId, Type: c.key, Name: name, Version: v}); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if _, err := parent.colD.Insert(models.Dependency{Id: primitive.NewObjectID(), NodeId: nodeId, SpiderId: primitive.NewObjectID(), Type: c.key, Name: name, Version: "0.0.1"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// search
+			var deps []models.Dependency
+			res := performRegistryTestRequest(t, c.svc.GetRepoList, "/?query="+c.query+"&page=1&size=10", nil, &deps)
+			if res.Total != len(c.names) {
+				t.Errorf("expected total %d, got %d", len(c.names), res.Total)
+			}
+			var names []string
+			for _, d := range deps {
+				names = append(names, d.Name)
+				if d.LatestVersion == "" {
+					t.Errorf("empty latest version of %s", d.Name)
+				}
+				expected := c.versions[d.Name]
+				if !reflect.DeepEqual(d.Result.Versions, expected) {
+					t.Errorf("installed versions of %s: expected %v, got %v", d.Name, expected, d.Result.Versions)
+				}
+			}
+			if !reflect.DeepEqual(names, c.names) {
+				t.Errorf("expected %v, got %v", c.names, names)
+			}
+		})
+	}
+}
+
+func TestBaseService_GetPackage(t *testing.T) {
+	parent := newRegistryTestService(t)
+
+	cases := []struct {
+		svc       DependencyService
+		key       string
+		name      string
+		latest    string
+		installed []string
+		sorted    []string
+	}{
+		{
+			svc:       NewPythonService(parent),
+			key:       constants.DependencyTypePython,
+			name:      "requests",
+			latest:    "2.28.1",
+			installed: []string{"2.28.1", "2.9.0", "2.27.1"},
+			sorted:    []string{"2.9.0", "2.27.1", "2.28.1"},
+		},
+		{
+			svc:       NewNodeService(parent),
+			key:       constants.DependencyTypeNode,
+			name:      "@types/node",
+			latest:    "18.7.14",
+			installed: []string{"18.7.13", "8.10.66", "16.11.56"},
+			sorted:    []string{"8.10.66", "16.11.56", "18.7.13"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.key, func(t *testing.T) {
+			// provider with fake registry
+			p := getTestBaseService(c.svc)
+			p.registryClient = NewFakeRegistryClient(filepath.Join("testdata", "registry", c.key))
+			if _, err := parent.colS.Insert(models.Setting{Id: primitive.NewObjectID(), Key: c.key}); err != nil {
+				t.Fatal(err)
+			}
+
+			// installed versions on nodes
+			for _, v := range c.installed {
+				if _, err := parent.colD.Insert(models.Dependency{Id: primitive.NewObjectID(), NodeId: primitive.NewObjectID(), Type: c.key, Name: c.name, Version: v}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// package detail
+			var pkg entity.PackageDetail
+			performRegistryTestRequest(t, p.getPackage, "/", gin.Params{{Key: "name", Value: "/" + c.name}}, &pkg)
+			if pkg.Name != c.name || pkg.LatestVersion != c.latest {
+				t.Errorf("unexpected package: %s %s", pkg.Name, pkg.LatestVersion)
+			}
+			var installed []string
+			for _, iv := range pkg.Installed {
+				installed = append(installed, iv.Version)
+				if len(iv.NodeIds) != 1 {
+					t.Errorf("expected 1 node of %s, got %v", iv.Version, iv.NodeIds)
+				}
+			}
+			if !reflect.DeepEqual(installed, c.sorted) {
+				t.Errorf("expected installed versions %v, got %v", c.sorted, installed)
+			}
+
+			// not found
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			ctx.Params = gin.Params{{Key: "name", Value: "/missing"}}
+			p.getPackage(ctx)
+			if w.Code != http.StatusNotFound {
+				t.Errorf("expected not found, got %d", w.Code)
+			}
+		})
+	}
+}
+
+func getTestBaseService(svc DependencyService) (p *baseService) {
+	switch s := svc.(type) {
+	case *PythonService:
+		return s.baseService
+	case *NodeService:
+		return s.baseService
+	}
+	return nil
+}
+
+func TestBaseService_IsLowerVersion(t *testing.T) {
+	pythonSvc := &PythonService{}
+	pythonSvc.baseService = &baseService{svc: pythonSvc}
+	nodeSvc := &NodeService{}
+	nodeSvc.baseService = &baseService{svc: nodeSvc}
+	javaSvc := &JavaService{}
+	javaSvc.baseService = &baseService{svc: javaSvc}
+
+	cases := []struct {
+		p      *baseService
+		v1, v2 string
+		res    bool
+	}{
+		{pythonSvc.baseService, "2.9.0", "2.28.1", true},
+		{pythonSvc.baseService, "3.0rc1", "3.0", true},
+		{pythonSvc.baseService, "not-a-version", "1.0", true},
+		{pythonSvc.baseService, "1.0", "not-a-version", false},
+		{nodeSvc.baseService, "8.10.66", "16.11.56", true},
+		{nodeSvc.baseService, "19.0.0-beta.1", "19.0.0", true},
+		{nodeSvc.baseService, "18.7.14", "18.7.13", false},
+		{javaSvc.baseService, "2.9", "2.10.1", true},
+		{javaSvc.baseService, "1.0-SNAPSHOT", "1.0", true},
+		{javaSvc.baseService, "1.0.1", "1.0", false},
+	}
+	for _, c := range cases {
+		if res := c.p._isLowerVersion(c.v1, c.v2); res != c.res {
+			t.Errorf("%s lower than %s: expected %t, got %t", c.v1, c.v2, c.res, res)
+		}
+	}
+}
+
+func TestPythonService_GetNamePattern(t *testing.T) {
+	svc := &PythonService{}
+	cases := []struct {
+		name    string
+		matches []string
+		others  []string
+	}{
+		{"Scrapy_Redis", []string{"scrapy-redis", "scrapy_redis", "Scrapy.Redis", "SCRAPY--REDIS"}, []string{"scrapyredis", "scrapy-redis2", "my-scrapy-redis"}},
+		{"django", []string{"Django", "DJANGO"}, []string{"django-cors-headers"}},
+		{"zope.interface", []string{"zope-interface", "Zope_Interface"}, []string{"zopeXinterface"}},
+	}
+	for _, c := range cases {
+		pattern := regexp.MustCompile("(?i)" + svc.GetNamePattern(c.name))
+		for _, name := range c.matches {
+			if !pattern.MatchString(name) {
+				t.Errorf("pattern of %s: expected to match %s", c.name, name)
+			}
+			if svc.NormalizeName(name) != svc.NormalizeName(c.name) {
+				t.Errorf("normalized name of %s: expected %s, got %s", name, svc.NormalizeName(c.name), svc.NormalizeName(name))
+			}
+		}
+		for _, name := range c.others {
+			if pattern.MatchString(name) {
+				t.Errorf("pattern of %s: expected not to match %s", c.name, name)
+			}
+		}
+	}
+}
+
+func TestBaseService_GetDependencyResultsMap(t *testing.T) {
+	parent := newRegistryTestService(t)
+	p := getTestBaseService(NewPythonService(parent))
+
+	// installed dependencies of names as reported by nodes
+	nodeIds := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID()}
+	for _, d := range []models.Dependency{
+		{NodeId: nodeIds[0], Name: "Django", Version: "4.1"},
+		{NodeId: nodeIds[1], Name: "django", Version: "4.0.7"},
+		{NodeId: nodeIds[0], Name: "scrapy-redis", Version: "0.7.2"},
+		{NodeId: nodeIds[0], Name: "scrapy", Version: "2.6.2"},
+	} {
+		d.Id = primitive.NewObjectID()
+		d.Type = constants.DependencyTypePython
+		if _, err := parent.colD.Insert(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// declared names
+	depsResultsMap, err := p._getDependencyResultsMap([]string{"DJANGO", "Scrapy_Redis", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(depsResultsMap) != 2 {
+		t.Errorf("expected 2 results, got %v", depsResultsMap)
+	}
+	dr := depsResultsMap["DJANGO"]
+	sort.Strings(dr.Versions)
+	if !reflect.DeepEqual(dr.Versions, []string{"4.0.7", "4.1"}) || len(dr.NodeIds) != 2 {
+		t.Errorf("unexpected result of DJANGO: %+v", dr)
+	}
+	if dr := depsResultsMap["Scrapy_Redis"]; !reflect.DeepEqual(dr.Versions, []string{"0.7.2"}) {
+		t.Errorf("unexpected result of Scrapy_Redis: %+v", dr)
+	}
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/crawlab-team/crawlab-core/controllers"
+	"github.com/crawlab-team/go-trace"
+	"github.com/crawlab-team/plugin-dependency/constants"
+	"github.com/crawlab-team/plugin-dependency/entity"
+	"github.com/crawlab-team/plugin-dependency/models"
+	"github.com/gin-gonic/gin"
+	"github.com/imroc/req"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type RubyService struct {
+	*baseService
+}
+
+func (svc *RubyService) GetRepoList(c *gin.Context) {
+	// query
+	query := c.Query("query")
+	pagination := controllers.MustGetPagination(c)
+
+	// validate
+	if query == "" {
+		controllers.HandleErrorBadRequest(c, errors.New("empty query"))
+		return
+	}
+
+	// setting
+	if err := svc._getSetting(); err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// request session
+	reqSession := req.New()
+
+	// set timeout
+	reqSession.SetTimeout(15 * time.Second)
+
+	// request url
+	requestUrl := fmt.Sprintf("%s/api/v1/search.json?query=%s&page=%d", svc._getSourceUrl(svc.s.Proxy), url.QueryEscape(query), pagination.Page)
+
+	// perform request
+	res, err := reqSession.Get(requestUrl)
+	if err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// response
+	var results []entity.RubyGemsSearchResult
+	if err := res.ToJSON(&results); err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// empty results
+	if len(results) == 0 {
+		controllers.HandleSuccess(c)
+		return
+	}
+
+	// dependencies
+	var deps []models.Dependency
+	var depNames []string
+	for _, r := range results {
+		d := models.Dependency{
+			Name:          r.Name,
+			LatestVersion: r.Version,
+			Description:   r.Info,
+		}
+		deps = append(deps, d)
+		depNames = append(depNames, d.Name)
+	}
+
+	// total is not provided by rubygems search api, which returns
+	// pages of 30 gems, so it is estimated to allow fetching the next page
+	pageSize := 30
+	total := (pagination.Page-1)*pageSize + len(results)
+	if len(results) >= pageSize {
+		total += pageSize
+	}
+
+	// dependencies in db
+	depsResultsMap, err := svc._getDependencyResultsMap(depNames)
+	if err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// iterate dependencies
+	for i, d := range deps {
+		dr, ok := depsResultsMap[d.Name]
+		if ok {
+			deps[i].Result = dr
+		}
+	}
+
+	controllers.HandleSuccessWithListData(c, deps, total)
+}
+
+func (svc *RubyService) GetDependencies(params entity.UpdateParams) (deps []models.Dependency, err error) {
+	cmd := exec.Command(params.Cmd, "list", "--local")
+	data, err := cmd.Output()
+	if err != nil {
+		return nil, trace.TraceError(err)
+	}
+
+	// e.g. "rake (13.0.6, 12.3.3)" or "bundler (default: 2.3.7)"
+	pattern := regexp.MustCompile(`^(\S+) \((.+)\)$`)
+	for _, line := range strings.Split(string(data), "\n") {
+		matches := pattern.FindStringSubmatch(strings.TrimSpace(line))
+		if len(matches) < 3 {
+			continue
+		}
+		version := strings.TrimSpace(strings.Split(matches[2], ",")[0])
+		version = strings.TrimPrefix(version, "default: ")
+		d := models.Dependency{
+			Name:    matches[1],
+			Version: version,
+		}
+		d.Type = constants.DependencyTypeRuby
+		deps = append(deps, d)
+	}
+	return deps, nil
+}
+
+func (svc *RubyService) InstallDependencies(params entity.InstallParams) (err error) {
+	if params.UseConfig {
+		// workspace path
+		workspacePath, err := svc._getInstallWorkspacePath(params)
+		if err != nil {
+			return err
+		}
+
+		// config path
+		configPath := filepath.Join(workspacePath, constants.DependencyConfigGemfile)
+
+		// bundler is shipped alongside gem
+		cmd := exec.Command(svc._getBundleCmd(params.Cmd), "install", "--gemfile", configPath)
+
+		return svc._runCmd(params.TaskId, cmd)
+	}
+
+	// arguments
+	var args []string
+
+	// install, or update if upgrade
+	if params.Upgrade {
+		args = append(args, "update")
+	} else {
+		args = append(args, "install")
+	}
+
+	// proxy
+	if params.Proxy != "" {
+		args = append(args, "--clear-sources", "--source", params.Proxy)
+	}
+
+	// dependency names
+	for _, depName := range params.Names {
+		args = append(args, depName)
+	}
+
+	// run
+	return svc._runCmd(params.TaskId, exec.Command(params.Cmd, args...))
+}
+
+func (svc *RubyService) UninstallDependencies(params entity.UninstallParams) (err error) {
+	// arguments
+	var args []string
+
+	// uninstall all versions and executables without prompting
+	args = append(args, "uninstall", "-a", "-x", "-I")
+
+	// dependency names
+	for _, depName := range params.Names {
+		args = append(args, depName)
+	}
+
+	// run
+	return svc._runCmd(params.TaskId, exec.Command(params.Cmd, args...))
+}
+
+func (svc *RubyService) GetLatestVersion(dep models.Dependency) (v string, err error) {
+	// request session
+	reqSession := req.New()
+
+	// set timeout
+	reqSession.SetTimeout(60 * time.Second)
+
+	// request url
+	requestUrl := fmt.Sprintf("%s/api/v1/versions/%s/latest.json", svc._getSourceUrl(svc.s.Proxy), url.PathEscape(dep.Name))
+
+	// perform request
+	res, err := reqSession.Get(requestUrl)
+	if err != nil {
+		return "", trace.TraceError(err)
+	}
+	if res.Response().StatusCode != http.StatusOK {
+		return "", trace.TraceError(errors.New(fmt.Sprintf("request %s failed: %s", requestUrl, res.Response().Status)))
+	}
+
+	// response
+	var gemVersion entity.RubyGemsVersion
+	if err := res.ToJSON(&gemVersion); err != nil {
+		return "", trace.TraceError(err)
+	}
+
+	// rubygems returns "unknown" for non-existing gems
+	if gemVersion.Version == "unknown" {
+		return "", nil
+	}
+
+	return gemVersion.Version, nil
+}
+
+// CompareVersions compares gem versions, e.g. "7.0.4.3" and "7.1.0.beta1"
+func (svc *RubyService) CompareVersions(v1, v2 string) (res int, err error) {
+	return compareRubyVersions(v1, v2)
+}
+
+// GetManifestDependencies returns gems declared in Gemfile with their
+// version requirements, e.g. "~> 1.13, >= 1.13.1"
+func (svc *RubyService) GetManifestDependencies(workspacePath, manifest string) (deps []models.Dependency, parseErrors []entity.ManifestError, err error) {
+	// file path
+	filePath := filepath.Join(workspacePath, manifest)
+
+	// file content
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, nil, trace.TraceError(err)
+	}
+
+	// gem declarations, e.g. gem 'nokogiri', '~> 1.13', '>= 1.13.1'
+	pattern := regexp.MustCompile(`^gem\s+['"]([^'"]+)['"]((?:\s*,\s*['"][^'"]*['"])*)`)
+	versionPattern := regexp.MustCompile(`['"]([^'"]*)['"]`)
+
+	// iterate content lines
+	for _, line := range strings.Split(string(data), "\n") {
+		matches := pattern.FindStringSubmatch(strings.TrimSpace(line))
+		if len(matches) < 2 {
+			continue
+		}
+
+		// version requirements
+		var versions []string
+		for _, m := range versionPattern.FindAllStringSubmatch(matches[2], -1) {
+			versions = append(versions, m[1])
+		}
+
+		deps = append(deps, models.Dependency{
+			Name:    matches[1],
+			Version: strings.Join(versions, ", "),
+		})
+	}
+
+	return deps, nil, nil
+}
+
+func (svc *RubyService) _getSourceUrl(proxy string) (sourceUrl string) {
+	if proxy == "" {
+		return constants.RubyGemsDefaultUrl
+	}
+	return strings.TrimSuffix(proxy, "/")
+}
+
+// _getBundleCmd returns the bundle command next to the gem command
+func (svc *RubyService) _getBundleCmd(gemCmd string) (cmd string) {
+	dir := filepath.Dir(gemCmd)
+	if dir == "." {
+		return "bundle"
+	}
+	return filepath.Join(dir, "bundle")
+}
+
+func NewRubyService(parent *Service) (svc *RubyService) {
+	svc = &RubyService{}
+	baseSvc := newBaseService(
+		svc,
+		parent,
+		constants.DependencyTypeRuby,
+		entity.MessageCodes{
+			Update:    constants.MessageCodeRubyUpdate,
+			Save:      constants.MessageCodeRubySave,
+			Install:   constants.MessageCodeRubyInstall,
+			Uninstall: constants.MessageCodeRubyUninstall,
+		},
+		models.Setting{
+			Name:        "Ruby",
+			Description: "settings.description.ruby",
+			Cmd:         "gem",
+			Enabled:     true,
+		},
+	)
+	baseSvc.manifests = []string{constants.DependencyConfigGemfile}
+	svc.baseService = baseSvc
+	return svc
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var rubyVersionPattern = regexp.MustCompile(`^[0-9]+(\.[0-9a-zA-Z]+)*(-[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`)
+var rubyVersionSegmentPattern = regexp.MustCompile(`[0-9]+|[a-zA-Z]+`)
+
+// parseRubyVersion parses gem version into segments of numbers and
+// strings as Gem::Version does, e.g. "1.0.0.pre1" -> [1 0 0 "pre" 1],
+// where "-" is a pre-release, e.g. "1.0.0-rc1" is "1.0.0.pre.rc1"
+func parseRubyVersion(s string) (segments []interface{}, err error) {
+	s = strings.TrimSpace(s)
+	if !rubyVersionPattern.MatchString(s) {
+		return nil, errors.New(fmt.Sprintf("invalid gem version: %s", s))
+	}
+	s = strings.ReplaceAll(s, "-", ".pre.")
+	for _, t := range rubyVersionSegmentPattern.FindAllString(s, -1) {
+		if n, err := strconv.Atoi(t); err == nil {
+			segments = append(segments, n)
+		} else {
+			segments = append(segments, t)
+		}
+	}
+	return segments, nil
+}
+
+// compareRubyVersions compares gem versions, where missing segments are 0
+// and strings sort before numbers as pre-releases, e.g. "7.0.4.3", "2.6"
+// and "7.1.0.beta1"
+func compareRubyVersions(v1, v2 string) (res int, err error) {
+	a, err := parseRubyVersion(v1)
+	if err != nil {
+		return 0, err
+	}
+	b, err := parseRubyVersion(v2)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y interface{} = 0, 0
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		nx, okX := x.(int)
+		ny, okY := y.(int)
+		switch {
+		case okX && okY:
+			res = compareInt(nx, ny)
+		case okX:
+			res = 1
+		case okY:
+			res = -1
+		default:
+			res = strings.Compare(x.(string), y.(string))
+		}
+		if res != 0 {
+			return res, nil
+		}
+	}
+	return 0, nil
+}
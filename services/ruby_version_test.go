@@ -0,0 +1,53 @@
+package services
+
+import "testing"
+
+func TestCompareRubyVersions(t *testing.T) {
+	cases := []struct {
+		a, b string
+		res  int
+	}{
+		{"7.0.4.3", "7.0.4", 1},
+		{"2.6", "2.6.0", 0},
+		{"2.6", "2.10", -1},
+		{"7.1.0.beta1", "7.1.0", -1},
+		{"7.1.0.beta1", "7.1.0.rc1", -1},
+		{"7.1.0.rc1", "7.0.9", 1},
+		{"1.0.0-rc1", "1.0.0", -1},
+		{"1.0.0a", "1.0.0", -1},
+	}
+	for _, c := range cases {
+		res, err := compareRubyVersions(c.a, c.b)
+		if err != nil {
+			t.Fatalf("compare %s with %s: %v", c.a, c.b, err)
+		}
+		if res != c.res {
+			t.Errorf("compare %s with %s: expected %d, got %d", c.a, c.b, c.res, res)
+		}
+	}
+	if _, err := compareRubyVersions("unknown", "1.0"); err == nil {
+		t.Error("expected error of invalid version")
+	}
+}
+
+func TestPhpService_CompareVersions(t *testing.T) {
+	svc := &PhpService{}
+	cases := []struct {
+		a, b string
+		res  int
+	}{
+		{"1.2", "1.2.0", 0},
+		{"v2.0.1", "2.0.0", 1},
+		{"1.2", "1.10", -1},
+		{"3.0.0-beta1", "3.0.0", -1},
+	}
+	for _, c := range cases {
+		res, err := svc.CompareVersions(c.a, c.b)
+		if err != nil {
+			t.Fatalf("compare %s with %s: %v", c.a, c.b, err)
+		}
+		if res != c.res {
+			t.Errorf("compare %s with %s: expected %d, got %d", c.a, c.b, c.res, res)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/blang/semver/v4"
+	"github.com/crawlab-team/crawlab-core/controllers"
+	"github.com/crawlab-team/crawlab-core/utils"
+	"github.com/crawlab-team/go-trace"
+	"github.com/crawlab-team/plugin-dependency/constants"
+	"github.com/crawlab-team/plugin-dependency/entity"
+	"github.com/crawlab-team/plugin-dependency/models"
+	"github.com/gin-gonic/gin"
+	"github.com/imroc/req"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type RustService struct {
+	*baseService
+}
+
+func (svc *RustService) GetRepoList(c *gin.Context) {
+	// query
+	query := strings.TrimSpace(c.Query("query"))
+	pagination := controllers.MustGetPagination(c)
+
+	// validate
+	if query == "" {
+		controllers.HandleErrorBadRequest(c, errors.New("empty query"))
+		return
+	}
+
+	// setting
+	if err := svc._getSetting(); err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// dependencies
+	var deps []models.Dependency
+	var total int
+	if svc.s.Proxy == "" {
+		// search in crates.io
+		searchRes, err := svc._search(query, pagination.Page, pagination.Size)
+		if err != nil {
+			controllers.HandleErrorInternalServerError(c, err)
+			return
+		}
+		for _, cr := range searchRes.Crates {
+			deps = append(deps, models.Dependency{
+				Name:          cr.Name,
+				LatestVersion: cr.MaxVersion,
+				Description:   cr.Description,
+			})
+		}
+		total = searchRes.Meta.Total
+	} else {
+		// sparse index has no search endpoint, so the query is
+		// looked up as a crate name
+		v, err := svc._getIndexLatestVersion(svc.s.Proxy, query)
+		if err != nil {
+			controllers.HandleErrorInternalServerError(c, err)
+			return
+		}
+		if v != "" {
+			deps = append(deps, models.Dependency{
+				Name:          query,
+				LatestVersion: v,
+			})
+			total = 1
+		}
+	}
+
+	// empty results
+	if total == 0 {
+		controllers.HandleSuccess(c)
+		return
+	}
+
+	// dependency names
+	var depNames []string
+	for _, d := range deps {
+		depNames = append(depNames, d.Name)
+	}
+
+	// dependencies in db
+	depsResultsMap, err := svc._getDependencyResultsMap(depNames)
+	if err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// iterate dependencies
+	for i, d := range deps {
+		dr, ok := depsResultsMap[d.Name]
+		if ok {
+			deps[i].Result = dr
+		}
+	}
+
+	controllers.HandleSuccessWithListData(c, deps, total)
+}
+
+func (svc *RustService) GetDependencies(params entity.UpdateParams) (deps []models.Dependency, err error) {
+	// cargo home
+	cargoHome, err := svc._getCargoHome()
+	if err != nil {
+		return nil, err
+	}
+
+	// installed crates
+	installs, err := svc._getInstalls(cargoHome)
+	if err != nil {
+		return nil, err
+	}
+
+	// e.g. "ripgrep 13.0.0 (registry+https://github.com/rust-lang/crates.io-index)"
+	for key, bins := range installs {
+		fields := strings.Fields(key)
+		if len(fields) < 2 {
+			continue
+		}
+		d := models.Dependency{
+			Name:        fields[0],
+			Version:     fields[1],
+			Description: strings.Join(bins, ", "),
+		}
+		d.Type = constants.DependencyTypeRust
+		deps = append(deps, d)
+	}
+
+	return deps, nil
+}
+
+func (svc *RustService) InstallDependencies(params entity.InstallParams) (err error) {
+	// validate
+	if params.UseConfig {
+		return trace.TraceError(errors.New("installing by config is not supported by rust"))
+	}
+
+	// iterate dependency names, as a version can only be
+	// specified for a single crate
+	for _, depName := range params.Names {
+		// arguments
+		var args []string
+		args = append(args, "install")
+
+		// name and version, e.g. "ripgrep@13.0.0"
+		parts := strings.SplitN(depName, "@", 2)
+		args = append(args, parts[0])
+		if len(parts) == 2 && !params.Upgrade {
+			args = append(args, "--version", parts[1])
+		}
+
+		// proxy
+		if params.Proxy != "" {
+			args = append(args, "--index", svc._getSparseIndexUrl(params.Proxy))
+		}
+
+		// run
+		if err := svc._runCmd(params.TaskId, exec.Command(params.Cmd, args...)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (svc *RustService) UninstallDependencies(params entity.UninstallParams) (err error) {
+	// arguments
+	var args []string
+
+	// uninstall
+	args = append(args, "uninstall")
+
+	// dependency names
+	for _, depName := range params.Names {
+		args = append(args, depName)
+	}
+
+	// run
+	return svc._runCmd(params.TaskId, exec.Command(params.Cmd, args...))
+}
+
+func (svc *RustService) GetLatestVersion(dep models.Dependency) (v string, err error) {
+	return svc._getIndexLatestVersion(svc.s.Proxy, dep.Name)
+}
+
+func (svc *RustService) _getCargoHome() (cargoHome string, err error) {
+	if cargoHome = os.Getenv("CARGO_HOME"); cargoHome != "" {
+		return cargoHome, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", trace.TraceError(err)
+	}
+	return filepath.Join(homeDir, ".cargo"), nil
+}
+
+// _getInstalls returns installed crates keyed by package id, read from
+// .crates2.json, or from the legacy .crates.toml if the former is absent
+func (svc *RustService) _getInstalls(cargoHome string) (installs map[string][]string, err error) {
+	installs = map[string][]string{}
+
+	// .crates2.json
+	crates2Path := filepath.Join(cargoHome, ".crates2.json")
+	if utils.Exists(crates2Path) {
+		data, err := ioutil.ReadFile(crates2Path)
+		if err != nil {
+			return nil, trace.TraceError(err)
+		}
+		var crates2 entity.CargoCrates2
+		if err := json.Unmarshal(data, &crates2); err != nil {
+			return nil, trace.TraceError(err)
+		}
+		for key, install := range crates2.Installs {
+			installs[key] = install.Bins
+		}
+		return installs, nil
+	}
+
+	// .crates.toml
+	cratesPath := filepath.Join(cargoHome, ".crates.toml")
+	if !utils.Exists(cratesPath) {
+		return installs, nil
+	}
+	data, err := ioutil.ReadFile(cratesPath)
+	if err != nil {
+		return nil, trace.TraceError(err)
+	}
+
+	// e.g. "ripgrep 13.0.0 (registry+https://github.com/rust-lang/crates.io-index)" = ["rg"]
+	pattern := regexp.MustCompile(`^"([^"]+)"\s*=\s*\[(.*)\]`)
+	binPattern := regexp.MustCompile(`"([^"]+)"`)
+	for _, line := range strings.Split(string(data), "\n") {
+		matches := pattern.FindStringSubmatch(strings.TrimSpace(line))
+		if len(matches) < 3 {
+			continue
+		}
+		var bins []string
+		for _, m := range binPattern.FindAllStringSubmatch(matches[2], -1) {
+			bins = append(bins, m[1])
+		}
+		installs[matches[1]] = bins
+	}
+	return installs, nil
+}
+
+// _getSparseIndexUrl returns the index url with "sparse+" protocol
+// prefix as accepted by "cargo install --index"
+func (svc *RustService) _getSparseIndexUrl(indexUrl string) (res string) {
+	if strings.HasPrefix(indexUrl, "sparse+") {
+		return indexUrl
+	}
+	return "sparse+" + strings.TrimSuffix(indexUrl, "/") + "/"
+}
+
+// _getIndexPath returns the path of crate in sparse index, e.g.
+// "1/a", "2/ab", "3/a/abc" and "ri/pg/ripgrep"
+func (svc *RustService) _getIndexPath(name string) (p string) {
+	name = strings.ToLower(name)
+	switch len(name) {
+	case 1:
+		return "1/" + name
+	case 2:
+		return "2/" + name
+	case 3:
+		return "3/" + name[:1] + "/" + name
+	default:
+		return name[:2] + "/" + name[2:4] + "/" + name
+	}
+}
+
+func (svc *RustService) _getIndexLatestVersion(indexUrl, name string) (v string, err error) {
+	// index url
+	if indexUrl == "" {
+		indexUrl = constants.CratesIndexDefaultUrl
+	}
+	indexUrl = strings.TrimSuffix(strings.TrimPrefix(indexUrl, "sparse+"), "/")
+
+	// request session
+	reqSession := req.New()
+
+	// set timeout
+	reqSession.SetTimeout(60 * time.Second)
+
+	// request url
+	requestUrl := fmt.Sprintf("%s/%s", indexUrl, svc._getIndexPath(name))
+
+	// perform request
+	res, err := reqSession.Get(requestUrl)
+	if err != nil {
+		return "", trace.TraceError(err)
+	}
+	if res.Response().StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if res.Response().StatusCode != http.StatusOK {
+		return "", trace.TraceError(errors.New(fmt.Sprintf("request %s failed: %s", requestUrl, res.Response().Status)))
+	}
+
+	// response bytes
+	data, err := res.ToBytes()
+	if err != nil {
+		return "", trace.TraceError(err)
+	}
+
+	// index file has one json entry per published version
+	var latest semver.Version
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var entry entity.CargoIndexEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if entry.Yanked {
+			continue
+		}
+		ev, err := semver.Parse(entry.Vers)
+		if err != nil || len(ev.Pre) > 0 {
+			continue
+		}
+		if v == "" || ev.GT(latest) {
+			latest = ev
+			v = entry.Vers
+		}
+	}
+
+	return v, nil
+}
+
+func (svc *RustService) _search(query string, page, size int) (searchRes entity.CratesSearchResponse, err error) {
+	// request session
+	reqSession := req.New()
+
+	// set timeout
+	reqSession.SetTimeout(15 * time.Second)
+
+	// crates.io requires a user agent
+	ua := req.Header{"user-agent": "crawlab-plugin-dependency"}
+
+	// request url
+	requestUrl := fmt.Sprintf("%s/crates?q=%s&page=%d&per_page=%d", constants.CratesApiUrl, url.QueryEscape(query), page, size)
+
+	// perform request
+	res, err := reqSession.Get(requestUrl, ua)
+	if err != nil {
+		return searchRes, trace.TraceError(err)
+	}
+
+	// response
+	if err := res.ToJSON(&searchRes); err != nil {
+		return searchRes, trace.TraceError(err)
+	}
+
+	return searchRes, nil
+}
+
+func NewRustService(parent *Service) (svc *RustService) {
+	svc = &RustService{}
+	baseSvc := newBaseService(
+		svc,
+		parent,
+		constants.DependencyTypeRust,
+		entity.MessageCodes{
+			Update:    constants.MessageCodeRustUpdate,
+			Save:      constants.MessageCodeRustSave,
+			Install:   constants.MessageCodeRustInstall,
+			Uninstall: constants.MessageCodeRustUninstall,
+		},
+		models.Setting{
+			Name:        "Rust",
+			Description: "settings.description.rust",
+			Cmd:         "cargo",
+			Enabled:     true,
+		},
+	)
+	svc.baseService = baseSvc
+	return svc
+}
@@ -5,7 +5,9 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/cenkalti/backoff/v4"
+	constants2 "github.com/crawlab-team/crawlab-core/constants"
 	"github.com/crawlab-team/crawlab-core/interfaces"
 	models2 "github.com/crawlab-team/crawlab-core/models/models"
 	"github.com/crawlab-team/crawlab-core/node/config"
@@ -36,31 +38,47 @@ type Service struct {
 	colD        *mongo2.Col // dependencies
 	colT        *mongo2.Col // dependency tasks
 	colL        *mongo2.Col // dependency logs
+	colO        *mongo2.Col // dependency operations
 	cfgSvc      interfaces.NodeConfigService
 	currentNode interfaces.Node
 	masterNode  interfaces.Node
 	msgStream   grpc.MessageService_ConnectClient
+	procMap     sync.Map // task processes running on current node
+	cancelMap   sync.Map // ids of tasks cancelled on current node, with time
 
 	// sub services
 	settingSvc *SettingService
 	taskSvc    *TaskService
-	pythonSvc  *PythonService
-	nodeSvc    *NodeService
+	opSvc      *OperationService
 	spiderSvc  *SpiderService
+	customSvc  *CustomProviderService
+
+	// dependency providers
+	registry *providerRegistry
 }
 
 func (svc *Service) Init() (err error) {
 	// initialize sub services
 	svc.settingSvc.Init()
 	svc.taskSvc.Init()
-	svc.pythonSvc.Init()
-	svc.nodeSvc.Init()
+	svc.opSvc.Init()
 	svc.spiderSvc.Init()
+	svc.customSvc.Init()
+
+	// initialize dependency providers
+	for _, p := range svc.registry.list() {
+		p.svc.Init()
+	}
 
 	return nil
 }
 
 func (svc *Service) Start() (err error) {
+	// load custom dependency providers
+	if err := svc.customSvc.load(); err != nil {
+		trace.PrintError(err)
+	}
+
 	if svc.cfgSvc.IsMaster() {
 		// initialize data
 		if err := svc.initData(); err != nil {
@@ -75,8 +93,10 @@ func (svc *Service) Start() (err error) {
 		// start api
 		go svc.StartApi()
 
-		// start python service
-		go svc.pythonSvc.Start()
+		// start dependency providers
+		for _, p := range svc.registry.list() {
+			go p.svc.Start()
+		}
 	}
 
 	// get current node
@@ -104,40 +124,23 @@ func (svc *Service) Stop() (err error) {
 }
 
 func (svc *Service) initData() (err error) {
-	total, err := svc.colS.Count(nil)
-	if err != nil {
-		return err
-	}
-	if total > 0 {
-		return nil
-	}
+	// iterate dependency providers
+	for _, p := range svc.registry.list() {
+		// skip if setting exists
+		total, err := svc.colS.Count(bson.M{"key": p.svc.GetKey()})
+		if err != nil {
+			return err
+		}
+		if total > 0 {
+			continue
+		}
 
-	// data to initialize
-	settings := []models.Setting{
-		{
-			Id:          primitive.NewObjectID(),
-			Key:         constants.DependencyTypePython,
-			Name:        "Python",
-			Description: "settings.description.python",
-			Cmd:         "pip",
-			Enabled:     true,
-		},
-		{
-			Id:          primitive.NewObjectID(),
-			Key:         constants.DependencyTypeNode,
-			Name:        "Node.js",
-			Cmd:         "npm",
-			Description: "settings.description.node",
-			Enabled:     true,
-		},
-	}
-	var data []interface{}
-	for _, s := range settings {
-		data = append(data, s)
-	}
-	_, err = svc.colS.InsertMany(data)
-	if err != nil {
-		return err
+		// default setting
+		s := p.svc.GetDefaultSetting()
+		s.Id = primitive.NewObjectID()
+		if _, err := svc.colS.Insert(s); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -169,6 +172,18 @@ func (svc *Service) initIndexes() (err error) {
 		},
 	})
 
+	// operations
+	optsColO := &options.IndexOptions{}
+	optsColO.SetExpireAfterSeconds(60 * 60 * 24)
+	_ = svc.colO.CreateIndexes([]mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{"update_ts", 1},
+			},
+			Options: optsColO,
+		},
+	})
+
 	// logs
 	optsColL := &options.IndexOptions{}
 	optsColL.SetExpireAfterSeconds(60 * 60 * 24)
@@ -216,24 +231,23 @@ func (svc *Service) handleStreamMessages() {
 			go svc.updateTask(msg, msgData)
 		case constants.MessageCodeInsertLogs:
 			go svc.insertLogs(msg, msgData)
-
-		case constants.MessageCodePythonUpdate:
-			go svc.pythonSvc.updateDependencyList(msg, msgData)
-		case constants.MessageCodePythonSave:
-			go svc.pythonSvc._saveDependencyList(msg, msgData)
-		case constants.MessageCodePythonInstall:
-			go svc.pythonSvc.installDependency(msg, msgData)
-		case constants.MessageCodePythonUninstall:
-			go svc.pythonSvc.uninstallDependency(msg, msgData)
-
-		case constants.MessageCodeNodeUpdate:
-			go svc.nodeSvc.updateDependencyList(msg, msgData)
-		case constants.MessageCodeNodeSave:
-			go svc.nodeSvc._saveDependencyList(msg, msgData)
-		case constants.MessageCodeNodeInstall:
-			go svc.nodeSvc.installDependency(msg, msgData)
-		case constants.MessageCodeNodeUninstall:
-			go svc.nodeSvc.uninstallDependency(msg, msgData)
+		case constants.MessageCodeCancelTask:
+			go svc.cancelTask(msg, msgData)
+		case constants.MessageCodeInsertTaskAttempt:
+			go svc.insertTaskAttempt(msg, msgData)
+		default:
+			// dependency provider message
+			h, ok := svc.registry.getHandler(msgData.Code)
+			if !ok && svc.customSvc.isCustomMessageCode(msgData.Code) {
+				// custom provider may be added after this node started
+				if err := svc.customSvc.load(); err != nil {
+					trace.PrintError(err)
+				}
+				h, ok = svc.registry.getHandler(msgData.Code)
+			}
+			if ok {
+				go h(msg, msgData)
+			}
 		}
 	}
 }
@@ -313,6 +327,20 @@ func (svc *Service) updateTask(msg *grpc.StreamMessage, msgData entity.MessageDa
 		trace.PrintError(err)
 		return
 	}
+
+	// status of operation owning the task
+	var t models.Task
+	if err := svc.colT.FindId(taskMsg.TaskId).One(&t); err != nil {
+		trace.PrintError(err)
+		return
+	}
+	if t.OperationId.IsZero() {
+		return
+	}
+	if err := svc.opSvc.updateStatus(t.OperationId); err != nil {
+		trace.PrintError(err)
+		return
+	}
 }
 
 func (svc *Service) insertLogs(msg *grpc.StreamMessage, msgData entity.MessageData) {
@@ -333,43 +361,85 @@ func (svc *Service) insertLogs(msg *grpc.StreamMessage, msgData entity.MessageDa
 	}
 }
 
-func (svc *Service) _configureLogging(taskId primitive.ObjectID, cmd *exec.Cmd) {
+// insertTaskAttempt appends the attempt reported by the node to the task
+func (svc *Service) insertTaskAttempt(msg *grpc.StreamMessage, msgData entity.MessageData) {
+	var attemptMsg entity.TaskAttemptMessage
+	if err := json.Unmarshal(msgData.Data, &attemptMsg); err != nil {
+		trace.PrintError(err)
+		return
+	}
+	update := bson.M{
+		"$push": bson.M{
+			"attempts": attemptMsg.Attempt,
+		},
+	}
+	if err := svc.colT.UpdateId(attemptMsg.TaskId, update); err != nil {
+		trace.PrintError(err)
+		return
+	}
+}
+
+// cancelTask kills the command of the task running on current node, or
+// reports the task as cancelled if it is not running, e.g. after restart.
+// The task id is recorded first, so that the task is not run if it has not
+// arrived yet
+func (svc *Service) cancelTask(msg *grpc.StreamMessage, msgData entity.MessageData) {
+	var taskMsg entity.TaskMessage
+	if err := json.Unmarshal(msgData.Data, &taskMsg); err != nil {
+		trace.PrintError(err)
+		return
+	}
+	svc._markTaskCancelled(taskMsg.TaskId)
+	if !svc._killTaskProcess(taskMsg.TaskId) {
+		svc._sendTaskStatus(taskMsg.TaskId, constants2.TaskStatusCancelled, nil)
+	}
+}
+
+// _configureLogging streams output of the command to the task logs, and
+// returns the wait group which is done when all output has been sent
+func (svc *Service) _configureLogging(taskId primitive.ObjectID, cmd *exec.Cmd) (wg *sync.WaitGroup) {
 	var logLines []string
+	var mu sync.Mutex
 	stdout, _ := cmd.StdoutPipe()
 	stderr, _ := cmd.StderrPipe()
-	wg := sync.WaitGroup{}
-	wg.Add(2)
-	go func() {
-		scannerStdout := bufio.NewScanner(stdout)
-		for scannerStdout.Scan() {
-			line := scannerStdout.Text()
+	proc, ok := svc._getTaskProcess(taskId)
+	scan := func(r io.Reader) {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if ok {
+				proc.matchLine(line)
+			}
+			mu.Lock()
 			logLines = append(logLines, line)
 			if len(logLines)%10 == 0 && len(logLines) > 0 {
 				svc._sendLogs(taskId, logLines)
 				logLines = []string{}
 			}
+			mu.Unlock()
 		}
-		wg.Done()
+	}
+	scanWg := sync.WaitGroup{}
+	scanWg.Add(2)
+	go func() {
+		scan(stdout)
+		scanWg.Done()
 	}()
 	go func() {
-		scannerStderr := bufio.NewScanner(stderr)
-		for scannerStderr.Scan() {
-			line := scannerStderr.Text()
-			logLines = append(logLines, line)
-			if len(logLines)%10 == 0 && len(logLines) > 0 {
-				svc._sendLogs(taskId, logLines)
-				logLines = []string{}
-			}
-		}
-		wg.Done()
+		scan(stderr)
+		scanWg.Done()
 	}()
+	wg = &sync.WaitGroup{}
+	wg.Add(1)
 	go func() {
-		wg.Wait()
+		scanWg.Wait()
 		if len(logLines) > 0 {
 			svc._sendLogs(taskId, logLines)
 			logLines = []string{}
 		}
+		wg.Done()
 	}()
+	return wg
 }
 
 func (svc *Service) _sendLogs(taskId primitive.ObjectID, lines []string) {
@@ -443,6 +513,39 @@ func (svc *Service) _sendTaskStatus(taskId primitive.ObjectID, status string, er
 	}
 }
 
+func (svc *Service) _sendTaskAttempt(taskId primitive.ObjectID, attempt entity.TaskAttempt) {
+	// attempt message
+	attemptMsg := &entity.TaskAttemptMessage{
+		TaskId:  taskId,
+		Attempt: attempt,
+	}
+
+	// data
+	data, _ := json.Marshal(attemptMsg)
+
+	// message data
+	msgDataObj := &entity.MessageData{
+		Code: constants.MessageCodeInsertTaskAttempt,
+		Data: data,
+	}
+	msgData, _ := json.Marshal(msgDataObj)
+
+	// stream message
+	msg := &grpc.StreamMessage{
+		Code:    grpc.StreamMessageCode_SEND,
+		NodeKey: svc.currentNode.GetKey(),
+		From:    "plugin:" + svc.currentNode.GetKey(),
+		To:      "plugin:" + svc.masterNode.GetKey(),
+		Data:    msgData,
+	}
+
+	// send message
+	if err := svc.msgStream.Send(msg); err != nil {
+		trace.PrintError(err)
+		return
+	}
+}
+
 func (svc *Service) _getNodes(query bson.M) (nodes []models2.Node, err error) {
 	// node model service
 	nodeModelSvc, err := svc.GetModelService().NewBaseServiceDelegate(interfaces.ModelIdNode)
@@ -487,7 +590,14 @@ func (svc *Service) _updateDependencyList(
 	}
 
 	// data
-	data, err := json.Marshal(deps)
+	depsData, err := json.Marshal(deps)
+	if err != nil {
+		trace.PrintError(err)
+		return
+	}
+	data, err := json.Marshal(entity.SaveParams{
+		Dependencies: depsData,
+	})
 	if err != nil {
 		trace.PrintError(err)
 		return
@@ -520,6 +630,29 @@ func (svc *Service) _updateDependencyList(
 	}
 }
 
+func (svc *Service) registerProvider(p *baseService) (err error) {
+	return svc.registry.register(p)
+}
+
+func (svc *Service) getProvider(key string) (p *baseService, err error) {
+	p, ok := svc.registry.get(key)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("invalid dependency type: %s", key))
+	}
+	return p, nil
+}
+
+// SetRegistryClient injects the client of package registry into the
+// dependency provider, e.g. a FakeRegistryClient to run without network
+func (svc *Service) SetRegistryClient(key string, client RegistryClient) (err error) {
+	p, err := svc.getProvider(key)
+	if err != nil {
+		return err
+	}
+	p.registryClient = client
+	return nil
+}
+
 func NewService() *Service {
 	// service
 	svc := &Service{
@@ -528,6 +661,7 @@ func NewService() *Service {
 		colD:     mongo2.GetMongoCol(constants.DependenciesColName),
 		colT:     mongo2.GetMongoCol(constants.DependencyTasksColName),
 		colL:     mongo2.GetMongoCol(constants.DependencyLogsColName),
+		colO:     mongo2.GetMongoCol(constants.DependencyOperationsColName),
 	}
 
 	// dependency injection
@@ -546,9 +680,39 @@ func NewService() *Service {
 	// sub services
 	svc.settingSvc = NewSettingService(svc)
 	svc.taskSvc = NewTaskService(svc)
-	svc.pythonSvc = NewPythonService(svc)
-	svc.nodeSvc = NewNodeService(svc)
+	svc.opSvc = NewOperationService(svc)
 	svc.spiderSvc = NewSpiderService(svc)
+	svc.customSvc = NewCustomProviderService(svc)
+
+	// dependency providers
+	svc.registry = newProviderRegistry()
+	if err := svc.registerProvider(NewPythonService(svc).baseService); err != nil {
+		panic(err)
+	}
+	if err := svc.registerProvider(NewNodeService(svc).baseService); err != nil {
+		panic(err)
+	}
+	if err := svc.registerProvider(NewGoService(svc).baseService); err != nil {
+		panic(err)
+	}
+	if err := svc.registerProvider(NewJavaService(svc).baseService); err != nil {
+		panic(err)
+	}
+	if err := svc.registerProvider(NewSystemService(svc).baseService); err != nil {
+		panic(err)
+	}
+	if err := svc.registerProvider(NewCondaService(svc).baseService); err != nil {
+		panic(err)
+	}
+	if err := svc.registerProvider(NewRubyService(svc).baseService); err != nil {
+		panic(err)
+	}
+	if err := svc.registerProvider(NewPhpService(svc).baseService); err != nil {
+		panic(err)
+	}
+	if err := svc.registerProvider(NewRustService(svc).baseService); err != nil {
+		panic(err)
+	}
 
 	// initialize
 	if err := svc.Init(); err != nil {
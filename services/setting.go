@@ -1,6 +1,7 @@
 package services
 
 import (
+	"errors"
 	"github.com/crawlab-team/crawlab-core/controllers"
 	mongo2 "github.com/crawlab-team/crawlab-db/mongo"
 	"github.com/crawlab-team/plugin-dependency/constants"
@@ -80,12 +81,31 @@ func (svc *SettingService) putSetting(c *gin.Context) {
 		return
 	}
 
+	// custom provider
+	var p *baseService
+	if s.Provider != nil {
+		var err error
+		p, err = svc.parent.customSvc.register(s)
+		if err != nil {
+			controllers.HandleErrorBadRequest(c, err)
+			return
+		}
+	}
+
 	s.Id = primitive.NewObjectID()
 	if _, err := svc.col.Insert(s); err != nil {
+		if p != nil {
+			svc.parent.customSvc.unregister(s.Key)
+		}
 		controllers.HandleErrorInternalServerError(c, err)
 		return
 	}
 
+	// start custom provider
+	if p != nil {
+		go p.svc.Start()
+	}
+
 	controllers.HandleSuccessWithData(c, s)
 }
 
@@ -102,17 +122,51 @@ func (svc *SettingService) postSetting(c *gin.Context) {
 		return
 	}
 
+	// stored key and whether it is a custom provider
+	key := s.Key
+	isCustom := s.Provider != nil
+
 	if err := c.ShouldBindJSON(&s); err != nil {
 		controllers.HandleErrorInternalServerError(c, err)
 		return
 	}
 	s.Id = id
 
+	// validate custom provider, whose key cannot be changed even if
+	// the provider definition is removed
+	if (isCustom || s.Provider != nil) && s.Key != key {
+		controllers.HandleErrorBadRequest(c, errors.New("key of custom provider cannot be changed"))
+		return
+	}
+	if s.Provider != nil {
+		if err := validateProviderDefinition(s); err != nil {
+			controllers.HandleErrorBadRequest(c, err)
+			return
+		}
+	}
+
 	if err := svc.col.ReplaceId(id, s); err != nil {
 		controllers.HandleErrorInternalServerError(c, err)
 		return
 	}
 
+	// unregister custom provider if its definition is removed
+	if isCustom && s.Provider == nil {
+		svc.parent.customSvc.unregister(key)
+	}
+
+	// register custom provider if not yet registered
+	if s.Provider != nil {
+		if _, ok := svc.parent.registry.get(s.Key); !ok {
+			p, err := svc.parent.customSvc.register(s)
+			if err != nil {
+				controllers.HandleErrorInternalServerError(c, err)
+				return
+			}
+			go p.svc.Start()
+		}
+	}
+
 	controllers.HandleSuccessWithData(c, s)
 }
 
@@ -123,11 +177,22 @@ func (svc *SettingService) deleteSetting(c *gin.Context) {
 		return
 	}
 
+	var s models.Setting
+	if err := svc.col.FindId(id).One(&s); err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
 	if err := svc.col.DeleteId(id); err != nil {
 		controllers.HandleErrorInternalServerError(c, err)
 		return
 	}
 
+	// unregister custom provider
+	if s.Provider != nil {
+		svc.parent.customSvc.unregister(s.Key)
+	}
+
 	controllers.HandleSuccess(c)
 }
 
@@ -7,18 +7,14 @@ import (
 	"github.com/crawlab-team/crawlab-core/controllers"
 	"github.com/crawlab-team/crawlab-core/spider/fs"
 	"github.com/crawlab-team/crawlab-core/utils"
-	"github.com/crawlab-team/go-trace"
 	"github.com/crawlab-team/plugin-dependency/constants"
 	"github.com/crawlab-team/plugin-dependency/entity"
 	"github.com/crawlab-team/plugin-dependency/models"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	mongo2 "go.mongodb.org/mongo-driver/mongo"
-	"io/ioutil"
 	"path"
-	"regexp"
-	"strings"
+	"sync"
 )
 
 type SpiderService struct {
@@ -59,16 +55,23 @@ func (svc *SpiderService) install(c *gin.Context) {
 	// dependency type
 	dependencyType := svc._getDependencyType(workspacePath)
 
-	// install
-	switch dependencyType {
-	case constants.DependencyConfigRequirementsTxt:
-		svc.parent.pythonSvc.install(c)
-	case constants.DependencyConfigPackageJson:
-		svc.parent.nodeSvc.install(c)
-	default:
-		controllers.HandleErrorInternalServerError(c, errors.New(fmt.Sprintf("invalid dependency type: %s", dependencyType)))
+	// dependency provider
+	p, err := svc._getProvider(dependencyType)
+	if err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// payload
+	var payload entity.InstallPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
 		return
 	}
+	payload.SpiderId = id
+
+	// install
+	p._install(c, payload)
 }
 
 func (svc *SpiderService) uninstall(c *gin.Context) {
@@ -98,16 +101,23 @@ func (svc *SpiderService) uninstall(c *gin.Context) {
 	// dependency type
 	dependencyType := svc._getDependencyType(workspacePath)
 
-	// uninstall
-	switch dependencyType {
-	case constants.DependencyConfigRequirementsTxt:
-		svc.parent.pythonSvc.uninstall(c)
-	case constants.DependencyConfigPackageJson:
-		svc.parent.nodeSvc.uninstall(c)
-	default:
-		controllers.HandleErrorInternalServerError(c, errors.New(fmt.Sprintf("invalid dependency type: %s", dependencyType)))
+	// dependency provider
+	p, err := svc._getProvider(dependencyType)
+	if err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// payload
+	var payload entity.UninstallPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
 		return
 	}
+	payload.SpiderId = id
+
+	// uninstall
+	p._uninstall(c, payload)
 }
 
 func (svc *SpiderService) get(c *gin.Context) {
@@ -141,153 +151,260 @@ func (svc *SpiderService) get(c *gin.Context) {
 	info := bson.M{}
 	info["dependency_type"] = dependencyType
 
-	// dependencies
-	var dependencies []models.Dependency
-	switch dependencyType {
-	case constants.DependencyConfigRequirementsTxt:
-		dependencies, err = svc._getDependenciesRequirementsTxt(workspacePath)
-	case constants.DependencyConfigPackageJson:
-		// TODO: implement
-	}
+	// isolated environment of spider
+	envSpiderId, envPath, err := svc._getEnv(dependencyType, id)
 	if err != nil {
 		controllers.HandleErrorInternalServerError(c, err)
 		return
 	}
+	if envPath != "" {
+		info["env_path"] = envPath
+	}
+
+	// dependencies
+	var dependencies []models.Dependency
+	if dependencyType != "" {
+		var parseErrors []entity.ManifestError
+		dependencies, parseErrors, err = svc._getDependencies(workspacePath, dependencyType, id, envSpiderId)
+		if err != nil {
+			controllers.HandleErrorInternalServerError(c, err)
+			return
+		}
+		if len(parseErrors) > 0 {
+			info["errors"] = parseErrors
+		}
+	}
 	info["dependencies"] = dependencies
 
 	controllers.HandleSuccessWithData(c, info)
 }
 
+// _getDependencyType returns the manifest file of spider dependencies in
+// workspace, which is detected from manifests of registered providers
+// that are able to parse them
 func (svc *SpiderService) _getDependencyType(workspacePath string) (t string) {
-	if utils.Exists(path.Join(workspacePath, constants.DependencyConfigRequirementsTxt)) {
-		return constants.DependencyConfigRequirementsTxt
-	} else if utils.Exists(path.Join(workspacePath, constants.DependencyConfigPackageJson)) {
-		return constants.DependencyConfigPackageJson
+	_, manifest, ok := svc.parent.registry.getByManifest(func(p *baseService, manifest string) bool {
+		if _, ok := p.svc.(ManifestDependencyService); !ok {
+			return false
+		}
+		return utils.Exists(path.Join(workspacePath, manifest))
+	})
+	if !ok {
+		return ""
 	}
-	return ""
+	return manifest
 }
 
-func (svc *SpiderService) _getDependenciesRequirementsTxt(workspacePath string) (deps []models.Dependency, err error) {
-	// file path
-	filePath := path.Join(workspacePath, constants.DependencyConfigRequirementsTxt)
+func (svc *SpiderService) _getProvider(dependencyType string) (p *baseService, err error) {
+	p, _, ok := svc.parent.registry.getByManifest(func(p *baseService, manifest string) bool {
+		return manifest == dependencyType
+	})
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("invalid dependency type: %s", dependencyType))
+	}
+	return p, nil
+}
 
-	// file content
-	data, err := ioutil.ReadFile(filePath)
+// _getEnv returns spider id and path of the isolated environment of spider
+// if the dependency provider is isolated, or empty values otherwise
+func (svc *SpiderService) _getEnv(dependencyType string, id primitive.ObjectID) (envSpiderId primitive.ObjectID, envPath string, err error) {
+	// dependency provider
+	p, err := svc._getProvider(dependencyType)
 	if err != nil {
-		return nil, trace.TraceError(err)
+		return primitive.NilObjectID, "", nil
+	}
+
+	// setting
+	if err := p._getSetting(); err != nil {
+		return primitive.NilObjectID, "", err
+	}
+	if !p._isIsolated(id) {
+		return primitive.NilObjectID, "", nil
 	}
-	content := string(data)
 
-	// regex pattern
-	pattern, err := regexp.Compile("(\\w+)([=<>]=.*)?")
+	// env path
+	envPath, err = p.svc.(IsolatedDependencyService).GetEnvPath(p.s.EnvPath, id)
 	if err != nil {
-		return nil, trace.TraceError(err)
+		return primitive.NilObjectID, "", err
 	}
 
-	// dependency names
-	var depNames []string
+	return id, envPath, nil
+}
+
+// _getDependencies returns dependencies declared in the manifest file of
+// spider workspace, which is parsed by its dependency provider, with the
+// installed results of the provider
+func (svc *SpiderService) _getDependencies(workspacePath, dependencyType string, spiderId, envSpiderId primitive.ObjectID) (deps []models.Dependency, parseErrors []entity.ManifestError, err error) {
+	// dependency provider
+	p, err := svc._getProvider(dependencyType)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifestSvc, ok := p.svc.(ManifestDependencyService)
+	if !ok {
+		return nil, nil, errors.New(fmt.Sprintf("manifest is not supported by provider: %s", p.key))
+	}
+
+	// declared dependencies
+	deps, parseErrors, err = manifestSvc.GetManifestDependencies(workspacePath, dependencyType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// dependencies with results, which are compared with the required
+	// versions by python and node providers
+	switch p.key {
+	case constants.DependencyTypePython:
+		deps, err = svc._getPythonDependenciesWithResults(envSpiderId, deps)
+	case constants.DependencyTypeNode:
+		deps, err = svc._getNodeDependenciesWithResults(spiderId, deps)
+	default:
+		deps, err = svc._getDependenciesWithResults(p.key, envSpiderId, deps)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
 
-	// iterate content lines
-	for _, line := range strings.Split(content, "\n") {
-		// trim space
-		line = strings.TrimSpace(line)
+	return deps, parseErrors, nil
+}
+
+// _getPythonDependenciesWithResults attaches installed results of python
+// to the dependencies, and compares installed versions with the required
+// PEP 440 specifiers, which are skipped if invalid, e.g. "^2.26" of poetry
+func (svc *SpiderService) _getPythonDependenciesWithResults(envSpiderId primitive.ObjectID, deps []models.Dependency) (res []models.Dependency, err error) {
+	// dependencies with results
+	deps, err = svc._getDependenciesWithResults(constants.DependencyTypePython, envSpiderId, deps)
+	if err != nil {
+		return nil, err
+	}
 
-		// validate regex match result
-		if !pattern.MatchString(line) {
-			return nil, trace.TraceError(errors.New(fmt.Sprintf("invalid %s", constants.DependencyConfigRequirementsTxt)))
+	// iterate dependencies
+	for i, d := range deps {
+		// required specifiers
+		specs, err := parsePythonSpecifierSet(d.Version)
+		if err != nil {
+			continue
 		}
 
-		// sub matches
-		matches := pattern.FindStringSubmatch(line)
-		if len(matches) < 2 {
-			return nil, trace.TraceError(errors.New(fmt.Sprintf("invalid %s", constants.DependencyConfigRequirementsTxt)))
+		// exact version
+		if len(specs) == 1 && specs[0].op == "==" && !specs[0].wildcard {
+			deps[i].Version = specs[0].version
 		}
 
-		// dependency result
-		d := models.Dependency{
-			Name: matches[1],
+		// skip if not installed
+		if len(d.Result.Versions) == 0 {
+			continue
 		}
 
-		// required version
-		if len(matches) > 2 {
-			d.Version = matches[2]
+		// iterate installed versions, where installed pre-releases
+		// are allowed to satisfy the specifiers
+		for _, v := range d.Result.Versions {
+			ok, err := specs.contains(v, true)
+			if err != nil || ok {
+				continue
+			}
+			if specs.isLower(v) {
+				deps[i].Result.Upgradable = true
+			} else {
+				deps[i].Result.Downgradable = true
+			}
 		}
+	}
 
-		// add to dependency names
-		depNames = append(depNames, d.Name)
+	return deps, nil
+}
+
+// _getNodeDependenciesWithResults attaches installed results of node to
+// the dependencies, which are installed in node_modules of spider workspace
+// or globally, and compares them with the required semver ranges
+func (svc *SpiderService) _getNodeDependenciesWithResults(spiderId primitive.ObjectID, deps []models.Dependency) (res []models.Dependency, err error) {
+	// dependency provider
+	p, err := svc.parent.getProvider(constants.DependencyTypeNode)
+	if err != nil {
+		return nil, err
+	}
 
-		// add to dependencies
-		deps = append(deps, d)
+	// dependency names
+	var depNames []string
+	for _, d := range deps {
+		depNames = append(depNames, d.Name)
 	}
 
-	// dependencies in db
-	var depsResults []entity.DependencyResult
-	pipelines := mongo2.Pipeline{
-		{{
-			"$match",
-			bson.M{
-				"type": constants.DependencyTypePython,
-				"name": bson.M{
-					"$in": depNames,
-				},
-			},
-		}},
-		{{
-			"$group",
-			bson.M{
-				"_id": "$name",
-				"node_ids": bson.M{
-					"$push": "$node_id",
-				},
-				"versions": bson.M{
-					"$addToSet": "$version",
-				},
-			},
-		}},
-		{{
-			"$project",
-			bson.M{
-				"name":     "$_id",
-				"node_ids": "$node_ids",
-				"versions": "$versions",
-			},
-		}},
-	}
-	if err := svc.parent.colD.Aggregate(pipelines, nil).All(&depsResults); err != nil {
+	// dependencies in db, which are installed in node_modules of
+	// spider workspace, or globally otherwise
+	localDepsResultsMap, err := p._getSpiderDependencyResultsMap(spiderId, depNames)
+	if err != nil {
+		return nil, err
+	}
+	depsResultsMap, err := p._getDependencyResultsMap(depNames)
+	if err != nil {
 		return nil, err
 	}
 
-	// dependencies map
-	depsResultsMap := map[string]entity.DependencyResult{}
-	for _, dr := range depsResults {
-		depsResultsMap[dr.Name] = dr
+	// setting of registry
+	if err := p._getSetting(); err != nil {
+		return nil, err
 	}
+	client := p._getRegistryClient()
+
+	// wait group, with limited concurrent requests
+	wg := sync.WaitGroup{}
+	sem := make(chan bool, 8)
 
 	// iterate dependencies
 	for i, d := range deps {
-		// operator
-		var op string
-		if strings.HasPrefix(d.Version, "==") {
-			op = "=="
-			deps[i].Version = strings.Replace(d.Version, "==", "", 1)
-		} else if strings.HasPrefix(d.Version, ">=") {
-			op = ">="
-		} else if strings.HasPrefix(d.Version, "<=") {
-			op = "<="
+		// required range, skipped if not a semver range, e.g.
+		// "latest", "file:../lib" or "github:user/repo"
+		r, err := parseNpmRange(d.Version)
+		if err != nil {
+			continue
+		}
+
+		// newest version satisfying the range versus the latest version
+		if client != nil {
+			wg.Add(1)
+			go func(i int, r npmRange) {
+				sem <- true
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				versions, err := client.GetVersions(deps[i].Name)
+				if err != nil || len(versions) == 0 {
+					return
+				}
+				latestVersion, err := client.GetLatestVersion(deps[i].Name)
+				if err != nil {
+					return
+				}
+				deps[i].Result.WantedVersion = r.maxSatisfying(versions)
+				deps[i].Result.LatestVersion = latestVersion
+			}(i, r)
 		}
+	}
+
+	// wait for all versions
+	wg.Wait()
 
+	// iterate dependencies
+	for i, d := range deps {
 		// dependency result
-		dr, ok := depsResultsMap[d.Name]
+		dr, ok := localDepsResultsMap[d.Name]
 		if !ok {
+			dr, ok = depsResultsMap[d.Name]
+		}
+		if !ok {
+			deps[i].Result.Installable = true
 			continue
 		}
+		dr.WantedVersion = d.Result.WantedVersion
+		if d.Result.LatestVersion != "" {
+			dr.LatestVersion = d.Result.LatestVersion
+		}
 		deps[i].Result = dr
 
-		// normalized version
-		nv := strings.Replace(d.Version, op, "", 1)
-
-		// required version
-		rv, err := semver.Make(nv)
+		// required range
+		r, err := parseNpmRange(d.Version)
 		if err != nil {
 			continue
 		}
@@ -295,27 +412,20 @@ func (svc *SpiderService) _getDependenciesRequirementsTxt(workspacePath string)
 		// iterate installed versions
 		for _, v := range dr.Versions {
 			// installed version
-			iv, err := semver.Make(v)
+			iv, err := semver.ParseTolerant(v)
 			if err != nil {
 				continue
 			}
 
-			// compare with the required version
-			switch op {
-			case "==":
-				if rv.Compare(iv) > 0 {
-					deps[i].Result.Upgradable = true
-				} else if rv.Compare(iv) < 0 {
-					deps[i].Result.Downgradable = true
-				}
-			case ">=":
-				if rv.Compare(iv) > 0 {
-					deps[i].Result.Upgradable = true
-				}
-			case "<=":
-				if rv.Compare(iv) < 0 {
-					deps[i].Result.Downgradable = true
-				}
+			// compare with the required range
+			if r.test(iv) {
+				continue
+			}
+			deps[i].Result.Installable = true
+			if r.isLower(iv) {
+				deps[i].Result.Upgradable = true
+			} else {
+				deps[i].Result.Downgradable = true
 			}
 		}
 	}
@@ -323,6 +433,39 @@ func (svc *SpiderService) _getDependenciesRequirementsTxt(workspacePath string)
 	return deps, nil
 }
 
+// _getDependenciesWithResults attaches installed results of the given
+// dependency provider to the dependencies declared in spider config, which
+// are scoped to the isolated environment of spider if envSpiderId is set
+func (svc *SpiderService) _getDependenciesWithResults(key string, envSpiderId primitive.ObjectID, deps []models.Dependency) (res []models.Dependency, err error) {
+	// dependency provider
+	p, err := svc.parent.getProvider(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// dependency names
+	var depNames []string
+	for _, d := range deps {
+		depNames = append(depNames, d.Name)
+	}
+
+	// dependencies in db
+	depsResultsMap, err := p._getSpiderDependencyResultsMap(envSpiderId, depNames)
+	if err != nil {
+		return nil, err
+	}
+
+	// iterate dependencies
+	for i, d := range deps {
+		dr, ok := depsResultsMap[d.Name]
+		if ok {
+			deps[i].Result = dr
+		}
+	}
+
+	return deps, nil
+}
+
 func NewSpiderService(parent *Service) (svc *SpiderService) {
 	svc = &SpiderService{
 		parent: parent,
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/crawlab-team/crawlab-core/controllers"
+	"github.com/crawlab-team/go-trace"
+	"github.com/crawlab-team/plugin-dependency/constants"
+	"github.com/crawlab-team/plugin-dependency/entity"
+	"github.com/crawlab-team/plugin-dependency/models"
+	"github.com/gin-gonic/gin"
+	"os"
+	"os/exec"
+)
+
+type SystemService struct {
+	*baseService
+}
+
+func (svc *SystemService) GetRepoList(c *gin.Context) {
+	// query
+	query := c.Query("query")
+	pagination := controllers.MustGetPagination(c)
+
+	// validate
+	if query == "" {
+		controllers.HandleErrorBadRequest(c, errors.New("empty query"))
+		return
+	}
+
+	// setting
+	if err := svc._getSetting(); err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// package manager of master node
+	pm, err := svc._getPackageManager(svc._getCmd())
+	if err != nil {
+		controllers.HandleErrorBadRequest(c, err)
+		return
+	}
+
+	// search
+	var args []string
+	args = append(args, pm.searchArgs[1:]...)
+	args = append(args, query)
+	lines, err := svc._getOutputLines(pm, pm.searchArgs[0], args)
+	if err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+	var results []models.Dependency
+	for _, line := range lines {
+		if d, ok := pm.parseSearch(line); ok {
+			results = append(results, d)
+		}
+	}
+
+	// empty results
+	total := len(results)
+	if total == 0 {
+		controllers.HandleSuccess(c)
+		return
+	}
+
+	// paginate
+	start := (pagination.Page - 1) * pagination.Size
+	if start > total {
+		start = total
+	}
+	end := start + pagination.Size
+	if end > total {
+		end = total
+	}
+	deps := results[start:end]
+
+	// dependency names
+	var depNames []string
+	for _, d := range deps {
+		depNames = append(depNames, d.Name)
+	}
+
+	// dependencies in db
+	depsResultsMap, err := svc._getDependencyResultsMap(depNames)
+	if err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// iterate dependencies
+	for i, d := range deps {
+		dr, ok := depsResultsMap[d.Name]
+		if ok {
+			deps[i].Result = dr
+		}
+	}
+
+	controllers.HandleSuccessWithListData(c, deps, total)
+}
+
+func (svc *SystemService) GetDependencies(params entity.UpdateParams) (deps []models.Dependency, err error) {
+	// package manager
+	pm, err := svc._getPackageManager(params.Cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// installed packages
+	lines, err := svc._getOutputLines(pm, pm.listArgs[0], pm.listArgs[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	// upgradable packages, which are resolved on each node as the
+	// package repositories may differ from those of master node
+	latestVersions := map[string]string{}
+	upgradableLines, err := svc._getOutputLines(pm, pm.upgradableArgs[0], pm.upgradableArgs[1:])
+	if err != nil {
+		trace.PrintError(err)
+	}
+	for _, line := range upgradableLines {
+		if d, ok := pm.parseUpgradable(line); ok {
+			latestVersions[d.Name] = d.LatestVersion
+		}
+	}
+
+	// dependencies
+	for _, line := range lines {
+		d, ok := pm.parseList(line)
+		if !ok {
+			continue
+		}
+		d.Type = constants.DependencyTypeSystem
+		d.LatestVersion = d.Version
+		if v, ok := latestVersions[d.Name]; ok {
+			d.LatestVersion = v
+		}
+		deps = append(deps, d)
+	}
+
+	return deps, nil
+}
+
+func (svc *SystemService) InstallDependencies(params entity.InstallParams) (err error) {
+	// validate
+	if params.UseConfig {
+		return trace.TraceError(errors.New("installing by config is not supported by system"))
+	}
+
+	// package manager
+	pm, err := svc._getPackageManager(params.Cmd)
+	if err != nil {
+		return err
+	}
+
+	// refresh package index
+	if len(pm.refreshArgs) > 0 {
+		if err := svc._runCmd(params.TaskId, svc._getCommand(pm, pm.refreshArgs[0], pm.refreshArgs[1:])); err != nil {
+			return err
+		}
+	}
+
+	// arguments
+	var args []string
+	args = append(args, pm.installArgs[1:]...)
+	args = append(args, params.Names...)
+
+	// run
+	return svc._runCmd(params.TaskId, svc._getCommand(pm, pm.installArgs[0], args))
+}
+
+func (svc *SystemService) UninstallDependencies(params entity.UninstallParams) (err error) {
+	// package manager
+	pm, err := svc._getPackageManager(params.Cmd)
+	if err != nil {
+		return err
+	}
+
+	// arguments
+	var args []string
+	args = append(args, pm.uninstallArgs[1:]...)
+	args = append(args, params.Names...)
+
+	// run
+	return svc._runCmd(params.TaskId, svc._getCommand(pm, pm.uninstallArgs[0], args))
+}
+
+func (svc *SystemService) GetLatestVersion(dep models.Dependency) (v string, err error) {
+	// latest versions are reported by each node in GetDependencies
+	return dep.LatestVersion, nil
+}
+
+// CompareVersions compares versions of system packages in dpkg ordering,
+// which is also followed by rpm and apk versions, e.g. "1:2.34-1ubuntu1"
+func (svc *SystemService) CompareVersions(v1, v2 string) (res int, err error) {
+	return compareSystemVersions(v1, v2)
+}
+
+// _getPackageManager returns the package manager specified by cmd,
+// or the first package manager whose binaries are found in PATH
+func (svc *SystemService) _getPackageManager(cmd string) (pm systemPackageManager, err error) {
+	for _, pm := range systemPackageManagers {
+		if cmd != "" && cmd != pm.name {
+			continue
+		}
+		found := true
+		for _, bin := range pm.bins {
+			if _, err := exec.LookPath(bin); err != nil {
+				found = false
+				break
+			}
+		}
+		if found {
+			return pm, nil
+		}
+	}
+	if cmd != "" {
+		return pm, trace.TraceError(errors.New(fmt.Sprintf("package manager not found: %s", cmd)))
+	}
+	return pm, trace.TraceError(errors.New("no supported package manager found"))
+}
+
+func (svc *SystemService) _getCommand(pm systemPackageManager, name string, args []string) (cmd *exec.Cmd) {
+	cmd = exec.Command(name, args...)
+	if len(pm.env) > 0 {
+		cmd.Env = append(os.Environ(), pm.env...)
+	}
+	return cmd
+}
+
+func (svc *SystemService) _getOutputLines(pm systemPackageManager, name string, args []string) (lines []string, err error) {
+	data, err := svc._getCommand(pm, name, args).Output()
+	if err != nil {
+		// non-zero exit codes are used by some package managers to
+		// indicate results, e.g. "yum check-update" exits with 100
+		// if updates are available
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || len(data) == 0 {
+			return nil, trace.TraceError(err)
+		}
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, nil
+}
+
+func NewSystemService(parent *Service) (svc *SystemService) {
+	svc = &SystemService{}
+	baseSvc := newBaseService(
+		svc,
+		parent,
+		constants.DependencyTypeSystem,
+		entity.MessageCodes{
+			Update:    constants.MessageCodeSystemUpdate,
+			Save:      constants.MessageCodeSystemSave,
+			Install:   constants.MessageCodeSystemInstall,
+			Uninstall: constants.MessageCodeSystemUninstall,
+		},
+		models.Setting{
+			Name:        "System",
+			Description: "settings.description.system",
+			Enabled:     true,
+		},
+	)
+	svc.baseService = baseSvc
+	return svc
+}
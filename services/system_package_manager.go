@@ -0,0 +1,165 @@
+package services
+
+import (
+	"github.com/crawlab-team/plugin-dependency/constants"
+	"github.com/crawlab-team/plugin-dependency/models"
+	"strings"
+)
+
+// systemPackageManager describes how an OS package manager lists,
+// installs and uninstalls packages. The first element of each args
+// slice is the command to execute.
+type systemPackageManager struct {
+	name            string
+	bins            []string
+	env             []string
+	listArgs        []string
+	parseList       func(line string) (d models.Dependency, ok bool)
+	upgradableArgs  []string
+	parseUpgradable func(line string) (d models.Dependency, ok bool)
+	refreshArgs     []string
+	installArgs     []string
+	uninstallArgs   []string
+	searchArgs      []string
+	parseSearch     func(line string) (d models.Dependency, ok bool)
+}
+
+var systemPackageManagers = []systemPackageManager{
+	{
+		name:     constants.SystemPackageManagerApt,
+		bins:     []string{"apt-get", "dpkg-query"},
+		env:      []string{"DEBIAN_FRONTEND=noninteractive"},
+		listArgs: []string{"dpkg-query", "-W", "-f=${Package}\t${Version}\t${Status}\n"},
+		parseList: func(line string) (d models.Dependency, ok bool) {
+			// e.g. "libxml2	2.9.10+dfsg-6.7	install ok installed"
+			fields := strings.Split(line, "\t")
+			if len(fields) < 3 || !strings.HasSuffix(fields[2], " installed") {
+				return d, false
+			}
+			return models.Dependency{Name: fields[0], Version: fields[1]}, true
+		},
+		upgradableArgs: []string{"apt", "list", "--upgradable"},
+		parseUpgradable: func(line string) (d models.Dependency, ok bool) {
+			// e.g. "libssl1.1/stable 1.1.1n-0+deb11u4 amd64 [upgradable from: 1.1.1n-0+deb11u3]"
+			fields := strings.Fields(line)
+			if len(fields) < 2 || !strings.Contains(line, "upgradable from") {
+				return d, false
+			}
+			name := strings.SplitN(fields[0], "/", 2)[0]
+			return models.Dependency{Name: name, LatestVersion: fields[1]}, true
+		},
+		refreshArgs:   []string{"apt-get", "update"},
+		installArgs:   []string{"apt-get", "install", "-y"},
+		uninstallArgs: []string{"apt-get", "remove", "-y"},
+		searchArgs:    []string{"apt-cache", "search"},
+		parseSearch: func(line string) (d models.Dependency, ok bool) {
+			// e.g. "libxml2-dev - Development files for the GNOME XML library"
+			parts := strings.SplitN(line, " - ", 2)
+			if len(parts) < 2 {
+				return d, false
+			}
+			return models.Dependency{Name: parts[0], Description: parts[1]}, true
+		},
+	},
+	{
+		name:     constants.SystemPackageManagerApk,
+		bins:     []string{"apk"},
+		listArgs: []string{"apk", "info", "-v"},
+		parseList: func(line string) (d models.Dependency, ok bool) {
+			// e.g. "libxml2-2.9.14-r2"
+			name, version, ok := splitApkPackage(strings.TrimSpace(line))
+			if !ok {
+				return d, false
+			}
+			return models.Dependency{Name: name, Version: version}, true
+		},
+		upgradableArgs: []string{"apk", "version", "-l", "<"},
+		parseUpgradable: func(line string) (d models.Dependency, ok bool) {
+			// e.g. "busybox-1.35.0-r13          < 1.35.0-r14"
+			fields := strings.Fields(line)
+			if len(fields) < 3 || fields[1] != "<" {
+				return d, false
+			}
+			name, _, ok := splitApkPackage(fields[0])
+			if !ok {
+				return d, false
+			}
+			return models.Dependency{Name: name, LatestVersion: fields[2]}, true
+		},
+		refreshArgs:   []string{"apk", "update"},
+		installArgs:   []string{"apk", "add"},
+		uninstallArgs: []string{"apk", "del"},
+		searchArgs:    []string{"apk", "search", "-v"},
+		parseSearch: func(line string) (d models.Dependency, ok bool) {
+			// e.g. "libxml2-dev-2.9.14-r2 - XML parsing library, version 2 (development files)"
+			parts := strings.SplitN(line, " - ", 2)
+			name, version, ok := splitApkPackage(strings.TrimSpace(parts[0]))
+			if !ok {
+				return d, false
+			}
+			d = models.Dependency{Name: name, LatestVersion: version}
+			if len(parts) > 1 {
+				d.Description = parts[1]
+			}
+			return d, true
+		},
+	},
+	newRpmPackageManager(constants.SystemPackageManagerDnf),
+	newRpmPackageManager(constants.SystemPackageManagerYum),
+}
+
+func newRpmPackageManager(cmd string) (pm systemPackageManager) {
+	return systemPackageManager{
+		name:     cmd,
+		bins:     []string{cmd, "rpm"},
+		listArgs: []string{"rpm", "-qa", "--qf", "%{NAME}\t%{VERSION}-%{RELEASE}\n"},
+		parseList: func(line string) (d models.Dependency, ok bool) {
+			// e.g. "libxml2	2.9.7-9.el8"
+			fields := strings.Split(line, "\t")
+			if len(fields) < 2 || fields[0] == "" {
+				return d, false
+			}
+			return models.Dependency{Name: fields[0], Version: fields[1]}, true
+		},
+		upgradableArgs: []string{cmd, "-q", "check-update"},
+		parseUpgradable: func(line string) (d models.Dependency, ok bool) {
+			// e.g. "libxml2.x86_64    2.9.7-15.el8    baseos"
+			fields := strings.Fields(line)
+			if len(fields) != 3 {
+				return d, false
+			}
+			idx := strings.LastIndex(fields[0], ".")
+			if idx <= 0 {
+				return d, false
+			}
+			return models.Dependency{Name: fields[0][:idx], LatestVersion: fields[1]}, true
+		},
+		installArgs:   []string{cmd, "install", "-y"},
+		uninstallArgs: []string{cmd, "remove", "-y"},
+		searchArgs:    []string{cmd, "-q", "search"},
+		parseSearch: func(line string) (d models.Dependency, ok bool) {
+			// e.g. "libxml2-devel.x86_64 : Libraries, includes, etc. to develop XML and HTML applications"
+			parts := strings.SplitN(line, " : ", 2)
+			if len(parts) < 2 {
+				return d, false
+			}
+			name := strings.TrimSpace(parts[0])
+			if idx := strings.LastIndex(name, "."); idx > 0 {
+				name = name[:idx]
+			}
+			return models.Dependency{Name: name, Description: parts[1]}, true
+		},
+	}
+}
+
+// splitApkPackage splits an apk package string "<name>-<version>-r<release>"
+// into name and version
+func splitApkPackage(s string) (name, version string, ok bool) {
+	parts := strings.Split(s, "-")
+	if len(parts) < 3 || !strings.HasPrefix(parts[len(parts)-1], "r") {
+		return "", "", false
+	}
+	name = strings.Join(parts[:len(parts)-2], "-")
+	version = strings.Join(parts[len(parts)-2:], "-")
+	return name, version, true
+}
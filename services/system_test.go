@@ -0,0 +1,130 @@
+package services
+
+import (
+	"github.com/crawlab-team/plugin-dependency/constants"
+	"github.com/crawlab-team/plugin-dependency/entity"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSystemService_GetDependencies(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stub package managers are shell scripts")
+	}
+
+	cases := []struct {
+		cmd      string
+		dir      string
+		versions map[string][2]string // name -> version, latest version
+	}{
+		{
+			cmd: constants.SystemPackageManagerApt,
+			dir: "apt",
+			versions: map[string][2]string{
+				"libxml2":   {"2.9.10+dfsg-6.7", "2.9.10+dfsg-6.7"},
+				"libssl1.1": {"1.1.1n-0+deb11u3", "1.1.1n-0+deb11u4"},
+			},
+		},
+		{
+			cmd: constants.SystemPackageManagerApk,
+			dir: "apk",
+			versions: map[string][2]string{
+				"busybox": {"1.35.0-r13", "1.35.0-r14"},
+				"libxml2": {"2.9.14-r2", "2.9.14-r2"},
+			},
+		},
+		{
+			cmd: constants.SystemPackageManagerDnf,
+			dir: "rpm",
+			versions: map[string][2]string{
+				"libxml2": {"2.9.7-9.el8", "2.9.7-15.el8"},
+				"openssl": {"1.1.1k-6.el8", "1.1.1k-6.el8"},
+			},
+		},
+	}
+
+	svc := &SystemService{}
+	for _, c := range cases {
+		t.Run(c.cmd, func(t *testing.T) {
+			// stub package manager binaries only
+			dir, err := filepath.Abs(filepath.Join("testdata", "system", c.dir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := os.Getenv("PATH")
+			_ = os.Setenv("PATH", dir)
+			defer func() { _ = os.Setenv("PATH", path) }()
+
+			deps, err := svc.GetDependencies(entity.UpdateParams{Cmd: c.cmd})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(deps) != len(c.versions) {
+				t.Fatalf("expected %d dependencies, got %d: %v", len(c.versions), len(deps), deps)
+			}
+			for _, d := range deps {
+				v, ok := c.versions[d.Name]
+				if !ok {
+					t.Errorf("unexpected dependency: %s", d.Name)
+					continue
+				}
+				if d.Version != v[0] || d.LatestVersion != v[1] {
+					t.Errorf("%s: expected %s (latest %s), got %s (latest %s)", d.Name, v[0], v[1], d.Version, d.LatestVersion)
+				}
+				if d.Type != constants.DependencyTypeSystem {
+					t.Errorf("%s: unexpected type %s", d.Name, d.Type)
+				}
+			}
+		})
+	}
+}
+
+func TestSystemService_GetPackageManagerNotFound(t *testing.T) {
+	path := os.Getenv("PATH")
+	_ = os.Setenv("PATH", t.TempDir())
+	defer func() { _ = os.Setenv("PATH", path) }()
+
+	if _, err := (&SystemService{}).GetDependencies(entity.UpdateParams{}); err == nil {
+		t.Fatal("expected error if no package manager is found")
+	}
+}
+
+func TestSystemService_CompareVersions(t *testing.T) {
+	svc := &SystemService{}
+	cases := []struct {
+		a, b string
+		res  int
+	}{
+		// dpkg
+		{"2.34-1ubuntu1", "2.34-1ubuntu2", -1},
+		{"1:2.34-1ubuntu1", "2.35-1", 1},
+		{"1.1.1n-0+deb11u3", "1.1.1n-0+deb11u4", -1},
+		{"2.9.10+dfsg-6.7", "2.9.10+dfsg-6.7", 0},
+		{"1.0~rc1-1", "1.0-1", -1},
+		{"1.0a", "1.0+", -1},
+		{"1.02", "1.2", 0},
+
+		// rpm
+		{"1.1.1k-6.el8", "1.1.1k-7.el8", -1},
+		{"2.9.7-9.el8", "2.9.7-15.el8", -1},
+
+		// apk
+		{"1.2.3-r0", "1.2.3-r1", -1},
+		{"1.35.0-r13", "1.35.0-r14", -1},
+		{"1.35.10-r0", "1.35.9-r2", 1},
+	}
+	for _, c := range cases {
+		res, err := svc.CompareVersions(c.a, c.b)
+		if err != nil {
+			t.Fatalf("compare %s with %s: %v", c.a, c.b, err)
+		}
+		if res != c.res {
+			t.Errorf("compare %s with %s: expected %d, got %d", c.a, c.b, c.res, res)
+		}
+	}
+	if _, err := svc.CompareVersions("", "1.0"); err == nil {
+		t.Error("expected error of empty version")
+	}
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// systemVersion is a version of system package in the dpkg format
+// "[epoch:]upstream[-revision]", which also covers rpm, e.g.
+// "1.1.1k-6.el8", and apk, e.g. "1.35.0-r13"
+type systemVersion struct {
+	epoch    int
+	upstream string
+	revision string
+}
+
+func parseSystemVersion(s string) (v systemVersion, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return v, errors.New("empty system package version")
+	}
+
+	// epoch
+	if i := strings.Index(s, ":"); i >= 0 {
+		v.epoch, err = strconv.Atoi(s[:i])
+		if err != nil || v.epoch < 0 {
+			return v, errors.New(fmt.Sprintf("invalid system package version: %s", s))
+		}
+		s = s[i+1:]
+	}
+
+	// revision
+	if i := strings.LastIndex(s, "-"); i >= 0 {
+		v.revision = s[i+1:]
+		s = s[:i]
+	}
+	v.upstream = s
+	if v.upstream == "" || strings.ContainsAny(v.upstream, " \t") {
+		return v, errors.New(fmt.Sprintf("invalid system package version: %s", s))
+	}
+
+	return v, nil
+}
+
+func (v systemVersion) compare(o systemVersion) (res int) {
+	if res = compareInt(v.epoch, o.epoch); res != 0 {
+		return res
+	}
+	if res = compareSystemVersionPart(v.upstream, o.upstream); res != 0 {
+		return res
+	}
+	return compareSystemVersionPart(v.revision, o.revision)
+}
+
+// compareSystemVersionPart compares upstream versions or revisions as dpkg
+// does, alternating non-digit parts, where "~" sorts before everything and
+// letters before other characters, and numeric parts
+func compareSystemVersionPart(a, b string) (res int) {
+	for a != "" || b != "" {
+		// non-digit part
+		for (a != "" && !isSystemVersionDigit(a[0])) || (b != "" && !isSystemVersionDigit(b[0])) {
+			ac, bc := systemVersionCharOrder(a), systemVersionCharOrder(b)
+			if ac != bc {
+				return compareInt(ac, bc)
+			}
+			a, b = a[1:], b[1:]
+		}
+
+		// numeric part
+		i, j := 0, 0
+		for i < len(a) && isSystemVersionDigit(a[i]) {
+			i++
+		}
+		for j < len(b) && isSystemVersionDigit(b[j]) {
+			j++
+		}
+		na := strings.TrimLeft(a[:i], "0")
+		nb := strings.TrimLeft(b[:j], "0")
+		if res = compareInt(len(na), len(nb)); res != 0 {
+			return res
+		}
+		if res = strings.Compare(na, nb); res != 0 {
+			return res
+		}
+		a, b = a[i:], b[j:]
+	}
+	return 0
+}
+
+// systemVersionCharOrder returns sort order of the first character, where
+// the end of string is the same as a digit
+func systemVersionCharOrder(s string) (order int) {
+	if s == "" {
+		return 0
+	}
+	c := s[0]
+	switch {
+	case isSystemVersionDigit(c):
+		return 0
+	case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		return int(c)
+	case c == '~':
+		return -1
+	default:
+		return int(c) + 256
+	}
+}
+
+func isSystemVersionDigit(c byte) (res bool) {
+	return c >= '0' && c <= '9'
+}
+
+// compareSystemVersions compares versions of system packages, e.g.
+// "1:2.34-1ubuntu1" and "2.34-1ubuntu2"
+func compareSystemVersions(v1, v2 string) (res int, err error) {
+	a, err := parseSystemVersion(v1)
+	if err != nil {
+		return 0, err
+	}
+	b, err := parseSystemVersion(v2)
+	if err != nil {
+		return 0, err
+	}
+	return a.compare(b), nil
+}
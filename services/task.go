@@ -1,8 +1,16 @@
 package services
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	constants2 "github.com/crawlab-team/crawlab-core/constants"
 	"github.com/crawlab-team/crawlab-core/controllers"
+	"github.com/crawlab-team/crawlab-core/interfaces"
 	mongo2 "github.com/crawlab-team/crawlab-db/mongo"
+	grpc "github.com/crawlab-team/crawlab-grpc"
+	"github.com/crawlab-team/plugin-dependency/constants"
+	"github.com/crawlab-team/plugin-dependency/entity"
 	"github.com/crawlab-team/plugin-dependency/models"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
@@ -18,6 +26,7 @@ type TaskService struct {
 func (svc *TaskService) Init() {
 	svc.api.GET("/tasks", svc.getList)
 	svc.api.GET("/tasks/:id/logs", svc.getLogs)
+	svc.api.POST("/tasks/:id/cancel", svc.cancel)
 }
 
 func (svc *TaskService) getList(c *gin.Context) {
@@ -72,6 +81,82 @@ func (svc *TaskService) getLogs(c *gin.Context) {
 	controllers.HandleSuccessWithData(c, logList)
 }
 
+// cancel sends cancel message to the node running the task, which kills the
+// running command and reports the task as cancelled
+func (svc *TaskService) cancel(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		controllers.HandleErrorBadRequest(c, err)
+		return
+	}
+
+	// task
+	var t models.Task
+	if err := svc.parent.colT.FindId(id).One(&t); err != nil {
+		controllers.HandleErrorNotFound(c, err)
+		return
+	}
+	if t.Status != constants.TaskStatusQueued && t.Status != constants2.TaskStatusRunning {
+		controllers.HandleErrorBadRequest(c, errors.New(fmt.Sprintf("task is not running: %s", t.Status)))
+		return
+	}
+
+	// node model service
+	nodeModelSvc, err := svc.parent.GetModelService().NewBaseServiceDelegate(interfaces.ModelIdNode)
+	if err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// node
+	doc, err := nodeModelSvc.GetById(t.NodeId)
+	if err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+	n, ok := doc.(interfaces.Node)
+	if !ok {
+		controllers.HandleErrorInternalServerError(c, errors.New("invalid type"))
+		return
+	}
+
+	// data
+	data, err := json.Marshal(&entity.TaskMessage{
+		TaskId: t.Id,
+	})
+	if err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// message data
+	msgData, err := json.Marshal(&entity.MessageData{
+		Code: constants.MessageCodeCancelTask,
+		Data: data,
+	})
+	if err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	// stream message
+	msg := &grpc.StreamMessage{
+		Code:    grpc.StreamMessageCode_SEND,
+		NodeKey: svc.parent.currentNode.GetKey(),
+		From:    "plugin:" + svc.parent.currentNode.GetKey(),
+		To:      "plugin:" + n.GetKey(),
+		Data:    msgData,
+	}
+
+	// send message
+	if err := svc.parent.msgStream.Send(msg); err != nil {
+		controllers.HandleErrorInternalServerError(c, err)
+		return
+	}
+
+	controllers.HandleSuccess(c)
+}
+
 func NewTaskService(parent *Service) (svc *TaskService) {
 	svc = &TaskService{
 		parent: parent,
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/cenkalti/backoff/v4"
+	constants2 "github.com/crawlab-team/crawlab-core/constants"
+	"github.com/crawlab-team/go-trace"
+	"github.com/crawlab-team/plugin-dependency/constants"
+	"github.com/crawlab-team/plugin-dependency/entity"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"os/exec"
+	"regexp"
+	"sync"
+	"time"
+)
+
+var errTaskCancelled = errors.New("task cancelled")
+var errTaskTimeout = errors.New("task timed out")
+
+// taskProcess is a task running on the current node, whose commands are
+// killed together with their process groups if cancelled or timed out
+type taskProcess struct {
+	mu         sync.Mutex
+	ctx        context.Context // context of task, done if cancelled
+	cancel     context.CancelFunc
+	attemptCtx context.Context // context of current attempt, done if timed out
+	cancelled  bool
+	exitCode   int
+	transient  bool
+	patterns   []*regexp.Regexp
+}
+
+// getContext returns the context of current attempt
+func (proc *taskProcess) getContext() (ctx context.Context) {
+	proc.mu.Lock()
+	defer proc.mu.Unlock()
+	return proc.attemptCtx
+}
+
+// getError returns the error of command run in the context, which is
+// replaced if the task is cancelled or timed out
+func (proc *taskProcess) getError(ctx context.Context, err error) (res error) {
+	proc.mu.Lock()
+	defer proc.mu.Unlock()
+	if proc.cancelled {
+		return errTaskCancelled
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return errTaskTimeout
+	}
+	return err
+}
+
+// setExitCode records the exit code of the command, which is -1 if
+// the command is not started or killed
+func (proc *taskProcess) setExitCode(err error) {
+	proc.mu.Lock()
+	defer proc.mu.Unlock()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		proc.exitCode = 0
+	case errors.As(err, &exitErr):
+		proc.exitCode = exitErr.ExitCode()
+	default:
+		proc.exitCode = -1
+	}
+}
+
+// matchLine marks the attempt as transient if the log line matches any
+// transient pattern
+func (proc *taskProcess) matchLine(line string) {
+	proc.mu.Lock()
+	defer proc.mu.Unlock()
+	for _, p := range proc.patterns {
+		if p.MatchString(line) {
+			proc.transient = true
+			return
+		}
+	}
+}
+
+// _runTask runs the task on the current node after waiting in the queue,
+// with its timeout and retry policy, records each attempt, and returns true
+// if it has been cancelled
+func (svc *Service) _runTask(taskId primitive.ObjectID, q *taskQueue, concurrency int, policy entity.TaskPolicy, run func() (err error)) (cancelled bool, err error) {
+	// transient log patterns
+	var patterns []*regexp.Regexp
+	for _, p := range policy.RetryPatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return false, trace.TraceError(err)
+		}
+		patterns = append(patterns, re)
+	}
+
+	// register the task to be cancelled
+	proc := &taskProcess{patterns: patterns}
+	proc.ctx, proc.cancel = context.WithCancel(context.Background())
+	svc.procMap.Store(taskId, proc)
+	defer svc.procMap.Delete(taskId)
+	defer proc.cancel()
+
+	// skip if cancelled before registered
+	if svc._isTaskCancelled(taskId) {
+		return true, errTaskCancelled
+	}
+
+	// wait in queue, unless cancelled
+	if err := q.acquire(proc.ctx, concurrency); err != nil {
+		return true, errTaskCancelled
+	}
+	defer q.release(concurrency)
+	svc._sendTaskStatus(taskId, constants2.TaskStatusRunning, nil)
+
+	// backoff between attempts
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = time.Duration(policy.Backoff) * time.Second
+	b.Multiplier = 2
+	b.RandomizationFactor = 0
+	if policy.MaxBackoff > 0 {
+		b.MaxInterval = time.Duration(policy.MaxBackoff) * time.Second
+	}
+	b.MaxElapsedTime = 0
+	b.Reset()
+
+	for i := 1; ; i++ {
+		// context of attempt
+		ctx, cancel := context.WithCancel(proc.ctx)
+		if policy.Timeout > 0 {
+			cancel()
+			ctx, cancel = context.WithTimeout(proc.ctx, time.Duration(policy.Timeout)*time.Second)
+		}
+		proc.mu.Lock()
+		proc.attemptCtx = ctx
+		proc.exitCode = 0
+		proc.transient = false
+		proc.mu.Unlock()
+
+		// run
+		attempt := entity.TaskAttempt{
+			Attempt: i,
+			StartTs: time.Now(),
+		}
+		err = run()
+		attempt.EndTs = time.Now()
+		attempt.TimedOut = ctx.Err() == context.DeadlineExceeded
+		cancel()
+
+		// record attempt
+		proc.mu.Lock()
+		cancelled = proc.cancelled
+		attempt.ExitCode = proc.exitCode
+		transient := proc.transient
+		proc.mu.Unlock()
+		if err != nil {
+			attempt.Error = err.Error()
+		}
+		svc._sendTaskAttempt(taskId, attempt)
+
+		// skip retry if succeeded, cancelled or not transient
+		if err == nil || cancelled || i >= policy.MaxAttempts {
+			return cancelled, err
+		}
+		if !svc._isTransientAttempt(policy, attempt, transient) {
+			return false, err
+		}
+
+		// wait before retry, unless cancelled
+		select {
+		case <-time.After(b.NextBackOff()):
+		case <-proc.ctx.Done():
+			return true, err
+		}
+	}
+}
+
+// _isTransientAttempt returns true if the failed attempt is to be retried,
+// where any failure other than timeout is transient if neither exit codes
+// nor log patterns are specified
+func (svc *Service) _isTransientAttempt(policy entity.TaskPolicy, attempt entity.TaskAttempt, logMatched bool) (res bool) {
+	if attempt.TimedOut {
+		return policy.RetryOnTimeout
+	}
+	if len(policy.RetryExitCodes) == 0 && len(policy.RetryPatterns) == 0 {
+		return true
+	}
+	for _, code := range policy.RetryExitCodes {
+		if code == attempt.ExitCode {
+			return true
+		}
+	}
+	return logMatched
+}
+
+func (svc *Service) _getTaskProcess(taskId primitive.ObjectID) (proc *taskProcess, ok bool) {
+	res, ok := svc.procMap.Load(taskId)
+	if !ok {
+		return nil, false
+	}
+	proc, ok = res.(*taskProcess)
+	return proc, ok
+}
+
+// _killTaskProcess marks the task as cancelled and kills its running
+// command, returning false if the task is not running on the current node
+func (svc *Service) _killTaskProcess(taskId primitive.ObjectID) (ok bool) {
+	proc, ok := svc._getTaskProcess(taskId)
+	if !ok {
+		return false
+	}
+	proc.mu.Lock()
+	proc.cancelled = true
+	proc.mu.Unlock()
+	proc.cancel()
+	return true
+}
+
+// _markTaskCancelled records the task as cancelled on the current node, and
+// removes records older than the retention
+func (svc *Service) _markTaskCancelled(taskId primitive.ObjectID) {
+	svc.cancelMap.Range(func(key, value interface{}) bool {
+		if ts, ok := value.(time.Time); ok && time.Since(ts) > constants.TaskCancelledRetention {
+			svc.cancelMap.Delete(key)
+		}
+		return true
+	})
+	svc.cancelMap.Store(taskId, time.Now())
+}
+
+// _isTaskCancelled returns true if the task has been cancelled on the
+// current node, and removes the record
+func (svc *Service) _isTaskCancelled(taskId primitive.ObjectID) (res bool) {
+	_, res = svc.cancelMap.LoadAndDelete(taskId)
+	return res
+}
+
+// withCmdContext returns a copy of the command created by
+// exec.CommandContext, which is killed when the context is done
+func withCmdContext(ctx context.Context, cmd *exec.Cmd) (res *exec.Cmd) {
+	res = exec.CommandContext(ctx, cmd.Path, cmd.Args[1:]...)
+	res.Args = cmd.Args
+	res.Dir = cmd.Dir
+	res.Env = cmd.Env
+	res.Stdin = cmd.Stdin
+	res.Stdout = cmd.Stdout
+	res.Stderr = cmd.Stderr
+	res.ExtraFiles = cmd.ExtraFiles
+	res.SysProcAttr = cmd.SysProcAttr
+	return res
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/crawlab-team/plugin-dependency/entity"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestService_RunTaskCancelledBeforeArrival(t *testing.T) {
+	svc := &Service{}
+	taskId := primitive.NewObjectID()
+
+	// cancel arrives before the task
+	svc._markTaskCancelled(taskId)
+
+	ran := false
+	cancelled, err := svc._runTask(taskId, newTaskQueue(), 1, entity.TaskPolicy{MaxAttempts: 1}, func() (err error) {
+		ran = true
+		return nil
+	})
+	if !cancelled || err != errTaskCancelled {
+		t.Errorf("expected cancelled, got %t %v", cancelled, err)
+	}
+	if ran {
+		t.Error("cancelled task is run")
+	}
+	if svc._isTaskCancelled(taskId) {
+		t.Error("record of cancelled task is not removed")
+	}
+	if _, ok := svc._getTaskProcess(taskId); ok {
+		t.Error("process of cancelled task is not removed")
+	}
+}
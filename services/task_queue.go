@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// taskQueue serialises tasks changing dependencies of a provider on the
+// current node, where up to the concurrency of tasks run at once and the
+// others wait in order
+type taskQueue struct {
+	mu      sync.Mutex
+	running int
+	waiting []chan struct{}
+}
+
+// acquire waits until the task can run, or returns error if the context is
+// done before that
+func (q *taskQueue) acquire(ctx context.Context, concurrency int) (err error) {
+	q.mu.Lock()
+	if q.running < q._getLimit(concurrency) && len(q.waiting) == 0 {
+		q.running++
+		q.mu.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	q.waiting = append(q.waiting, ch)
+	q.mu.Unlock()
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		for i, c := range q.waiting {
+			if c == ch {
+				q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+				return ctx.Err()
+			}
+		}
+		// already acquired, pass on to the next one
+		q._release(concurrency)
+		return ctx.Err()
+	}
+}
+
+// release lets the next waiting task run
+func (q *taskQueue) release(concurrency int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q._release(concurrency)
+}
+
+func (q *taskQueue) _release(concurrency int) {
+	q.running--
+	for q.running < q._getLimit(concurrency) && len(q.waiting) > 0 {
+		close(q.waiting[0])
+		q.waiting = q.waiting[1:]
+		q.running++
+	}
+}
+
+// _getLimit returns the concurrency, which is 1 by default
+func (q *taskQueue) _getLimit(concurrency int) (limit int) {
+	if concurrency <= 0 {
+		return 1
+	}
+	return concurrency
+}
+
+func newTaskQueue() (q *taskQueue) {
+	return &taskQueue{}
+}